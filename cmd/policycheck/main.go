@@ -0,0 +1,24 @@
+// Command policycheck compiles the authorization policies in a directory and
+// runs the tests embedded in them, exiting non-zero on any failure. Run it in
+// CI before deploying policy changes:
+//
+//	go run ./cmd/policycheck ./policies
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Naomejoy/app-service/internal/policy"
+)
+
+func main() {
+	dir := "policies"
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+	if _, err := policy.NewEngine(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "policycheck: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+// Command rotatekeys rewraps the data keys of encrypted blobs with the
+// current master key. Blobs are not rewritten. Run it after making a new
+// master key current, with the previous keys still configured:
+//
+//	ENCRYPTION_KEY_FILE=keys ENCRYPTION_KEY_ID=2026-10 go run ./cmd/rotatekeys
+//
+// Once it reports nothing left to rewrap, the previous keys can be removed.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Naomejoy/app-service/internal/db"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/storage"
+	"github.com/Naomejoy/app-service/pkg/config"
+)
+
+const batchSize = 100
+
+func main() {
+	cfg := config.LoadConfig()
+	keyring, err := storage.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionKeyFile, cfg.EncryptionKeyID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotatekeys: %v\n", err)
+		os.Exit(1)
+	}
+	if keyring == nil {
+		fmt.Fprintln(os.Stderr, "rotatekeys: no encryption keys configured")
+		os.Exit(1)
+	}
+	db.ConnectDB(cfg)
+	keys := repository.NewBlobDataKeyRepository(db.DB)
+
+	ctx := context.Background()
+	current := keyring.Current()
+	rewrapped, failed := 0, 0
+	after := ""
+	for {
+		batch, err := keys.ListNotWrappedWith(ctx, current, after, batchSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatekeys: %v\n", err)
+			os.Exit(1)
+		}
+		for _, key := range batch {
+			after = key.ID
+			id, err := hex.DecodeString(key.ID)
+			if err != nil {
+				log.Printf("Failed to rewrap data key %s: %v", key.ID, err)
+				failed++
+				continue
+			}
+			masterKeyID, wrapped, err := keyring.Rewrap(key.MasterKeyID, key.WrappedKey, id)
+			if err != nil {
+				log.Printf("Failed to rewrap data key %s: %v", key.ID, err)
+				failed++
+				continue
+			}
+			// A key rewrapped concurrently is left as it is.
+			ok, err := keys.Rewrap(ctx, key.ID, key.MasterKeyID, masterKeyID, wrapped, time.Now())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "rotatekeys: %v\n", err)
+				os.Exit(1)
+			}
+			if ok {
+				rewrapped++
+			}
+		}
+		if len(batch) < batchSize {
+			break
+		}
+	}
+	log.Printf("Rewrapped %d data keys with master key %q; %d failed", rewrapped, current, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
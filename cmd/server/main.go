@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"log"
 	"net/http"
 	"os"
@@ -10,10 +11,16 @@ import (
 	"time"
 
 	"github.com/Naomejoy/app-service/internal/api"
+	"github.com/Naomejoy/app-service/internal/auth"
 	"github.com/Naomejoy/app-service/internal/db"
 	"github.com/Naomejoy/app-service/internal/middleware"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/ratelimit"
 	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/scan"
 	"github.com/Naomejoy/app-service/internal/service"
+	"github.com/Naomejoy/app-service/internal/signedurl"
+	"github.com/Naomejoy/app-service/internal/storage"
 	"github.com/Naomejoy/app-service/pkg/config"
 
 	"github.com/gin-gonic/gin"
@@ -25,7 +32,9 @@ import (
 
 // @title Application Service API
 // @version 1.0
-// @description API for managing applications, statuses and file types
+// @description API for managing applications, statuses, file types and documents.
+// @description Requests are scoped to the tenant bound to the credential. Only cross-tenant credentials (the shared API key and HMAC clients configured as cross-tenant) may name one in the X-Tenant-ID header; other credentials without a tenant use the default tenant.
+// @description A gateway holding the API key may act for an end user by sending X-User-ID and X-User-Roles; applicants only see their own applications.
 // @host localhost:8083
 // @BasePath /api/v1
 // @schemes http
@@ -35,8 +44,21 @@ import (
 // @in header
 // @name X-API-Key
 
+// Define signed request security (see pkg/hmacsign); X-Signature-Timestamp
+// and X-Signature-Nonce are required as well
+// @securityDefinitions.apikey HMACAuth
+// @in header
+// @name X-Signature
+
+// Define bearer token security ("Bearer <JWT>")
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
 // Apply security globally
 // @security ApiKeyAuth
+// @security BearerAuth
+// @security HMACAuth
 func main() {
 
 	cfg := config.LoadConfig()
@@ -47,15 +69,143 @@ func main() {
 	appRepo := repository.NewApplicationRepository(db.DB)
 	statusRepo := repository.NewApplicationStatusRepository(db.DB)
 	fileRepo := repository.NewApplicationFileTypeRepository(db.DB)
+	idempotencyRepo := repository.NewIdempotencyRepository(db.DB)
+	apiKeyRepo := repository.NewAPIKeyRepository(db.DB)
+	docRepo := repository.NewApplicationDocumentRepository(db.DB)
+	hmacNonceRepo := repository.NewHMACNonceRepository(db.DB)
+	uploadRepo := repository.NewDocumentUploadRepository(db.DB)
+	auditRepo := repository.NewAuditRepository(db.DB)
+	exportRepo := repository.NewExportJobRepository(db.DB)
+	typeRepo := repository.NewDocumentTypeRepository(db.DB)
+	blobRepo := repository.NewDocumentBlobRepository(db.DB)
+	dataKeyRepo := repository.NewBlobDataKeyRepository(db.DB)
+	retentionRepo := repository.NewRetentionRepository(db.DB)
 
-	appService := service.NewApplicationService(appRepo)
-	statusService := service.NewApplicationStatusService(statusRepo)
-	fileService := service.NewApplicationFileTypeService(fileRepo)
+	var blobs storage.BlobStore
+	switch cfg.StorageBackend {
+	case "s3":
+		s3Store, err := storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+		if err != nil {
+			log.Fatalf("Failed to configure S3 storage: %v", err)
+		}
+		blobs = s3Store
+	case "local":
+		localStore, err := storage.NewLocalStore(cfg.StorageLocalDir)
+		if err != nil {
+			log.Fatalf("Failed to configure local storage: %v", err)
+		}
+		blobs = localStore
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q", cfg.StorageBackend)
+	}
+	keyring, err := storage.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionKeyFile, cfg.EncryptionKeyID)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	if keyring != nil {
+		blobs = storage.NewEncryptedStore(blobs, dataKeyRepo, keyring)
+	} else {
+		log.Println("Encryption at rest disabled; documents are stored unencrypted")
+	}
+
+	var scanner scan.Scanner
+	switch cfg.Scanner {
+	case "clamd":
+		clamd, err := scan.NewClamd(cfg.ClamdAddress, cfg.ScanTimeout)
+		if err != nil {
+			log.Fatalf("Failed to configure clamd: %v", err)
+		}
+		scanner = clamd
+	case "none":
+		log.Println("Malware scanning disabled; documents are released unscanned")
+		scanner = scan.Noop{}
+	case "":
+		log.Fatal("SCANNER must be set: clamd, or none to release documents unscanned")
+	default:
+		log.Fatalf("Unknown SCANNER %q", cfg.Scanner)
+	}
+	previewWorker := service.NewPreviewWorker(docRepo, blobs)
+	go previewWorker.Run(context.Background(), cfg.PreviewInterval)
+	scanWorker := service.NewScanWorker(docRepo, blobs, scanner, previewWorker)
+	go scanWorker.Run(context.Background(), cfg.ScanInterval)
+
+	var policyEngine *policy.Engine
+	if cfg.PolicyDir != "" {
+		engine, err := policy.NewEngine(cfg.PolicyDir)
+		if err != nil {
+			log.Fatalf("Failed to load policies: %v", err)
+		}
+		policyEngine = engine
+		go policyEngine.Watch(context.Background(), cfg.PolicyReloadInterval)
+	}
+	authz := service.NewAuthorizer(policyEngine, statusRepo)
+
+	docService := service.NewApplicationDocumentService(docRepo, fileRepo, appRepo, blobRepo, blobs, service.UploadLimits{
+		MaxSize:           cfg.UploadMaxSize,
+		ArchiveMaxEntries: cfg.ArchiveMaxEntries,
+		ArchiveMaxSize:    cfg.ArchiveMaxSize,
+		ArchiveMaxRatio:   cfg.ArchiveMaxRatio,
+		ApplicationQuota:  cfg.ApplicationQuotaBytes,
+		UserQuota:         cfg.UserQuotaBytes,
+	}, scanWorker, cfg.Tenants, authz)
+	appService := service.NewApplicationService(appRepo, service.DuplicateConfig{
+		Policy:     cfg.DuplicatePolicy,
+		Similarity: cfg.DuplicateSimilarity,
+		Window:     cfg.DuplicateWindow,
+	}, cfg.Tenants, authz, docRepo, docService)
+	statusService := service.NewApplicationStatusService(statusRepo, appRepo, cfg.Tenants, authz, docService)
+	uploadService := service.NewDocumentUploadService(uploadRepo, docService, cfg.UploadExpiry)
+	linkSecret := []byte(cfg.DownloadLinkSecret)
+	if len(linkSecret) == 0 {
+		linkSecret = make([]byte, 32)
+		if _, err := rand.Read(linkSecret); err != nil {
+			log.Fatalf("Failed to generate download link secret: %v", err)
+		}
+		log.Println("DOWNLOAD_LINK_SECRET not set; download links only work on this instance until it restarts")
+	}
+	linkSigner, err := signedurl.NewSigner(linkSecret)
+	if err != nil {
+		log.Fatalf("Invalid DOWNLOAD_LINK_SECRET: %v", err)
+	}
+	linkService := service.NewDownloadLinkService(docService, auditRepo, linkSigner, service.DownloadLinkConfig{
+		BaseURL:    cfg.PublicBaseURL + "/api/v1",
+		DefaultTTL: cfg.DownloadLinkTTL,
+		MaxTTL:     cfg.DownloadLinkMaxTTL,
+	})
+	exportService := service.NewDocumentExportService(docService, exportRepo, auditRepo, service.ExportConfig{
+		MaxApplications: cfg.ExportMaxApplications,
+		Expiry:          cfg.ExportExpiry,
+	})
+	go exportService.Run(context.Background(), cfg.ExportInterval)
+	fileService := service.NewApplicationFileTypeService(fileRepo, typeRepo, docRepo, appRepo, authz)
+	typeService := service.NewDocumentTypeService(typeRepo)
+	expiryChecker := service.NewDocumentExpiryChecker(appRepo, statusRepo, auditRepo, cfg.Tenants)
+	retentionService := service.NewRetentionService(appRepo, docRepo, retentionRepo, auditRepo, docService, cfg.Tenants, authz)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
 
 	appHandler := api.NewApplicationHandler(appService)
 	statusHandler := api.NewStatusHandler(statusService)
 	fileHandler := api.NewFileTypeHandler(fileService)
+	typeHandler := api.NewDocumentTypeHandler(typeService)
+	docHandler := api.NewDocumentHandler(docService, cfg.UploadMaxSize, cfg.UploadChunkTimeout, cfg.DownloadWriteTimeout)
+	uploadHandler := api.NewUploadHandler(uploadService, cfg.UploadChunkTimeout)
+	linkHandler := api.NewDownloadLinkHandler(linkService, cfg.DownloadWriteTimeout)
+	exportHandler := api.NewExportHandler(exportService, cfg.ExportWriteTimeout)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeyService)
+	meHandler := api.NewMeHandler()
+	policyHandler := api.NewPolicyHandler(appService)
+	retentionHandler := api.NewRetentionHandler(retentionService)
 	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	r.Use(middleware.LoggerMiddleware())
@@ -84,29 +234,218 @@ func main() {
 		})
 	})
 
-	apiKey := cfg.APIKey
+	authenticators := []auth.Authenticator{auth.NewManagedKeyAuthenticator(apiKeyRepo)}
+	if cfg.APIKey != "" {
+		// The shared key is kept for bootstrapping and existing gateways.
+		authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(cfg.APIKey))
+		log.Println("Shared API_KEY authentication enabled")
+	}
+	if len(cfg.HMACClients) > 0 {
+		clients := make([]auth.HMACClient, 0, len(cfg.HMACClients))
+		for _, c := range cfg.HMACClients {
+			clients = append(clients, auth.HMACClient{ID: c.ID, Secret: []byte(c.Secret), Roles: c.Roles, TenantID: c.TenantID, CrossTenant: c.CrossTenant})
+		}
+		hmacAuth, err := auth.NewHMACAuthenticator(clients, cfg.HMACClockSkew, hmacNonceRepo)
+		if err != nil {
+			log.Fatalf("Invalid HMAC client configuration: %v", err)
+		}
+		authenticators = append(authenticators, hmacAuth)
+		log.Printf("Signed request authentication enabled for %d clients", len(clients))
+	}
+	if cfg.JWKSURL != "" || cfg.JWKSFile != "" {
+		keys, err := auth.NewKeySet(cfg.JWKSURL, cfg.JWKSFile, cfg.JWKSRefresh)
+		if err != nil {
+			log.Fatalf("Failed to load JWKS: %v", err)
+		}
+		jwtAuth, err := auth.NewJWTAuthenticator(auth.JWTConfig{
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			Algorithms:  cfg.JWTAlgorithms,
+			Leeway:      cfg.JWTLeeway,
+			UserIDClaim: cfg.JWTUserIDClaim,
+			RolesClaim:  cfg.JWTRolesClaim,
+			TenantClaim: cfg.JWTTenantClaim,
+		}, keys)
+		if err != nil {
+			log.Fatalf("Invalid JWT configuration: %v", err)
+		}
+		authenticators = append(authenticators, jwtAuth)
+		log.Println("Bearer token authentication enabled")
+	}
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "postgres" {
+		pgStore := ratelimit.NewPostgresStore(db.DB)
+		rateLimitStore = pgStore
+		go func() {
+			for range time.Tick(time.Hour) {
+				if err := pgStore.DeleteIdle(time.Now().Add(-time.Hour)); err != nil {
+					log.Printf("Failed to purge idle rate limit buckets: %v", err)
+				}
+			}
+		}()
+	}
+	rateLimitRoutes := make(map[string]ratelimit.Limit, len(cfg.RateLimitRoutes))
+	for _, route := range cfg.RateLimitRoutes {
+		rateLimitRoutes[route.Route] = ratelimit.PerMinute(route.RequestsPerMinute, route.Burst)
+	}
 
-	log.Printf("API Key configured and is : %s", cfg.APIKey)
+	// Signed download links carry their own credentials, so this route sits
+	// outside the authenticated group.
+	r.GET("/api/v1/downloads/:docId", middleware.RateLimitMiddleware(rateLimitStore, ratelimit.PerMinute(cfg.RateLimitRPM, cfg.RateLimitBurst), rateLimitRoutes), linkHandler.Download)
+
+	// Uploads carry the largest bodies; leave room for their multipart
+	// framing.
+	var idempotencyMaxBody int64
+	if cfg.UploadMaxSize > 0 {
+		idempotencyMaxBody = cfg.UploadMaxSize + 1<<20
+	}
 
 	api := r.Group("/api/v1")
-	api.Use(middleware.APIKeyAuthMiddleware(apiKey))
+	api.Use(middleware.IPRateLimitMiddleware(rateLimitStore, ratelimit.PerMinute(cfg.RateLimitIPRPM, cfg.RateLimitIPBurst)))
+	api.Use(middleware.AuthMiddleware(authenticators...))
+	api.Use(middleware.TenantMiddleware(cfg.Tenants))
+	api.Use(middleware.RateLimitMiddleware(rateLimitStore, ratelimit.PerMinute(cfg.RateLimitRPM, cfg.RateLimitBurst), rateLimitRoutes))
+	api.Use(middleware.IdempotencyMiddleware(idempotencyRepo, cfg.IdempotencyTTL, idempotencyMaxBody, cfg.UploadChunkTimeout))
+
+	api.GET("/me", meHandler.GetMe)
 
 	applications := api.Group("/applications")
 	{
-		applications.POST("", appHandler.CreateApplication)
-		applications.GET("", appHandler.ListApplications)
-		applications.GET("/:id", appHandler.GetApplication)
-		applications.PUT("/:id", appHandler.UpdateApplication)
-		applications.DELETE("/:id", appHandler.DeleteApplication)
+		applications.POST("", middleware.RequirePermission(auth.ScopeApplicationsWrite), appHandler.CreateApplication)
+		applications.GET("", middleware.RequirePermission(auth.ScopeApplicationsRead), appHandler.ListApplications)
+		applications.GET("/:id", middleware.RequirePermission(auth.ScopeApplicationsRead), appHandler.GetApplication)
+		applications.PUT("/:id", middleware.RequirePermission(auth.ScopeApplicationsWrite), appHandler.UpdateApplication)
+		applications.DELETE("/:id", middleware.RequirePermission(auth.ScopeApplicationsDelete), appHandler.DeleteApplication)
+		applications.POST("/:id/merge", middleware.RequirePermission(auth.ScopeApplicationsMerge), appHandler.MergeApplications)
 
-		applications.POST("/:id/status", statusHandler.AddStatus)
-		applications.GET("/:id/statuses", statusHandler.ListStatuses)
+		applications.POST("/:id/status", middleware.RequirePermission(auth.ScopeStatusesWrite), statusHandler.AddStatus)
+		applications.GET("/:id/statuses", middleware.RequirePermission(auth.ScopeStatusesRead), statusHandler.ListStatuses)
 
-		applications.POST("/:id/file-types", fileHandler.AddFileType)
-		applications.GET("/:id/file-types", fileHandler.ListFileTypes)
-		applications.DELETE("/:id/file-types/:fileTypeId", fileHandler.DeleteFileType)
+		applications.POST("/:id/file-types", middleware.RequirePermission(auth.ScopeFileTypesWrite), fileHandler.AddFileType)
+		applications.GET("/:id/file-types", middleware.RequirePermission(auth.ScopeFileTypesRead), fileHandler.ListFileTypes)
+		applications.DELETE("/:id/file-types/:fileTypeId", middleware.RequirePermission(auth.ScopeFileTypesWrite), fileHandler.DeleteFileType)
+
+		applications.POST("/:id/files", middleware.RequirePermission(auth.ScopeDocumentsWrite), docHandler.UploadDocument)
+		applications.GET("/:id/files", middleware.RequirePermission(auth.ScopeDocumentsRead), docHandler.ListDocuments)
+		applications.GET("/:id/files/:fileId", middleware.RequirePermission(auth.ScopeDocumentsRead), docHandler.DownloadDocument)
+		applications.DELETE("/:id/files/:fileId", middleware.RequirePermission(auth.ScopeDocumentsWrite), docHandler.DeleteDocument)
+		applications.POST("/:id/files/:fileId/links", middleware.RequirePermission(auth.ScopeDocumentsRead), linkHandler.CreateDownloadLink)
+		applications.POST("/:id/files/:fileId/rescan", middleware.RequirePermission(auth.ScopeDocumentsScan), docHandler.RescanDocument)
+		applications.GET("/:id/files/:fileId/versions", middleware.RequirePermission(auth.ScopeDocumentsRead), docHandler.ListVersions)
+		applications.POST("/:id/files/:fileId/review", middleware.RequirePermission(auth.ScopeDocumentsReview), docHandler.ReviewDocument)
+		applications.OPTIONS("/:id/uploads", uploadHandler.UploadOptions)
+		applications.POST("/:id/uploads", middleware.RequirePermission(auth.ScopeDocumentsWrite), uploadHandler.CreateUpload)
+		applications.HEAD("/:id/uploads/:uploadId", middleware.RequirePermission(auth.ScopeDocumentsWrite), uploadHandler.HeadUpload)
+		applications.PATCH("/:id/uploads/:uploadId", middleware.RequirePermission(auth.ScopeDocumentsWrite), uploadHandler.PatchUpload)
+		applications.DELETE("/:id/uploads/:uploadId", middleware.RequirePermission(auth.ScopeDocumentsWrite), uploadHandler.DeleteUpload)
+		applications.GET("/:id/storage", middleware.RequirePermission(auth.ScopeDocumentsRead), docHandler.GetStorageUsage)
+		applications.GET("/:id/checklist", middleware.RequirePermission(auth.ScopeDocumentsRead), docHandler.GetChecklist)
+		applications.GET("/:id/documents.zip", middleware.RequirePermission(auth.ScopeDocumentsRead), exportHandler.DownloadApplicationArchive)
+		applications.PUT("/:id/legal-hold", middleware.RequirePermission(auth.ScopeRetentionManage), retentionHandler.PlaceLegalHold)
+		applications.DELETE("/:id/legal-hold", middleware.RequirePermission(auth.ScopeRetentionManage), retentionHandler.ReleaseLegalHold)
+	}
+
+	api.GET("/documents/:id/preview", middleware.RequirePermission(auth.ScopeDocumentsRead), docHandler.GetPreview)
+
+	documentTypes := api.Group("/document-types")
+	{
+		documentTypes.GET("", middleware.RequirePermission(auth.ScopeFileTypesRead), typeHandler.ListDocumentTypes)
+		documentTypes.GET("/:id", middleware.RequirePermission(auth.ScopeFileTypesRead), typeHandler.GetDocumentType)
+		documentTypes.POST("", middleware.RequirePermission(auth.ScopeDocumentTypesManage), typeHandler.CreateDocumentType)
+		documentTypes.PUT("/:id", middleware.RequirePermission(auth.ScopeDocumentTypesManage), typeHandler.UpdateDocumentType)
+		documentTypes.DELETE("/:id", middleware.RequirePermission(auth.ScopeDocumentTypesManage), typeHandler.DeleteDocumentType)
 	}
 
+	exports := api.Group("/exports", middleware.RequirePermission(auth.ScopeDocumentsRead))
+	{
+		exports.POST("", exportHandler.CreateExport)
+		exports.GET("/:id", exportHandler.GetExport)
+		exports.GET("/:id/download", exportHandler.DownloadExport)
+	}
+
+	api.GET("/retention/report", middleware.RequirePermission(auth.ScopeRetentionManage), retentionHandler.GetReport)
+
+	api.POST("/policies/explain", middleware.RequirePermission(auth.ScopePoliciesExplain), policyHandler.Explain)
+
+	apiKeys := api.Group("/admin/api-keys", middleware.RequirePermission(auth.ScopeAPIKeysManage))
+	{
+		apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+		apiKeys.GET("", apiKeyHandler.ListAPIKeys)
+		apiKeys.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+		apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+	}
+
+	// Expired idempotency keys are also replaced lazily on reuse; this just
+	// keeps the table from growing.
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := idempotencyRepo.DeleteExpired(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to purge expired idempotency keys: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := hmacNonceRepo.DeleteExpired(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to purge expired signature nonces: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := auditRepo.DeleteExpiredDownloadLinks(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to purge used download links: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := uploadService.DeleteExpired(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to purge expired uploads: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := exportService.DeleteExpired(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to purge expired exports: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		for range time.Tick(cfg.BlobGCInterval) {
+			if err := docService.CollectGarbage(context.Background(), time.Now(), cfg.BlobGCGrace); err != nil {
+				log.Printf("Failed to delete unused document content: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		// Check at startup too, since the interval is usually long.
+		ticker := time.NewTicker(cfg.DocumentExpiryInterval)
+		defer ticker.Stop()
+		for {
+			if err := expiryChecker.Check(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to check document expiry: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	go func() {
+		for range time.Tick(cfg.RetentionInterval) {
+			if err := retentionService.Purge(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to purge documents past retention: %v", err)
+			}
+		}
+	}()
+
 	// Start server with graceful shutdown
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
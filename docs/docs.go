@@ -15,6 +15,205 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/api-keys": {
+            "get": {
+                "description": "List the managed API keys of the tenant. Secrets are never returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIKeys"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.APIKey"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a managed API key. The secret is only returned in this response.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIKeys"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "API key payload",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/service.APIKeySecretResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/api-keys/{id}": {
+            "delete": {
+                "description": "Revoke a managed API key immediately",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIKeys"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/api-keys/{id}/rotate": {
+            "post": {
+                "description": "Issue a replacement key with the same name and scopes. The old key keeps working for the overlap window.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIKeys"
+                ],
+                "summary": "Rotate an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Rotation payload",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/service.RotateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/service.APIKeySecretResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/applications": {
             "get": {
                 "description": "List applications with filters, pagination and sorting",
@@ -48,10 +247,22 @@ const docTemplate = `{
                     },
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "User ID (ignored for applicants, who only see their own applications)",
                         "name": "userId",
                         "in": "query"
                     },
+                    {
+                        "type": "integer",
+                        "description": "Only applications assigned to this user",
+                        "name": "assigneeId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only applications of this category",
+                        "name": "category",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "default": "created_at",
@@ -77,6 +288,22 @@ const docTemplate = `{
                         "description": "End date YYYY-MM-DD",
                         "name": "to",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only applications flagged (or not) as possible duplicates",
+                        "name": "possibleDuplicate",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "expiring",
+                            "expired"
+                        ],
+                        "type": "string",
+                        "description": "Only applications with documents that are expiring soon or expired",
+                        "name": "documentState",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -86,6 +313,15 @@ const docTemplate = `{
                             "$ref": "#/definitions/service.ListResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -123,6 +359,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/service.CreateApplicationRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries of this request safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -141,6 +383,13 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -179,6 +428,22 @@ const docTemplate = `{
                             "$ref": "#/definitions/domain.Application"
                         }
                     },
+                    "301": {
+                        "description": "Moved Permanently",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -236,6 +501,15 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -257,7 +531,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Soft delete application by ID",
+                "description": "Soft delete application by ID. Applications on legal hold cannot be deleted.",
                 "produces": [
                     "application/json"
                 ],
@@ -284,6 +558,33 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -296,16 +597,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/applications/{id}/file-types": {
+        "/applications/{id}/checklist": {
             "get": {
-                "description": "List all file types for an application",
+                "description": "Report which documents required by the application's category were accepted, await review, were rejected or are missing. Only accepted documents satisfy a requirement.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "ApplicationFileTypes"
+                    "ApplicationDocuments"
                 ],
-                "summary": "List file types",
+                "summary": "Document checklist",
                 "parameters": [
                     {
                         "type": "integer",
@@ -319,25 +620,155 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/domain.ApplicationUploadedFileType"
-                            }
+                            "$ref": "#/definitions/service.Checklist"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
-                    }
-                }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/documents.zip": {
+            "get": {
+                "description": "Stream a ZIP archive of the current version of every document of the application, built on the fly. Documents are in a folder per file type; manifest.json lists each document's checksum and metadata, and the quarantined documents that were left out.",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "DocumentExports"
+                ],
+                "summary": "Download all documents as ZIP",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/file-types": {
+            "get": {
+                "description": "List all file types for an application with their document types",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationFileTypes"
+                ],
+                "summary": "List file types",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.ApplicationUploadedFileType"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
             },
             "post": {
-                "description": "Add a new file type to an application",
+                "description": "Declare that an application takes documents of a catalog document type. Uploads follow the document type's rules.",
                 "consumes": [
                     "application/json"
                 ],
@@ -364,6 +795,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/service.AddFileTypeRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries of this request safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -385,6 +822,33 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -399,7 +863,7 @@ const docTemplate = `{
         },
         "/applications/{id}/file-types/{fileTypeId}": {
             "delete": {
-                "description": "Delete a file type from an application",
+                "description": "Delete a file type from an application. File types with documents cannot be deleted.",
                 "produces": [
                     "application/json"
                 ],
@@ -408,6 +872,13 @@ const docTemplate = `{
                 ],
                 "summary": "Delete file type",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
                     {
                         "type": "integer",
                         "description": "FileType ID",
@@ -426,6 +897,33 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -438,19 +936,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/applications/{id}/status": {
-            "post": {
-                "description": "Add a new status for an application",
-                "consumes": [
-                    "application/json"
-                ],
+        "/applications/{id}/files": {
+            "get": {
+                "description": "List the current version of each document uploaded for an application, with its review decision",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "ApplicationStatus"
+                    "ApplicationDocuments"
                 ],
-                "summary": "Add status to an application",
+                "summary": "List documents",
                 "parameters": [
                     {
                         "type": "integer",
@@ -458,20 +953,20 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Status payload",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/service.AddStatusRequest"
-                        }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.ApplicationDocument"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -479,8 +974,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -498,18 +993,19 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/applications/{id}/statuses": {
-            "get": {
-                "description": "List all statuses for an application with pagination",
+            },
+            "post": {
+                "description": "Upload a file for one of the file types declared on the application. The content is sniffed and must match the allowed MIME types, extensions and size of the file type's catalog document type, as well as the declared content type and file name; archives are unpacked to reject zip bombs. Pass replaces to upload a new version of a document; earlier versions are kept. Uploads that would take the application or its owner over their storage quota are rejected with 413.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "ApplicationStatus"
+                    "ApplicationDocuments"
                 ],
-                "summary": "List statuses of an application",
+                "summary": "Upload a document",
                 "parameters": [
                     {
                         "type": "integer",
@@ -518,159 +1014,3111 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "file",
+                        "description": "Document",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
                     {
                         "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query"
+                        "description": "Declared file type the document belongs to; required unless replaces is given",
+                        "name": "fileTypeId",
+                        "in": "formData"
                     },
                     {
                         "type": "integer",
-                        "default": 20,
-                        "description": "Page size",
-                        "name": "pageSize",
-                        "in": "query"
+                        "description": "ID of the current version of the document the upload replaces",
+                        "name": "replaces",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day the document is valid, YYYY-MM-DD",
+                        "name": "validUntil",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Uploader (staff only; applicants always upload as themselves)",
+                        "name": "userId",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries of this request safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/service.ListResponse"
+                            "$ref": "#/definitions/domain.ApplicationDocument"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
-                    }
-                }
-            }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/files/{fileId}": {
+            "get": {
+                "description": "Download the content of a document. Documents are quarantined until their malware scan is clean.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Download a document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "fileId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a document with all its versions and their stored content. Once any version was reviewed, deleting it needs the documents:delete permission. Documents of applications on legal hold cannot be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Delete a document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "fileId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/files/{fileId}/links": {
+            "post": {
+                "description": "Issue a signed, expiring link to a document that works without API credentials, e.g. in a browser",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Create a download link",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "fileId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Link options",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/service.CreateDownloadLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/service.DownloadLinkResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/files/{fileId}/rescan": {
+            "post": {
+                "description": "Quarantine a document again and queue it for another malware scan",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Rescan a document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "fileId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ApplicationDocument"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/files/{fileId}/review": {
+            "post": {
+                "description": "Accept or reject one version of a document. A rejection needs a reason code (illegible, expired, wrong_document, incomplete, mismatch, or other with a note), which the applicant sees. Only versions scanned clean can be accepted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Review a document version",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document version ID",
+                        "name": "fileId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Decision",
+                        "name": "review",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.ReviewDocumentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ApplicationDocument"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/files/{fileId}/versions": {
+            "get": {
+                "description": "List every version of the document, newest first, with their review decisions",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Document versions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of any version of the document",
+                        "name": "fileId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.ApplicationDocument"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/legal-hold": {
+            "put": {
+                "description": "Keep the application and its documents from being purged by retention or deleted until the hold is released",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Retention"
+                ],
+                "summary": "Place a legal hold",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Legal hold",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.LegalHoldRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Application"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Let retention purge the application again once it is due",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Retention"
+                ],
+                "summary": "Release a legal hold",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Application"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/merge": {
+            "post": {
+                "description": "Absorb duplicate applications into this one. Statuses, file types and documents move to the survivor, conflicting fields are resolved with the given strategy and the absorbed applications redirect to the survivor. Applications on legal hold cannot take part in a merge.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Applications"
+                ],
+                "summary": "Merge applications",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Surviving application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge payload",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.MergeApplicationsRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries of this request safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Application"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/status": {
+            "post": {
+                "description": "Add a new status for an application. Moving to a submit status of the tenant's workflow (\"submitted\" unless configured) requires the documents of the application's category checklist to be uploaded and not rejected; they need not be reviewed yet.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationStatus"
+                ],
+                "summary": "Add status to an application",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Status payload",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.AddStatusRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries of this request safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/statuses": {
+            "get": {
+                "description": "List all statuses for an application with pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationStatus"
+                ],
+                "summary": "List statuses of an application",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ListResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/storage": {
+            "get": {
+                "description": "Report the storage used by the application's documents and by all documents of its owner, counting every version, with the quotas that apply. A limit of 0 means no quota.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Storage usage",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.StorageUsage"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/applications/{id}/uploads": {
+            "post": {
+                "description": "Start a tus upload of a document. Upload-Metadata carries base64 values for fileTypeId (required), filename, filetype (content type), checksum (hex SHA-256 of the whole file), replaces (ID of the document the upload becomes a new version of; fileTypeId may then be left out), validUntil (last day the document is valid, YYYY-MM-DD) and userId (staff only).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentUploads"
+                ],
+                "summary": "Start a resumable upload",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Protocol version, 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Size of the whole file in bytes",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated key and base64 value pairs",
+                        "name": "Upload-Metadata",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.DocumentUpload"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the upload"
+                            },
+                            "Upload-Expires": {
+                                "type": "string",
+                                "description": "When the upload expires unless continued"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "options": {
+                "description": "Report the tus protocol version, extensions, maximum size and checksum algorithms",
+                "tags": [
+                    "DocumentUploads"
+                ],
+                "summary": "Resumable upload capabilities",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/applications/{id}/uploads/{uploadId}": {
+            "delete": {
+                "description": "Terminate a tus upload and remove the chunks received so far. An upload being turned into a document cannot be terminated.",
+                "tags": [
+                    "DocumentUploads"
+                ],
+                "summary": "Abandon an upload",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "uploadId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Protocol version, 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Report how much of a tus upload was received, so the client knows where to resume",
+                "tags": [
+                    "DocumentUploads"
+                ],
+                "summary": "Resumable upload offset",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "uploadId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Protocol version, 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "headers": {
+                            "Upload-Length": {
+                                "type": "int",
+                                "description": "Size of the whole file"
+                            },
+                            "Upload-Offset": {
+                                "type": "int",
+                                "description": "Bytes received"
+                            },
+                            "X-Document-ID": {
+                                "type": "int",
+                                "description": "Document the completed upload became"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Append a chunk to a tus upload at Upload-Offset. The chunk completing the upload turns it into a document, checked like a direct upload; an empty chunk at the end retries that step.",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "tags": [
+                    "DocumentUploads"
+                ],
+                "summary": "Send a chunk",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Application ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "uploadId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Protocol version, 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset the chunk starts at",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Algorithm and base64 checksum of the chunk, e.g. sha256 ...",
+                        "name": "Upload-Checksum",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "Upload-Offset": {
+                                "type": "int",
+                                "description": "Bytes received"
+                            },
+                            "X-Document-ID": {
+                                "type": "int",
+                                "description": "Document the completed upload became"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "460": {
+                        "description": "",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/document-types": {
+            "get": {
+                "description": "List the tenant's catalog of document types",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentTypes"
+                ],
+                "summary": "List document types",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.DocumentType"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add an entry to the tenant's catalog of document types. Applications declare file types by referring to catalog entries.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentTypes"
+                ],
+                "summary": "Create a document type",
+                "parameters": [
+                    {
+                        "description": "Document type",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.CreateDocumentTypeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.DocumentType"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/document-types/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentTypes"
+                ],
+                "summary": "Get a document type",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document type ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.DocumentType"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the display name, description, upload rules and retention of a document type. The key cannot change. The rules apply to uploads from now on.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentTypes"
+                ],
+                "summary": "Update a document type",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document type ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Document type",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.UpdateDocumentTypeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.DocumentType"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a document type from the catalog. Types that applications declare cannot be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentTypes"
+                ],
+                "summary": "Delete a document type",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document type ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/preview": {
+            "get": {
+                "description": "Get a JPEG preview of an image, or of the first page of a scanned PDF; PDF pages of text have no preview. Previews are made in the background once the document is scanned clean; the document's previewStatus tells whether one is ready.",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "ApplicationDocuments"
+                ],
+                "summary": "Preview a document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "small",
+                        "description": "Preview size: small (256 px) or large (1024 px)",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/downloads/{docId}": {
+            "get": {
+                "description": "Download a document with the query parameters of a signed link instead of API credentials. Every download is audited.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Downloads"
+                ],
+                "summary": "Download through a signed link",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "docId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/exports": {
+            "post": {
+                "description": "Queue a ZIP export of the documents of several applications: those listed in applicationIds, or else those matching the filters that the caller may read. The archive has a folder per application and a manifest.json; poll the job until it is done, then download it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentExports"
+                ],
+                "summary": "Start a document export",
+                "parameters": [
+                    {
+                        "description": "Applications to export",
+                        "name": "export",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.CreateExportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ExportJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/exports/{id}": {
+            "get": {
+                "description": "Report the status of an export the caller started",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "DocumentExports"
+                ],
+                "summary": "Get a document export",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Export ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ExportJob"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/exports/{id}/download": {
+            "get": {
+                "description": "Download the archive of a finished export the caller started",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "DocumentExports"
+                ],
+                "summary": "Download a document export",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Export ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/me": {
+            "get": {
+                "description": "Return the authenticated principal, its tenant and its effective permissions",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Current principal",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.MeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/policies/explain": {
+            "post": {
+                "description": "Evaluate the authorization policies for an action on an application and report which rules matched. The principal defaults to the caller; request holds the attributes of the change being made, e.g. {\"status\": \"approved\"}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policies"
+                ],
+                "summary": "Explain a policy decision",
+                "parameters": [
+                    {
+                        "description": "Decision input",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.ExplainPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/policy.Decision"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/retention/report": {
+            "get": {
+                "description": "Dry run of the retention job: list the applications past the retention of the terminal status they closed with, and the documents past the retention of their document type, that a purge at the given day would delete. Nothing is deleted. Entries on legal hold are listed but would be kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Retention"
+                ],
+                "summary": "Retention report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Day of the purge, YYYY-MM-DD; defaults to now",
+                        "name": "at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.RetentionReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "api.MeResponse": {
+            "type": "object",
+            "properties": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "principal": {
+                    "$ref": "#/definitions/auth.Principal"
+                },
+                "tenantId": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.Principal": {
+            "type": "object",
+            "properties": {
+                "crossTenant": {
+                    "description": "CrossTenant marks credentials that may pick the tenant of a request\nwith the X-Tenant-ID header. Other credentials without a tenant are\npinned to the default tenant.",
+                    "type": "boolean"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "scopes": {
+                    "description": "Scopes limits what a credential may do. Nil means the principal is\nnot scope-limited, as with user tokens and the shared API key.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "tenantId": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "rotatedFromId": {
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenantId": {
+                    "type": "string"
+                }
+            }
+        },
         "domain.Application": {
             "type": "object",
             "properties": {
-                "code": {
+                "assigneeId": {
+                    "description": "AssigneeID is the staff member reviewing the application.",
+                    "type": "integer"
+                },
+                "category": {
+                    "description": "Category selects the required-document checklist of the tenant.",
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "documentState": {
+                    "description": "DocumentState is DocumentsExpiring, DocumentsExpired or empty.",
+                    "type": "string"
+                },
+                "fileTypes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ApplicationUploadedFileType"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "legalHold": {
+                    "description": "An application on legal hold is neither purged by retention nor\ndeleted until the hold is released.",
+                    "type": "boolean"
+                },
+                "legalHoldAt": {
+                    "type": "string"
+                },
+                "legalHoldBy": {
+                    "type": "integer"
+                },
+                "legalHoldReason": {
+                    "type": "string"
+                },
+                "mergedAt": {
+                    "type": "string"
+                },
+                "mergedIntoId": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "possibleDuplicate": {
+                    "type": "boolean"
+                },
+                "statuses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ApplicationStatus"
+                    }
+                },
+                "tenantId": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.ApplicationDocument": {
+            "type": "object",
+            "properties": {
+                "applicationId": {
+                    "type": "integer"
+                },
+                "checksum": {
+                    "description": "Checksum is the hex SHA-256 of the content.",
+                    "type": "string"
+                },
+                "contentType": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "fileName": {
+                    "type": "string"
+                },
+                "fileType": {
+                    "$ref": "#/definitions/domain.ApplicationUploadedFileType"
+                },
+                "fileTypeId": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isCurrent": {
+                    "type": "boolean"
+                },
+                "lineageId": {
+                    "type": "integer"
+                },
+                "previewStatus": {
+                    "description": "PreviewKeys are the blob keys of the JPEG previews by size, set once\nPreviewStatus is ready.",
+                    "type": "string"
+                },
+                "previewedAt": {
+                    "description": "PreviewedAt is the time of the last preview attempt.",
+                    "type": "string"
+                },
+                "reviewNote": {
+                    "type": "string"
+                },
+                "reviewReason": {
+                    "type": "string"
+                },
+                "reviewStatus": {
+                    "description": "The review decision on this version. A rejection carries one of the\nReviewReasons and is shown to the applicant.",
+                    "type": "string"
+                },
+                "reviewedAt": {
+                    "type": "string"
+                },
+                "reviewedBy": {
+                    "type": "integer"
+                },
+                "scanSignature": {
+                    "description": "ScanSignature names the malware found in an infected document.",
+                    "type": "string"
+                },
+                "scanStatus": {
+                    "type": "string"
+                },
+                "scannedAt": {
+                    "description": "ScannedAt is the time of the last scan attempt.",
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "tenantId": {
+                    "type": "string"
+                },
+                "uploadedBy": {
+                    "type": "integer"
+                },
+                "validUntil": {
+                    "description": "ValidUntil is the last day the document, e.g. an ID card, is valid.",
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.ApplicationStatus": {
+            "type": "object",
+            "properties": {
+                "applicationId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tenantId": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.ApplicationUploadedFileType": {
+            "type": "object",
+            "properties": {
+                "applicationId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "documentType": {
+                    "$ref": "#/definitions/domain.DocumentType"
+                },
+                "documentTypeId": {
+                    "type": "integer"
+                },
+                "fileTypeName": {
+                    "description": "FileTypeName is the key of the document type.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "tenantId": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.DocumentType": {
+            "type": "object",
+            "properties": {
+                "allowedExtensions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "allowedMimeTypes": {
+                    "description": "AllowedMimeTypes and AllowedExtensions restrict what may be uploaded\nfor this type; empty lists allow anything. MaxSize is in bytes, 0\nmeans the server-wide upload limit.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "key": {
+                    "description": "Key identifies the type, e.g. \"passport\". It cannot change, since\nchecklists and file types refer to it.",
+                    "type": "string"
+                },
+                "maxSize": {
+                    "type": "integer"
+                },
+                "retentionDays": {
+                    "description": "RetentionDays is how long documents of this type are kept once their\napplication closed; 0 keeps them indefinitely.",
+                    "type": "integer"
+                },
+                "tenantId": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.DocumentUpload": {
+            "type": "object",
+            "properties": {
+                "applicationId": {
+                    "type": "integer"
+                },
+                "checksum": {
+                    "description": "Checksum is the hex SHA-256 the client expects for the whole file;\nempty when it did not send one.",
+                    "type": "string"
+                },
+                "contentType": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "documentId": {
+                    "description": "DocumentID is set once the upload is complete.",
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "fileName": {
+                    "type": "string"
+                },
+                "fileTypeId": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "length": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "replacesId": {
+                    "description": "ReplacesID is the document the upload becomes a new version of.",
+                    "type": "integer"
+                },
+                "tenantId": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "uploadedBy": {
+                    "type": "integer"
+                },
+                "validUntil": {
+                    "description": "ValidUntil is passed on to the document.",
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ExportJob": {
+            "type": "object",
+            "properties": {
+                "applicationIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "documentCount": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "requestedBy": {
+                    "description": "RequestedBy is the subject of the principal who requested the job;\nonly they can see and download it.",
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tenantId": {
+                    "type": "string"
+                }
+            }
+        },
+        "policy.Decision": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "type": "boolean"
+                },
+                "applicable": {
+                    "description": "Applicable is false when no rule covers the action; role checks alone\ndecide then.",
+                    "type": "boolean"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/policy.RuleResult"
+                    }
+                }
+            }
+        },
+        "policy.RuleResult": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "effect": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "matched": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "service.APIKeySecretResponse": {
+            "type": "object",
+            "properties": {
+                "apiKey": {
+                    "$ref": "#/definitions/domain.APIKey"
+                },
+                "secret": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.AddFileTypeRequest": {
+            "type": "object",
+            "required": [
+                "documentTypeId"
+            ],
+            "properties": {
+                "documentTypeId": {
+                    "description": "DocumentTypeID is the catalog entry the application takes documents of.",
+                    "type": "integer"
+                }
+            }
+        },
+        "service.AddStatusRequest": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "userId": {
+                    "description": "UserID lets staff record a status for another user. It is ignored\nfor applicants, who always act as themselves.",
+                    "type": "integer"
+                }
+            }
+        },
+        "service.ApplicationPurge": {
+            "type": "object",
+            "properties": {
+                "applicationId": {
+                    "type": "integer"
+                },
+                "category": {
                     "type": "string"
                 },
-                "createdAt": {
+                "closedAt": {
                     "type": "string"
                 },
-                "deletedAt": {
+                "code": {
                     "type": "string"
                 },
-                "description": {
+                "documents": {
+                    "description": "Documents and Size count every version of its documents.",
+                    "type": "integer"
+                },
+                "legalHold": {
+                    "type": "boolean"
+                },
+                "retentionDays": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.Checklist": {
+            "type": "object",
+            "properties": {
+                "applicationId": {
+                    "type": "integer"
+                },
+                "category": {
                     "type": "string"
                 },
-                "fileTypes": {
+                "complete": {
+                    "type": "boolean"
+                },
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/domain.ApplicationUploadedFileType"
+                        "$ref": "#/definitions/service.ChecklistItem"
                     }
                 },
-                "id": {
+                "submittable": {
+                    "description": "Submittable reports whether every item is provided.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "service.ChecklistItem": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count is the number of accepted documents.",
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "fileType": {
+                    "description": "FileType is the document type key, like \"passport\", even where the\ntenant's checklist still names the display name \"Passport\".",
+                    "type": "string"
+                },
+                "max": {
+                    "type": "integer"
+                },
+                "min": {
+                    "type": "integer"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "problem": {
+                    "description": "Problem explains why the item is not satisfied.",
+                    "type": "string"
+                },
+                "provided": {
+                    "description": "Provided reports whether enough documents were accepted or await\nreview, which is what submission needs.",
+                    "type": "boolean"
+                },
+                "rejected": {
                     "type": "integer"
                 },
+                "required": {
+                    "type": "boolean"
+                },
+                "satisfied": {
+                    "description": "Satisfied reports whether enough documents were accepted.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "service.CreateAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "expiresIn": {
+                    "description": "ExpiresIn is a Go duration such as \"720h\". Empty means no expiry.",
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
-                "statuses": {
-                    "description": "Relations",
+                "scopes": {
                     "type": "array",
+                    "minItems": 1,
                     "items": {
-                        "$ref": "#/definitions/domain.ApplicationStatus"
+                        "type": "string"
                     }
+                }
+            }
+        },
+        "service.CreateApplicationRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "category": {
+                    "description": "Category selects the tenant's required-document checklist.",
+                    "type": "string"
                 },
-                "updatedAt": {
+                "name": {
                     "type": "string"
                 },
                 "userId": {
+                    "description": "UserID lets staff create an application for another user. It is\nignored for applicants, who always act as themselves.",
                     "type": "integer"
                 }
             }
         },
-        "domain.ApplicationStatus": {
+        "service.CreateDocumentTypeRequest": {
             "type": "object",
+            "required": [
+                "displayName",
+                "key"
+            ],
             "properties": {
-                "applicationId": {
-                    "type": "integer"
+                "allowedExtensions": {
+                    "description": "AllowedExtensions lists the accepted file name extensions, e.g. \".pdf\".",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "createdAt": {
+                "allowedMimeTypes": {
+                    "description": "AllowedMimeTypes lists the accepted content types, e.g. \"application/pdf\".\nUploads are matched on their sniffed content, not on what the client declares.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "description": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
+                "displayName": {
+                    "type": "string"
                 },
-                "status": {
+                "key": {
+                    "description": "Key identifies the type, e.g. \"passport\": lower-case letters, digits\nand underscores. It cannot be changed later.",
                     "type": "string"
                 },
-                "userId": {
+                "maxSize": {
+                    "description": "MaxSize is the largest accepted upload in bytes; 0 uses the server limit.",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "retentionDays": {
+                    "description": "RetentionDays is how long documents of the type are kept once their\napplication closed; 0 keeps them indefinitely.",
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "service.CreateDownloadLinkRequest": {
+            "type": "object",
+            "properties": {
+                "expiresIn": {
+                    "description": "ExpiresIn is the lifetime of the link in seconds; 0 uses the default.",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "singleUse": {
+                    "description": "SingleUse links work for one request only, so they cannot serve\nrange requests after the first.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "service.CreateExportRequest": {
+            "type": "object",
+            "properties": {
+                "applicationIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "assigneeId": {
                     "type": "integer"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "q": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
                 }
             }
         },
-        "domain.ApplicationUploadedFileType": {
+        "service.DocumentPurge": {
             "type": "object",
             "properties": {
                 "applicationId": {
                     "type": "integer"
                 },
-                "createdAt": {
+                "closedAt": {
                     "type": "string"
                 },
-                "fileTypeName": {
+                "documentId": {
+                    "type": "integer"
+                },
+                "documentType": {
                     "type": "string"
                 },
-                "id": {
+                "legalHold": {
+                    "type": "boolean"
+                },
+                "lineageId": {
+                    "type": "integer"
+                },
+                "retentionDays": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "uploadedAt": {
+                    "type": "string"
+                },
+                "version": {
                     "type": "integer"
                 }
             }
         },
-        "service.AddFileTypeRequest": {
+        "service.DownloadLinkResponse": {
             "type": "object",
-            "required": [
-                "fileTypeName"
-            ],
             "properties": {
-                "fileTypeName": {
+                "expiresAt": {
+                    "type": "string"
+                },
+                "singleUse": {
+                    "type": "boolean"
+                },
+                "url": {
                     "type": "string"
                 }
             }
         },
-        "service.AddStatusRequest": {
+        "service.ExplainPolicyRequest": {
             "type": "object",
             "required": [
-                "status",
-                "userId"
+                "action",
+                "applicationId"
             ],
             "properties": {
-                "status": {
+                "action": {
                     "type": "string"
                 },
-                "userId": {
+                "applicationId": {
                     "type": "integer"
+                },
+                "principal": {
+                    "$ref": "#/definitions/auth.Principal"
+                },
+                "request": {
+                    "type": "object",
+                    "additionalProperties": true
                 }
             }
         },
-        "service.CreateApplicationRequest": {
+        "service.LegalHoldRequest": {
             "type": "object",
             "required": [
-                "name",
-                "userId"
+                "reason"
             ],
             "properties": {
-                "name": {
+                "reason": {
+                    "description": "Reason records why, e.g. the case the application is evidence in.",
                     "type": "string"
-                },
-                "userId": {
-                    "type": "integer"
                 }
             }
         },
@@ -683,6 +4131,32 @@ const docTemplate = `{
                 }
             }
         },
+        "service.MergeApplicationsRequest": {
+            "type": "object",
+            "required": [
+                "sourceIds"
+            ],
+            "properties": {
+                "fields": {
+                    "description": "Fields overrides Strategy per field (name, description).",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "sourceIds": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "strategy": {
+                    "description": "Strategy resolves conflicting scalar fields: target, newest, oldest or longest.",
+                    "type": "string"
+                }
+            }
+        },
         "service.PaginationMeta": {
             "type": "object",
             "properties": {
@@ -700,9 +4174,101 @@ const docTemplate = `{
                 }
             }
         },
+        "service.QuotaUsage": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "used": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.RetentionReport": {
+            "type": "object",
+            "properties": {
+                "applications": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ApplicationPurge"
+                    }
+                },
+                "at": {
+                    "type": "string"
+                },
+                "documents": {
+                    "description": "Documents are purged ahead of their application under the retention\nof their document type. Documents of listed applications are left\nout.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.DocumentPurge"
+                    }
+                },
+                "truncated": {
+                    "description": "Truncated is set when more is due than the report lists.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "service.ReviewDocumentRequest": {
+            "type": "object",
+            "required": [
+                "decision"
+            ],
+            "properties": {
+                "decision": {
+                    "type": "string",
+                    "enum": [
+                        "accepted",
+                        "rejected"
+                    ]
+                },
+                "note": {
+                    "description": "Note is shown to the applicant; required for reason \"other\".",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Reason is the code of a rejection: illegible, expired,\nwrong_document, incomplete, mismatch or other.",
+                    "type": "string"
+                }
+            }
+        },
+        "service.RotateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "overlap": {
+                    "description": "Overlap is how long the old key keeps working, as a Go duration.\nDefaults to 24h; \"0s\" revokes it immediately.",
+                    "type": "string"
+                }
+            }
+        },
+        "service.StorageUsage": {
+            "type": "object",
+            "properties": {
+                "application": {
+                    "$ref": "#/definitions/service.QuotaUsage"
+                },
+                "applicationId": {
+                    "type": "integer"
+                },
+                "user": {
+                    "$ref": "#/definitions/service.QuotaUsage"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
         "service.UpdateApplicationRequest": {
             "type": "object",
             "properties": {
+                "assigneeId": {
+                    "description": "AssigneeID assigns the application to a staff member; 0 clears the\nassignment. Applicants cannot set it.",
+                    "type": "integer"
+                },
+                "category": {
+                    "type": "string"
+                },
                 "code": {
                     "type": "string"
                 },
@@ -713,6 +4279,44 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "service.UpdateDocumentTypeRequest": {
+            "type": "object",
+            "required": [
+                "displayName"
+            ],
+            "properties": {
+                "allowedExtensions": {
+                    "description": "AllowedExtensions lists the accepted file name extensions, e.g. \".pdf\".",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "allowedMimeTypes": {
+                    "description": "AllowedMimeTypes lists the accepted content types, e.g. \"application/pdf\".\nUploads are matched on their sniffed content, not on what the client declares.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "maxSize": {
+                    "description": "MaxSize is the largest accepted upload in bytes; 0 uses the server limit.",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "retentionDays": {
+                    "description": "RetentionDays is how long documents of the type are kept once their\napplication closed; 0 keeps them indefinitely.",
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -720,11 +4324,27 @@ const docTemplate = `{
             "type": "apiKey",
             "name": "X-API-Key",
             "in": "header"
+        },
+        "BearerAuth": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        },
+        "HMACAuth": {
+            "type": "apiKey",
+            "name": "X-Signature",
+            "in": "header"
         }
     },
     "security": [
         {
             "ApiKeyAuth": []
+        },
+        {
+            "BearerAuth": []
+        },
+        {
+            "HMACAuth": []
         }
     ]
 }`
@@ -736,7 +4356,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/api/v1",
 	Schemes:          []string{"http"},
 	Title:            "Application Service API",
-	Description:      "API for managing applications, statuses and file types",
+	Description:      "API for managing applications, statuses, file types and documents.\nRequests are scoped to the tenant bound to the credential. Only cross-tenant credentials (the shared API key and HMAC clients configured as cross-tenant) may name one in the X-Tenant-ID header; other credentials without a tenant use the default tenant.\nA gateway holding the API key may act for an end user by sending X-User-ID and X-User-Roles; applicants only see their own applications.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// APIKey is a managed API key. Only a SHA-256 hash of the secret is stored;
+// Prefix is the non-secret part used to find the row.
+type APIKey struct {
+	ID            uint64     `gorm:"primaryKey;column:id" json:"id"`
+	TenantID      string     `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
+	Name          string     `gorm:"column:name;size:255;not null" json:"name"`
+	Prefix        string     `gorm:"column:prefix;size:32;not null;uniqueIndex" json:"prefix"`
+	KeyHash       string     `gorm:"column:key_hash;size:64;not null" json:"-"`
+	Scopes        []string   `gorm:"column:scopes;type:jsonb;serializer:json;not null" json:"scopes"`
+	ExpiresAt     *time.Time `gorm:"column:expires_at" json:"expiresAt,omitempty"`
+	LastUsedAt    *time.Time `gorm:"column:last_used_at" json:"lastUsedAt,omitempty"`
+	RevokedAt     *time.Time `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+	RotatedFromID *uint64    `gorm:"column:rotated_from_id" json:"rotatedFromId,omitempty"`
+	CreatedBy     string     `gorm:"column:created_by;size:255;not null" json:"createdBy"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Active reports whether the key may still be used at the given time.
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
@@ -2,12 +2,37 @@ package domain
 
 import "time"
 
+// Document states of an application, set by the expiry check while one of
+// its current documents expired or is about to.
+const (
+	DocumentsExpiring = "expiring"
+	DocumentsExpired  = "expired"
+)
+
 type Application struct {
 	ID          uint64 `gorm:"primaryKey;column:id" json:"id"`
+	TenantID    string `gorm:"column:tenant_id;size:64;not null;uniqueIndex:uq_applications_tenant_code,priority:1" json:"tenantId"`
 	UserID      uint64 `gorm:"column:user_id;not null;index" json:"userId"`
 	Name        string `gorm:"column:name;size:255;not null" json:"name"`
 	Description string `gorm:"column:description" json:"description"`
-	Code        string `gorm:"column:code;size:50;not null;uniqueIndex" json:"code"`
+	Code        string `gorm:"column:code;size:50;not null;uniqueIndex:uq_applications_tenant_code,priority:2" json:"code"`
+	// Category selects the required-document checklist of the tenant.
+	Category string `gorm:"column:category;size:100;not null;default:'';index" json:"category"`
+
+	// AssigneeID is the staff member reviewing the application.
+	AssigneeID *uint64 `gorm:"column:assignee_id;index" json:"assigneeId,omitempty"`
+
+	PossibleDuplicate bool       `gorm:"column:possible_duplicate;not null;default:false" json:"possibleDuplicate"`
+	MergedIntoID      *uint64    `gorm:"column:merged_into_id;index" json:"mergedIntoId,omitempty"`
+	MergedAt          *time.Time `gorm:"column:merged_at" json:"mergedAt,omitempty"`
+	// DocumentState is DocumentsExpiring, DocumentsExpired or empty.
+	DocumentState string `gorm:"column:document_state;size:20;not null;default:''" json:"documentState,omitempty"`
+	// An application on legal hold is neither purged by retention nor
+	// deleted until the hold is released.
+	LegalHold       bool       `gorm:"column:legal_hold;not null;default:false" json:"legalHold"`
+	LegalHoldReason string     `gorm:"column:legal_hold_reason;not null;default:''" json:"legalHoldReason,omitempty"`
+	LegalHoldBy     *uint64    `gorm:"column:legal_hold_by" json:"legalHoldBy,omitempty"`
+	LegalHoldAt     *time.Time `gorm:"column:legal_hold_at" json:"legalHoldAt,omitempty"`
 
 	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
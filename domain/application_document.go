@@ -0,0 +1,95 @@
+package domain
+
+import "time"
+
+// Scan statuses of a document. Documents are quarantined, and cannot be
+// downloaded, until their scan comes back clean.
+const (
+	ScanPending  = "pending"
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+)
+
+// Preview statuses of a document. Previews are made of images and of the
+// first page of scanned PDFs once the document is scanned clean; other
+// content, including PDF pages of text, is unsupported.
+const (
+	PreviewPending     = "pending"
+	PreviewReady       = "ready"
+	PreviewFailed      = "failed"
+	PreviewUnsupported = "unsupported"
+)
+
+// PreviewSizes are the preview sizes with the longest side, in pixels, of
+// each. Previews are never larger than the original.
+var PreviewSizes = map[string]int{
+	"small": 256,
+	"large": 1024,
+}
+
+// Review decisions on a document version.
+const (
+	ReviewPending  = "pending"
+	ReviewAccepted = "accepted"
+	ReviewRejected = "rejected"
+)
+
+// ReviewReasons are the reason codes a rejection may give, with the
+// explanation shown to the applicant.
+var ReviewReasons = map[string]string{
+	"illegible":      "The document cannot be read",
+	"expired":        "The document has expired",
+	"wrong_document": "This is not the requested document",
+	"incomplete":     "Pages or parts of the document are missing",
+	"mismatch":       "The document does not match the application details",
+	"other":          "See the reviewer's note",
+}
+
+// ApplicationDocument is one version of a file uploaded for one of the file
+// types declared on an application. The bytes live in the blob store under
+// StorageKey. Uploading a replacement adds a version; the versions of a
+// document share LineageID, the ID of the first one, and only the newest is
+// current.
+type ApplicationDocument struct {
+	ID            uint64 `gorm:"primaryKey;column:id" json:"id"`
+	TenantID      string `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
+	ApplicationID uint64 `gorm:"column:application_id;not null;index" json:"applicationId"`
+	FileTypeID    uint64 `gorm:"column:file_type_id;not null;index" json:"fileTypeId"`
+	FileName      string `gorm:"column:file_name;size:255;not null" json:"fileName"`
+	ContentType   string `gorm:"column:content_type;size:255;not null" json:"contentType"`
+	Size          int64  `gorm:"column:size;not null" json:"size"`
+	// Checksum is the hex SHA-256 of the content.
+	Checksum   string `gorm:"column:checksum;size:64;not null" json:"checksum"`
+	StorageKey string `gorm:"column:storage_key;size:512;not null" json:"-"`
+	UploadedBy uint64 `gorm:"column:uploaded_by;not null" json:"uploadedBy"`
+	ScanStatus string `gorm:"column:scan_status;size:20;not null;default:pending" json:"scanStatus"`
+	// ScanSignature names the malware found in an infected document.
+	ScanSignature string `gorm:"column:scan_signature;size:255;not null;default:''" json:"scanSignature,omitempty"`
+	// ScannedAt is the time of the last scan attempt.
+	ScannedAt *time.Time `gorm:"column:scanned_at" json:"scannedAt,omitempty"`
+	// ValidUntil is the last day the document, e.g. an ID card, is valid.
+	ValidUntil *time.Time `gorm:"column:valid_until;type:date" json:"validUntil,omitempty"`
+	LineageID  uint64     `gorm:"column:lineage_id;not null" json:"lineageId"`
+	Version    int        `gorm:"column:version;not null;default:1" json:"version"`
+	IsCurrent  bool       `gorm:"column:is_current;not null;default:true" json:"isCurrent"`
+	// The review decision on this version. A rejection carries one of the
+	// ReviewReasons and is shown to the applicant.
+	ReviewStatus string     `gorm:"column:review_status;size:20;not null;default:pending" json:"reviewStatus"`
+	ReviewReason string     `gorm:"column:review_reason;size:50;not null;default:''" json:"reviewReason,omitempty"`
+	ReviewNote   string     `gorm:"column:review_note;not null;default:''" json:"reviewNote,omitempty"`
+	ReviewedBy   *uint64    `gorm:"column:reviewed_by" json:"reviewedBy,omitempty"`
+	ReviewedAt   *time.Time `gorm:"column:reviewed_at" json:"reviewedAt,omitempty"`
+	// PreviewKeys are the blob keys of the JPEG previews by size, set once
+	// PreviewStatus is ready.
+	PreviewStatus string            `gorm:"column:preview_status;size:20;not null;default:pending" json:"previewStatus"`
+	PreviewKeys   map[string]string `gorm:"column:preview_keys;serializer:json;not null" json:"-"`
+	// PreviewedAt is the time of the last preview attempt.
+	PreviewedAt *time.Time `gorm:"column:previewed_at" json:"previewedAt,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+
+	FileType *ApplicationUploadedFileType `gorm:"foreignKey:FileTypeID" json:"fileType,omitempty"`
+}
+
+func (ApplicationDocument) TableName() string {
+	return "application_documents"
+}
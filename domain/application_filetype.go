@@ -2,13 +2,19 @@ package domain
 
 import "time"
 
+// ApplicationUploadedFileType declares that an application takes documents
+// of a catalog document type.
 type ApplicationUploadedFileType struct {
-	ID            uint64    `gorm:"primaryKey;column:id" json:"id"`
-	ApplicationID uint64    `gorm:"column:application_id;not null;index" json:"applicationId"`
-	FileTypeName  string    `gorm:"column:file_type_name;size:100;not null" json:"fileTypeName"`
-	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	ID             uint64 `gorm:"primaryKey;column:id" json:"id"`
+	TenantID       string `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
+	ApplicationID  uint64 `gorm:"column:application_id;not null;index" json:"applicationId"`
+	DocumentTypeID uint64 `gorm:"column:document_type_id;not null;index" json:"documentTypeId"`
+	// FileTypeName is the key of the document type.
+	FileTypeName string    `gorm:"column:file_type_name;size:100;not null" json:"fileTypeName"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
 
-	Application Application `gorm:"foreignKey:ApplicationID" json:"-"`
+	Application  Application   `gorm:"foreignKey:ApplicationID" json:"-"`
+	DocumentType *DocumentType `gorm:"foreignKey:DocumentTypeID" json:"documentType,omitempty"`
 }
 
 func (ApplicationUploadedFileType) TableName() string {
@@ -4,6 +4,7 @@ import "time"
 
 type ApplicationStatus struct {
 	ID            uint64    `gorm:"primaryKey;column:id" json:"id"`
+	TenantID      string    `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
 	ApplicationID uint64    `gorm:"column:application_id;not null;index" json:"applicationId"`
 	UserID        uint64    `gorm:"column:user_id;not null;index" json:"userId"`
 	Status        string    `gorm:"column:status;size:50;not null" json:"status"`
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// AuditEvent records an access to or change of tenant data.
+type AuditEvent struct {
+	ID       uint64 `gorm:"primaryKey;column:id" json:"id"`
+	TenantID string `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
+	// Action is named like the policy actions, e.g. "documents:download".
+	Action        string  `gorm:"column:action;size:100;not null" json:"action"`
+	Actor         string  `gorm:"column:actor;size:255;not null" json:"actor"`
+	ApplicationID *uint64 `gorm:"column:application_id" json:"applicationId,omitempty"`
+	DocumentID    *uint64 `gorm:"column:document_id" json:"documentId,omitempty"`
+	IP            string  `gorm:"column:ip;size:64;not null" json:"ip"`
+	UserAgent     string  `gorm:"column:user_agent;size:512;not null" json:"userAgent"`
+	// Details holds action specific data.
+	Details   map[string]interface{} `gorm:"column:details;serializer:json;not null" json:"details,omitempty"`
+	CreatedAt time.Time              `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// DownloadLinkUse marks a single-use download link as used.
+type DownloadLinkUse struct {
+	Nonce     string    `gorm:"primaryKey;column:nonce;size:32"`
+	TenantID  string    `gorm:"column:tenant_id;size:64;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+	UsedAt    time.Time `gorm:"column:used_at;autoCreateTime"`
+}
+
+func (DownloadLinkUse) TableName() string {
+	return "download_link_uses"
+}
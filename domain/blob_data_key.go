@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// BlobDataKey is the key one encrypted blob was encrypted with, wrapped by
+// the master key MasterKeyID.
+type BlobDataKey struct {
+	ID          string     `gorm:"primaryKey;column:id;size:32" json:"id"`
+	StorageKey  string     `gorm:"column:storage_key;size:512;not null" json:"-"`
+	MasterKeyID string     `gorm:"column:master_key_id;size:64;not null" json:"masterKeyId"`
+	WrappedKey  []byte     `gorm:"column:wrapped_key;not null" json:"-"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	RewrappedAt *time.Time `gorm:"column:rewrapped_at" json:"rewrappedAt,omitempty"`
+}
+
+func (BlobDataKey) TableName() string {
+	return "blob_data_keys"
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// DocumentBlob is document content stored once per tenant and checksum,
+// however many document versions hold it. RefCount counts those versions;
+// a blob without references since UnreferencedAt is garbage. Blobs left
+// over from before deduplication have no checksum.
+type DocumentBlob struct {
+	ID             uint64     `gorm:"primaryKey;column:id" json:"id"`
+	TenantID       string     `gorm:"column:tenant_id;size:64;not null" json:"tenantId"`
+	Checksum       *string    `gorm:"column:checksum;size:64" json:"checksum,omitempty"`
+	StorageKey     string     `gorm:"column:storage_key;size:512;not null" json:"-"`
+	Size           int64      `gorm:"column:size;not null" json:"size"`
+	RefCount       int        `gorm:"column:ref_count;not null;default:0" json:"refCount"`
+	Stored         bool       `gorm:"column:stored;not null;default:false" json:"stored"`
+	UnreferencedAt *time.Time `gorm:"column:unreferenced_at" json:"unreferencedAt,omitempty"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (DocumentBlob) TableName() string {
+	return "document_blobs"
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// DocumentType is an entry of a tenant's catalog of document types. File
+// types declared on applications refer to one, so documents can be reported
+// on across applications, and its upload rules apply to all of them.
+type DocumentType struct {
+	ID       uint64 `gorm:"primaryKey;column:id" json:"id"`
+	TenantID string `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
+	// Key identifies the type, e.g. "passport". It cannot change, since
+	// checklists and file types refer to it.
+	Key         string `gorm:"column:key;size:100;not null" json:"key"`
+	DisplayName string `gorm:"column:display_name;size:255;not null" json:"displayName"`
+	Description string `gorm:"column:description;not null;default:''" json:"description"`
+	// AllowedMimeTypes and AllowedExtensions restrict what may be uploaded
+	// for this type; empty lists allow anything. MaxSize is in bytes, 0
+	// means the server-wide upload limit.
+	AllowedMimeTypes  []string `gorm:"column:allowed_mime_types;serializer:json;not null" json:"allowedMimeTypes"`
+	AllowedExtensions []string `gorm:"column:allowed_extensions;serializer:json;not null" json:"allowedExtensions"`
+	MaxSize           int64    `gorm:"column:max_size;not null;default:0" json:"maxSize"`
+	// RetentionDays is how long documents of this type are kept once their
+	// application closed; 0 keeps them indefinitely.
+	RetentionDays int       `gorm:"column:retention_days;not null;default:0" json:"retentionDays"`
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (DocumentType) TableName() string {
+	return "document_types"
+}
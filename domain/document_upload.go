@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// DocumentUpload is a resumable upload in progress. Each received chunk is
+// kept as a blob under one of PartKeys; once Offset reaches Length the parts
+// are joined into an ApplicationDocument.
+type DocumentUpload struct {
+	ID            string `gorm:"primaryKey;column:id;size:32" json:"id"`
+	TenantID      string `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
+	ApplicationID uint64 `gorm:"column:application_id;not null;index" json:"applicationId"`
+	FileTypeID    uint64 `gorm:"column:file_type_id;not null" json:"fileTypeId"`
+	FileName      string `gorm:"column:file_name;size:255;not null" json:"fileName"`
+	ContentType   string `gorm:"column:content_type;size:255;not null" json:"contentType"`
+	Length        int64  `gorm:"column:length;not null" json:"length"`
+	Offset        int64  `gorm:"column:upload_offset;not null" json:"offset"`
+	// Checksum is the hex SHA-256 the client expects for the whole file;
+	// empty when it did not send one.
+	Checksum   string   `gorm:"column:checksum;size:64;not null" json:"checksum,omitempty"`
+	PartKeys   []string `gorm:"column:part_keys;serializer:json;not null" json:"-"`
+	UploadedBy uint64   `gorm:"column:uploaded_by;not null" json:"uploadedBy"`
+	// ReplacesID is the document the upload becomes a new version of.
+	ReplacesID *uint64 `gorm:"column:replaces_id" json:"replacesId,omitempty"`
+	// ValidUntil is passed on to the document.
+	ValidUntil *time.Time `gorm:"column:valid_until;type:date" json:"validUntil,omitempty"`
+	// DocumentID is set once the upload is complete.
+	DocumentID *uint64 `gorm:"column:document_id" json:"documentId,omitempty"`
+	// FinalizingUntil is set while a request turns the upload into a
+	// document.
+	FinalizingUntil *time.Time `gorm:"column:finalizing_until" json:"-"`
+	ExpiresAt       time.Time  `gorm:"column:expires_at;not null" json:"expiresAt"`
+	CreatedAt       time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (DocumentUpload) TableName() string {
+	return "document_uploads"
+}
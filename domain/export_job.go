@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// Export job statuses.
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// ExportJob builds a ZIP archive of the documents of several applications
+// in the background. The applications are fixed when the job is requested;
+// the finished archive is kept in the blob store until ExpiresAt.
+type ExportJob struct {
+	ID       uint64 `gorm:"primaryKey;column:id" json:"id"`
+	TenantID string `gorm:"column:tenant_id;size:64;not null;index" json:"tenantId"`
+	// RequestedBy is the subject of the principal who requested the job;
+	// only they can see and download it.
+	RequestedBy    string     `gorm:"column:requested_by;size:255;not null" json:"requestedBy"`
+	ApplicationIDs []uint64   `gorm:"column:application_ids;serializer:json;not null" json:"applicationIds"`
+	Status         string     `gorm:"column:status;size:20;not null;default:pending" json:"status"`
+	Error          string     `gorm:"column:error;not null;default:''" json:"error,omitempty"`
+	DocumentCount  int        `gorm:"column:document_count;not null;default:0" json:"documentCount"`
+	Size           int64      `gorm:"column:size;not null;default:0" json:"size"`
+	StorageKey     string     `gorm:"column:storage_key;size:512;not null;default:''" json:"-"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	StartedAt      *time.Time `gorm:"column:started_at" json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `gorm:"column:finished_at" json:"finishedAt,omitempty"`
+	ExpiresAt      *time.Time `gorm:"column:expires_at" json:"expiresAt,omitempty"`
+}
+
+func (ExportJob) TableName() string {
+	return "export_jobs"
+}
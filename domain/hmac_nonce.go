@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// HMACNonce marks the nonce of a signed request as used.
+type HMACNonce struct {
+	ClientID  string    `gorm:"primaryKey;column:client_id;size:255"`
+	Nonce     string    `gorm:"primaryKey;column:nonce;size:128"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+	UsedAt    time.Time `gorm:"column:used_at;autoCreateTime"`
+}
+
+func (HMACNonce) TableName() string {
+	return "hmac_nonces"
+}
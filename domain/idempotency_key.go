@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// IdempotencyKey records a POST request made with an Idempotency-Key header
+// and, once it has completed, the response to replay for retries.
+type IdempotencyKey struct {
+	TenantID     string    `gorm:"primaryKey;column:tenant_id;size:64" json:"tenantId"`
+	Principal    string    `gorm:"primaryKey;column:principal;size:255" json:"principal"`
+	Key          string    `gorm:"primaryKey;column:key;size:255" json:"key"`
+	Fingerprint  string    `gorm:"column:fingerprint;size:64;not null" json:"fingerprint"`
+	Completed    bool      `gorm:"column:completed;not null;default:false" json:"completed"`
+	StatusCode   int       `gorm:"column:status_code" json:"statusCode"`
+	ContentType  string    `gorm:"column:content_type;size:255" json:"contentType"`
+	ResponseBody []byte    `gorm:"column:response_body" json:"-"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;not null;index" json:"expiresAt"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	keyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(keyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{keyService: keyService}
+}
+
+// @Summary Create an API key
+// @Description Create a managed API key. The secret is only returned in this response.
+// @Tags APIKeys
+// @Accept json
+// @Produce json
+// @Param input body service.CreateAPIKeyRequest true "API key payload"
+// @Success 201 {object} service.APIKeySecretResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req service.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.keyService.Create(c.Request.Context(), req)
+	if err != nil {
+		writeAPIKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// @Summary List API keys
+// @Description List the managed API keys of the tenant. Secrets are never returned.
+// @Tags APIKeys
+// @Produce json
+// @Success 200 {array} domain.APIKey
+// @Failure 500 {object} map[string]string
+// @Router /admin/api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.keyService.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary Rotate an API key
+// @Description Issue a replacement key with the same name and scopes. The old key keeps working for the overlap window.
+// @Tags APIKeys
+// @Accept json
+// @Produce json
+// @Param id path int true "API key ID"
+// @Param input body service.RotateAPIKeyRequest false "Rotation payload"
+// @Success 201 {object} service.APIKeySecretResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req service.RotateAPIKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	resp, err := h.keyService.Rotate(c.Request.Context(), id, req)
+	if err != nil {
+		writeAPIKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// @Summary Revoke an API key
+// @Description Revoke a managed API key immediately
+// @Tags APIKeys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err := h.keyService.Revoke(c.Request.Context(), id); err != nil {
+		writeAPIKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "revoked"})
+}
+
+func writeAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidAPIKeyReq):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
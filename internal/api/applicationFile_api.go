@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -18,14 +19,18 @@ func NewFileTypeHandler(fileService *service.ApplicationFileTypeService) *FileTy
 }
 
 // @Summary Add file type to an application
-// @Description Add a new file type to an application
+// @Description Declare that an application takes documents of a catalog document type. Uploads follow the document type's rules.
 // @Tags ApplicationFileTypes
 // @Accept json
 // @Produce json
 // @Param id path int true "Application ID"
 // @Param input body service.AddFileTypeRequest true "File type payload"
+// @Param Idempotency-Key header string false "Makes retries of this request safe"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications/{id}/file-types [post]
 func (h *FileTypeHandler) AddFileType(c *gin.Context) {
@@ -36,7 +41,23 @@ func (h *FileTypeHandler) AddFileType(c *gin.Context) {
 		return
 	}
 
-	if err := h.fileService.Add(appID, req.FileTypeName); err != nil {
+	if err := h.fileService.Add(c.Request.Context(), appID, req); err != nil {
+		if errors.Is(err, service.ErrDocumentTypeNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrApplicationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrFileTypeExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -44,16 +65,33 @@ func (h *FileTypeHandler) AddFileType(c *gin.Context) {
 }
 
 // @Summary Delete file type
-// @Description Delete a file type from an application
+// @Description Delete a file type from an application. File types with documents cannot be deleted.
 // @Tags ApplicationFileTypes
 // @Produce json
+// @Param id path int true "Application ID"
 // @Param fileTypeId path int true "FileType ID"
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications/{id}/file-types/{fileTypeId} [delete]
 func (h *FileTypeHandler) DeleteFileType(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 	fileTypeID, _ := strconv.ParseUint(c.Param("fileTypeId"), 10, 64)
-	if err := h.fileService.Delete(fileTypeID); err != nil {
+	if err := h.fileService.Delete(c.Request.Context(), appID, fileTypeID); err != nil {
+		if errors.Is(err, service.ErrApplicationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrFileTypeInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -61,17 +99,27 @@ func (h *FileTypeHandler) DeleteFileType(c *gin.Context) {
 }
 
 // @Summary List file types
-// @Description List all file types for an application
+// @Description List all file types for an application with their document types
 // @Tags ApplicationFileTypes
 // @Produce json
 // @Param id path int true "Application ID"
 // @Success 200 {array} domain.ApplicationUploadedFileType
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications/{id}/file-types [get]
 func (h *FileTypeHandler) ListFileTypes(c *gin.Context) {
 	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
-	fileTypes, err := h.fileService.List(appID)
+	fileTypes, err := h.fileService.List(c.Request.Context(), appID)
 	if err != nil {
+		if errors.Is(err, service.ErrApplicationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"path"
 	"strconv"
 	"time"
 
@@ -25,8 +27,10 @@ func NewApplicationHandler(appService *service.ApplicationService) *ApplicationH
 // @Accept json
 // @Produce json
 // @Param input body service.CreateApplicationRequest true "Application payload"
+// @Param Idempotency-Key header string false "Makes retries of this request safe"
 // @Success 201 {object} domain.Application
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]string
 // @Router /applications [post]
 // @Security ApiKeyAuth
@@ -38,11 +42,21 @@ func (h *ApplicationHandler) CreateApplication(c *gin.Context) {
 	}
 
 	app := &domain.Application{
-		Name:   req.Name,
-		UserID: req.UserID,
+		Name:     req.Name,
+		Category: req.Category,
+		UserID:   req.UserID,
 	}
 
-	if err := h.appService.Create(app); err != nil {
+	if err := h.appService.Create(c.Request.Context(), app); err != nil {
+		var dupErr *service.DuplicateError
+		if errors.As(err, &dupErr) {
+			c.JSON(http.StatusConflict, gin.H{"error": dupErr.Error(), "duplicates": dupErr.Candidates})
+			return
+		}
+		if errors.Is(err, service.ErrUserRequired) || errors.Is(err, service.ErrUnknownCategory) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -55,15 +69,69 @@ func (h *ApplicationHandler) CreateApplication(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Application ID"
 // @Success 200 {object} domain.Application
+// @Failure 301 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /applications/{id} [get]
 func (h *ApplicationHandler) GetApplication(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
-	app, err := h.appService.GetByID(id)
+	app, err := h.appService.GetByID(c.Request.Context(), id)
+	if errors.Is(err, service.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "application not found"})
 		return
 	}
+	if app.MergedIntoID != nil {
+		survivor := strconv.FormatUint(*app.MergedIntoID, 10)
+		c.Header("Location", path.Join(path.Dir(c.Request.URL.Path), survivor))
+		c.JSON(http.StatusMovedPermanently, gin.H{"error": "application was merged", "mergedIntoId": *app.MergedIntoID})
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// @Summary Merge applications
+// @Description Absorb duplicate applications into this one. Statuses, file types and documents move to the survivor, conflicting fields are resolved with the given strategy and the absorbed applications redirect to the survivor. Applications on legal hold cannot take part in a merge.
+// @Tags Applications
+// @Accept json
+// @Produce json
+// @Param id path int true "Surviving application ID"
+// @Param input body service.MergeApplicationsRequest true "Merge payload"
+// @Param Idempotency-Key header string false "Makes retries of this request safe"
+// @Success 200 {object} domain.Application
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/merge [post]
+func (h *ApplicationHandler) MergeApplications(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req service.MergeApplicationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	app, err := h.appService.Merge(c.Request.Context(), id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrApplicationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidMerge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrLegalHold):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
 	c.JSON(http.StatusOK, app)
 }
 
@@ -76,6 +144,7 @@ func (h *ApplicationHandler) GetApplication(c *gin.Context) {
 // @Param input body service.UpdateApplicationRequest true "Update payload"
 // @Success 200 {object} domain.Application
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications/{id} [put]
@@ -87,24 +156,18 @@ func (h *ApplicationHandler) UpdateApplication(c *gin.Context) {
 		return
 	}
 
-	app, err := h.appService.GetByID(id)
+	app, err := h.appService.Update(c.Request.Context(), id, req)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "application not found"})
-		return
-	}
-
-	if req.Name != "" {
-		app.Name = req.Name
-	}
-	if req.Code != "" {
-		app.Code = req.Code
-	}
-	if req.Description != "" {
-		app.Description = req.Description
-	}
-
-	if err := h.appService.Update(app); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, service.ErrApplicationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "application not found"})
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrUnknownCategory):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
@@ -112,16 +175,31 @@ func (h *ApplicationHandler) UpdateApplication(c *gin.Context) {
 }
 
 // @Summary Delete application
-// @Description Soft delete application by ID
+// @Description Soft delete application by ID. Applications on legal hold cannot be deleted.
 // @Tags Applications
 // @Produce json
 // @Param id path int true "Application ID"
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications/{id} [delete]
 func (h *ApplicationHandler) DeleteApplication(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err := h.appService.Delete(id); err != nil {
+	if err := h.appService.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, service.ErrApplicationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "application not found"})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrLegalHold) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -135,12 +213,17 @@ func (h *ApplicationHandler) DeleteApplication(c *gin.Context) {
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Page size" default(20)
 // @Param q query string false "Search query"
-// @Param userId query int false "User ID"
+// @Param userId query int false "User ID (ignored for applicants, who only see their own applications)"
+// @Param assigneeId query int false "Only applications assigned to this user"
+// @Param category query string false "Only applications of this category"
 // @Param sort query string false "Sort column" default(created_at)
 // @Param order query string false "Sort order" default(desc)
 // @Param from query string false "Start date YYYY-MM-DD"
 // @Param to query string false "End date YYYY-MM-DD"
+// @Param possibleDuplicate query bool false "Only applications flagged (or not) as possible duplicates"
+// @Param documentState query string false "Only applications with documents that are expiring soon or expired" Enums(expiring, expired)
 // @Success 200 {object} service.ListResponse
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications [get]
 func (h *ApplicationHandler) ListApplications(c *gin.Context) {
@@ -148,6 +231,7 @@ func (h *ApplicationHandler) ListApplications(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
 	q := c.Query("q")
 	userID, _ := strconv.ParseUint(c.DefaultQuery("userId", "0"), 10, 64)
+	assigneeID, _ := strconv.ParseUint(c.DefaultQuery("assigneeId", "0"), 10, 64)
 	sort := c.DefaultQuery("sort", "created_at")
 	order := c.DefaultQuery("order", "desc")
 	from := c.Query("from")
@@ -162,9 +246,18 @@ func (h *ApplicationHandler) ListApplications(c *gin.Context) {
 		Order:    order,
 		From:     parseDatePtr(from),
 		To:       parseDatePtr(to),
+
+		AssigneeID:        assigneeID,
+		Category:          c.Query("category"),
+		PossibleDuplicate: parseBoolPtr(c.Query("possibleDuplicate")),
+		DocumentState:     c.Query("documentState"),
 	}
 
-	resp, err := h.appService.List(params)
+	resp, err := h.appService.List(c.Request.Context(), params)
+	if errors.Is(err, service.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -181,3 +274,14 @@ func parseDatePtr(dateStr string) *time.Time {
 	}
 	return &t
 }
+
+func parseBoolPtr(value string) *bool {
+	if value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil
+	}
+	return &b
+}
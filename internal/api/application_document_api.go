@@ -0,0 +1,398 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is allowed on top of the upload limit for the form
+// fields and part headers around the file.
+const multipartOverhead = 1 << 20
+
+type DocumentHandler struct {
+	docService    *service.ApplicationDocumentService
+	maxUploadSize int64
+	// uploadTimeout replaces the server's read and write timeouts for
+	// uploads; writeTimeout bounds each write of a download instead.
+	uploadTimeout time.Duration
+	writeTimeout  time.Duration
+}
+
+func NewDocumentHandler(docService *service.ApplicationDocumentService, maxUploadSize int64, uploadTimeout, writeTimeout time.Duration) *DocumentHandler {
+	return &DocumentHandler{docService: docService, maxUploadSize: maxUploadSize, uploadTimeout: uploadTimeout, writeTimeout: writeTimeout}
+}
+
+// @Summary Upload a document
+// @Description Upload a file for one of the file types declared on the application. The content is sniffed and must match the allowed MIME types, extensions and size of the file type's catalog document type, as well as the declared content type and file name; archives are unpacked to reject zip bombs. Pass replaces to upload a new version of a document; earlier versions are kept. Uploads that would take the application or its owner over their storage quota are rejected with 413.
+// @Tags ApplicationDocuments
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param file formData file true "Document"
+// @Param fileTypeId formData int false "Declared file type the document belongs to; required unless replaces is given"
+// @Param replaces formData int false "ID of the current version of the document the upload replaces"
+// @Param validUntil formData string false "Last day the document is valid, YYYY-MM-DD"
+// @Param userId formData int false "Uploader (staff only; applicants always upload as themselves)"
+// @Param Idempotency-Key header string false "Makes retries of this request safe"
+// @Success 201 {object} domain.ApplicationDocument
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files [post]
+func (h *DocumentHandler) UploadDocument(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if h.uploadTimeout > 0 {
+		rc := http.NewResponseController(c.Writer)
+		deadline := time.Now().Add(h.uploadTimeout)
+		rc.SetReadDeadline(deadline)
+		rc.SetWriteDeadline(deadline)
+	}
+	if h.maxUploadSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize+multipartOverhead)
+	}
+	replacesID, _ := strconv.ParseUint(c.PostForm("replaces"), 10, 64)
+	fileTypeID, err := strconv.ParseUint(c.PostForm("fileTypeId"), 10, 64)
+	if err != nil && replacesID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileTypeId is required"})
+		return
+	}
+	validUntil, err := parseValidUntil(c.PostForm("validUntil"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := strconv.ParseUint(c.DefaultPostForm("userId", "0"), 10, 64)
+	header, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": service.ErrDocumentTooLarge.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	doc, err := h.docService.Upload(c.Request.Context(), appID, service.UploadDocumentInput{
+		FileTypeID:  fileTypeID,
+		FileName:    header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Size:        header.Size,
+		Content:     file,
+		UserID:      userID,
+		ReplacesID:  replacesID,
+		ValidUntil:  validUntil,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrApplicationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrFileTypeNotFound), errors.Is(err, service.ErrUserRequired),
+			errors.Is(err, service.ErrDocumentNotFound), errors.Is(err, service.ErrFileTypeMismatch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrDocumentTooLarge), errors.Is(err, service.ErrQuotaExceeded):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrDocumentTypeNotAllowed), errors.Is(err, service.ErrDocumentMismatch):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrUnsafeArchive):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, doc)
+}
+
+// @Summary List documents
+// @Description List the current version of each document uploaded for an application, with its review decision
+// @Tags ApplicationDocuments
+// @Produce json
+// @Param id path int true "Application ID"
+// @Success 200 {array} domain.ApplicationDocument
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files [get]
+func (h *DocumentHandler) ListDocuments(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docs, err := h.docService.List(c.Request.Context(), appID)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, docs)
+}
+
+// parseValidUntil parses an optional validUntil date.
+func parseValidUntil(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, errors.New("validUntil must be a date, YYYY-MM-DD")
+	}
+	return &t, nil
+}
+
+// @Summary Download a document
+// @Description Download the content of a document. Documents are quarantined until their malware scan is clean.
+// @Tags ApplicationDocuments
+// @Produce octet-stream
+// @Param id path int true "Application ID"
+// @Param fileId path int true "Document ID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files/{fileId} [get]
+func (h *DocumentHandler) DownloadDocument(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docID, _ := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	doc, content, err := h.docService.Open(c.Request.Context(), appID, docID)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	defer content.Close()
+	serveDocument(c, doc, content, h.writeTimeout)
+}
+
+// serveDocument writes the content as an attachment. Range and conditional
+// requests are answered, with the checksum as ETag. Each write must finish
+// within writeTimeout instead of the server's write timeout, so slow
+// clients can download large documents.
+func serveDocument(c *gin.Context, doc *domain.ApplicationDocument, content io.ReadSeeker, writeTimeout time.Duration) {
+	if writeTimeout > 0 {
+		c.Writer = &deadlineWriter{ResponseWriter: c.Writer, rc: http.NewResponseController(c.Writer), timeout: writeTimeout}
+	}
+	c.Header("Content-Type", doc.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	c.Header("X-Checksum-SHA256", doc.Checksum)
+	c.Header("ETag", `"`+doc.Checksum+`"`)
+	http.ServeContent(c.Writer, c.Request, doc.FileName, doc.CreatedAt, content)
+}
+
+// @Summary Preview a document
+// @Description Get a JPEG preview of an image, or of the first page of a scanned PDF; PDF pages of text have no preview. Previews are made in the background once the document is scanned clean; the document's previewStatus tells whether one is ready.
+// @Tags ApplicationDocuments
+// @Produce jpeg
+// @Param id path int true "Document ID"
+// @Param size query string false "Preview size: small (256 px) or large (1024 px)" default(small)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /documents/{id}/preview [get]
+func (h *DocumentHandler) GetPreview(c *gin.Context) {
+	docID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	doc, content, err := h.docService.OpenPreview(c.Request.Context(), docID, c.Query("size"))
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	defer content.Close()
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("Cache-Control", "private, max-age=3600")
+	http.ServeContent(c.Writer, c.Request, "", *doc.PreviewedAt, content)
+}
+
+// @Summary Delete a document
+// @Description Delete a document with all its versions and their stored content. Once any version was reviewed, deleting it needs the documents:delete permission. Documents of applications on legal hold cannot be deleted.
+// @Tags ApplicationDocuments
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param fileId path int true "Document ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files/{fileId} [delete]
+func (h *DocumentHandler) DeleteDocument(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docID, _ := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	if err := h.docService.Delete(c.Request.Context(), appID, docID); err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// @Summary Rescan a document
+// @Description Quarantine a document again and queue it for another malware scan
+// @Tags ApplicationDocuments
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param fileId path int true "Document ID"
+// @Success 202 {object} domain.ApplicationDocument
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files/{fileId}/rescan [post]
+func (h *DocumentHandler) RescanDocument(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docID, _ := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	doc, err := h.docService.Rescan(c.Request.Context(), appID, docID)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, doc)
+}
+
+// @Summary Document versions
+// @Description List every version of the document, newest first, with their review decisions
+// @Tags ApplicationDocuments
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param fileId path int true "ID of any version of the document"
+// @Success 200 {array} domain.ApplicationDocument
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files/{fileId}/versions [get]
+func (h *DocumentHandler) ListVersions(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docID, _ := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	versions, err := h.docService.Versions(c.Request.Context(), appID, docID)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// @Summary Review a document version
+// @Description Accept or reject one version of a document. A rejection needs a reason code (illegible, expired, wrong_document, incomplete, mismatch, or other with a note), which the applicant sees. Only versions scanned clean can be accepted.
+// @Tags ApplicationDocuments
+// @Accept json
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param fileId path int true "Document version ID"
+// @Param review body service.ReviewDocumentRequest true "Decision"
+// @Success 200 {object} domain.ApplicationDocument
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files/{fileId}/review [post]
+func (h *DocumentHandler) ReviewDocument(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docID, _ := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	var req service.ReviewDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	doc, err := h.docService.Review(c.Request.Context(), appID, docID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidReview) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// deadlineWriter gives every write its own deadline.
+type deadlineWriter struct {
+	gin.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	if w.timeout > 0 {
+		w.rc.SetWriteDeadline(time.Now().Add(w.timeout))
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func writeDocumentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrDocumentNotFound), errors.Is(err, service.ErrNoPreview):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidPreviewSize):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDocumentQuarantined), errors.Is(err, service.ErrPreviewNotReady), errors.Is(err, service.ErrLegalHold):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// @Summary Document checklist
+// @Description Report which documents required by the application's category were accepted, await review, were rejected or are missing. Only accepted documents satisfy a requirement.
+// @Tags ApplicationDocuments
+// @Produce json
+// @Param id path int true "Application ID"
+// @Success 200 {object} service.Checklist
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/checklist [get]
+func (h *DocumentHandler) GetChecklist(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	checklist, err := h.docService.Checklist(c.Request.Context(), appID)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, checklist)
+}
+
+// @Summary Storage usage
+// @Description Report the storage used by the application's documents and by all documents of its owner, counting every version, with the quotas that apply. A limit of 0 means no quota.
+// @Tags ApplicationDocuments
+// @Produce json
+// @Param id path int true "Application ID"
+// @Success 200 {object} service.StorageUsage
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/storage [get]
+func (h *DocumentHandler) GetStorageUsage(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	usage, err := h.docService.Usage(c.Request.Context(), appID)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
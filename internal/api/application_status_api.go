@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -18,14 +19,18 @@ func NewStatusHandler(statusService *service.ApplicationStatusService) *StatusHa
 }
 
 // @Summary Add status to an application
-// @Description Add a new status for an application
+// @Description Add a new status for an application. Moving to a submit status of the tenant's workflow ("submitted" unless configured) requires the documents of the application's category checklist to be uploaded and not rejected; they need not be reviewed yet.
 // @Tags ApplicationStatus
 // @Accept json
 // @Produce json
 // @Param id path int true "Application ID"
 // @Param input body service.AddStatusRequest true "Status payload"
+// @Param Idempotency-Key header string false "Makes retries of this request safe"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications/{id}/status [post]
 func (h *StatusHandler) AddStatus(c *gin.Context) {
@@ -36,8 +41,22 @@ func (h *StatusHandler) AddStatus(c *gin.Context) {
 		return
 	}
 
-	if err := h.statusService.Add(appID, req.UserID, req.Status); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.statusService.Add(c.Request.Context(), appID, req.UserID, req.Status); err != nil {
+		var checklistErr *service.IncompleteChecklistError
+		switch {
+		case errors.As(err, &checklistErr):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": checklistErr.Error(), "checklist": checklistErr.Checklist})
+		case errors.Is(err, service.ErrApplicationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrUserRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidTransition):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "status added"})
@@ -51,6 +70,8 @@ func (h *StatusHandler) AddStatus(c *gin.Context) {
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Page size" default(20)
 // @Success 200 {object} service.ListResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /applications/{id}/statuses [get]
 func (h *StatusHandler) ListStatuses(c *gin.Context) {
@@ -58,8 +79,16 @@ func (h *StatusHandler) ListStatuses(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
 
-	resp, err := h.statusService.List(appID, page, pageSize)
+	resp, err := h.statusService.List(c.Request.Context(), appID, page, pageSize)
 	if err != nil {
+		if errors.Is(err, service.ErrApplicationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
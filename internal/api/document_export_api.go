@@ -0,0 +1,149 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	exportService *service.DocumentExportService
+	writeTimeout  time.Duration
+}
+
+// NewExportHandler creates the export handler. Streamed archives may take
+// longer than the server's write timeout; instead each write must finish
+// within writeTimeout.
+func NewExportHandler(exportService *service.DocumentExportService, writeTimeout time.Duration) *ExportHandler {
+	return &ExportHandler{exportService: exportService, writeTimeout: writeTimeout}
+}
+
+// @Summary Download all documents as ZIP
+// @Description Stream a ZIP archive of the current version of every document of the application, built on the fly. Documents are in a folder per file type; manifest.json lists each document's checksum and metadata, and the quarantined documents that were left out.
+// @Tags DocumentExports
+// @Produce application/zip
+// @Param id path int true "Application ID"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/documents.zip [get]
+func (h *ExportHandler) DownloadApplicationArchive(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	archive, err := h.exportService.ApplicationArchive(c.Request.Context(), appID)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	name := "application-" + strconv.FormatUint(appID, 10) + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Status(http.StatusOK)
+
+	w := &deadlineWriter{ResponseWriter: c.Writer, rc: http.NewResponseController(c.Writer), timeout: h.writeTimeout}
+	if _, err := archive.Write(c.Request.Context(), w); err != nil {
+		// The status is sent already; the archive lacks its central
+		// directory, so clients see it is broken.
+		log.Printf("Failed to stream archive of application %d: %v", appID, err)
+	}
+}
+
+// @Summary Start a document export
+// @Description Queue a ZIP export of the documents of several applications: those listed in applicationIds, or else those matching the filters that the caller may read. The archive has a folder per application and a manifest.json; poll the job until it is done, then download it.
+// @Tags DocumentExports
+// @Accept json
+// @Produce json
+// @Param export body service.CreateExportRequest true "Applications to export"
+// @Success 202 {object} domain.ExportJob
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /exports [post]
+func (h *ExportHandler) CreateExport(c *gin.Context) {
+	var req service.CreateExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := h.exportService.CreateJob(c.Request.Context(), req)
+	if err != nil {
+		writeExportError(c, err)
+		return
+	}
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.FormatUint(job.ID, 10))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// @Summary Get a document export
+// @Description Report the status of an export the caller started
+// @Tags DocumentExports
+// @Produce json
+// @Param id path int true "Export ID"
+// @Success 200 {object} domain.ExportJob
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /exports/{id} [get]
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	job, err := h.exportService.GetJob(c.Request.Context(), id)
+	if err != nil {
+		writeExportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Download a document export
+// @Description Download the archive of a finished export the caller started
+// @Tags DocumentExports
+// @Produce application/zip
+// @Param id path int true "Export ID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /exports/{id}/download [get]
+func (h *ExportHandler) DownloadExport(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	job, content, err := h.exportService.OpenJob(c.Request.Context(), id)
+	if err != nil {
+		writeExportError(c, err)
+		return
+	}
+	defer content.Close()
+	name := "export-" + strconv.FormatUint(job.ID, 10) + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	if h.writeTimeout > 0 {
+		c.Writer = &deadlineWriter{ResponseWriter: c.Writer, rc: http.NewResponseController(c.Writer), timeout: h.writeTimeout}
+	}
+	http.ServeContent(c.Writer, c.Request, name, *job.FinishedAt, content)
+}
+
+func writeExportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrExportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidExport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrExportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrExportExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
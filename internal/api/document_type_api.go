@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DocumentTypeHandler struct {
+	typeService *service.DocumentTypeService
+}
+
+func NewDocumentTypeHandler(typeService *service.DocumentTypeService) *DocumentTypeHandler {
+	return &DocumentTypeHandler{typeService: typeService}
+}
+
+// @Summary Create a document type
+// @Description Add an entry to the tenant's catalog of document types. Applications declare file types by referring to catalog entries.
+// @Tags DocumentTypes
+// @Accept json
+// @Produce json
+// @Param input body service.CreateDocumentTypeRequest true "Document type"
+// @Success 201 {object} domain.DocumentType
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /document-types [post]
+func (h *DocumentTypeHandler) CreateDocumentType(c *gin.Context) {
+	var req service.CreateDocumentTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	docType, err := h.typeService.Create(c.Request.Context(), req)
+	if err != nil {
+		writeDocumentTypeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, docType)
+}
+
+// @Summary List document types
+// @Description List the tenant's catalog of document types
+// @Tags DocumentTypes
+// @Produce json
+// @Success 200 {array} domain.DocumentType
+// @Failure 500 {object} map[string]string
+// @Router /document-types [get]
+func (h *DocumentTypeHandler) ListDocumentTypes(c *gin.Context) {
+	docTypes, err := h.typeService.List(c.Request.Context())
+	if err != nil {
+		writeDocumentTypeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, docTypes)
+}
+
+// @Summary Get a document type
+// @Tags DocumentTypes
+// @Produce json
+// @Param id path int true "Document type ID"
+// @Success 200 {object} domain.DocumentType
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /document-types/{id} [get]
+func (h *DocumentTypeHandler) GetDocumentType(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docType, err := h.typeService.Get(c.Request.Context(), id)
+	if err != nil {
+		writeDocumentTypeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, docType)
+}
+
+// @Summary Update a document type
+// @Description Replace the display name, description, upload rules and retention of a document type. The key cannot change. The rules apply to uploads from now on.
+// @Tags DocumentTypes
+// @Accept json
+// @Produce json
+// @Param id path int true "Document type ID"
+// @Param input body service.UpdateDocumentTypeRequest true "Document type"
+// @Success 200 {object} domain.DocumentType
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /document-types/{id} [put]
+func (h *DocumentTypeHandler) UpdateDocumentType(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req service.UpdateDocumentTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	docType, err := h.typeService.Update(c.Request.Context(), id, req)
+	if err != nil {
+		writeDocumentTypeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, docType)
+}
+
+// @Summary Delete a document type
+// @Description Remove a document type from the catalog. Types that applications declare cannot be deleted.
+// @Tags DocumentTypes
+// @Produce json
+// @Param id path int true "Document type ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /document-types/{id} [delete]
+func (h *DocumentTypeHandler) DeleteDocumentType(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err := h.typeService.Delete(c.Request.Context(), id); err != nil {
+		writeDocumentTypeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func writeDocumentTypeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrDocumentTypeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidDocumentType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDocumentTypeExists), errors.Is(err, service.ErrDocumentTypeInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The tus 1.0 resumable upload protocol, see https://tus.io/protocols/resumable-upload.
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination,checksum"
+	// tusContentType is the only content type accepted for chunks.
+	tusContentType = "application/offset+octet-stream"
+	// statusChecksumMismatch is the status tus defines for a bad checksum.
+	statusChecksumMismatch = 460
+)
+
+type UploadHandler struct {
+	uploadService *service.DocumentUploadService
+	// chunkTimeout replaces the server's read and write timeouts for
+	// chunks, which may take long on a slow connection.
+	chunkTimeout time.Duration
+}
+
+func NewUploadHandler(uploadService *service.DocumentUploadService, chunkTimeout time.Duration) *UploadHandler {
+	return &UploadHandler{uploadService: uploadService, chunkTimeout: chunkTimeout}
+}
+
+// @Summary Resumable upload capabilities
+// @Description Report the tus protocol version, extensions, maximum size and checksum algorithms
+// @Tags DocumentUploads
+// @Param id path int true "Application ID"
+// @Success 204
+// @Router /applications/{id}/uploads [options]
+func (h *UploadHandler) UploadOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", strings.Join(service.UploadChecksumAlgorithms, ","))
+	if max := h.uploadService.MaxSize(); max > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(max, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Start a resumable upload
+// @Description Start a tus upload of a document. Upload-Metadata carries base64 values for fileTypeId (required), filename, filetype (content type), checksum (hex SHA-256 of the whole file), replaces (ID of the document the upload becomes a new version of; fileTypeId may then be left out), validUntil (last day the document is valid, YYYY-MM-DD) and userId (staff only).
+// @Tags DocumentUploads
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Length header int true "Size of the whole file in bytes"
+// @Param Upload-Metadata header string true "Comma-separated key and base64 value pairs"
+// @Success 201 {object} domain.DocumentUpload
+// @Header 201 {string} Location "URL of the upload"
+// @Header 201 {string} Upload-Expires "When the upload expires unless continued"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/uploads [post]
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Defer-Length is not supported"})
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length is required"})
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	replacesID, _ := strconv.ParseUint(metadata["replaces"], 10, 64)
+	fileTypeID, err := strconv.ParseUint(metadata["fileTypeId"], 10, 64)
+	if err != nil && replacesID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileTypeId metadata is required"})
+		return
+	}
+	validUntil, err := parseValidUntil(metadata["validUntil"])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := strconv.ParseUint(metadata["userId"], 10, 64)
+
+	upload, err := h.uploadService.Create(c.Request.Context(), appID, service.CreateUploadInput{
+		FileTypeID:  fileTypeID,
+		FileName:    metadata["filename"],
+		ContentType: metadata["filetype"],
+		Length:      length,
+		Checksum:    metadata["checksum"],
+		UserID:      userID,
+		ReplacesID:  replacesID,
+		ValidUntil:  validUntil,
+	})
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+	setUploadHeaders(c, upload)
+	c.JSON(http.StatusCreated, upload)
+}
+
+// @Summary Resumable upload offset
+// @Description Report how much of a tus upload was received, so the client knows where to resume
+// @Tags DocumentUploads
+// @Param id path int true "Application ID"
+// @Param uploadId path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Success 200
+// @Header 200 {int} Upload-Offset "Bytes received"
+// @Header 200 {int} Upload-Length "Size of the whole file"
+// @Header 200 {int} X-Document-ID "Document the completed upload became"
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /applications/{id}/uploads/{uploadId} [head]
+func (h *UploadHandler) HeadUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	upload, err := h.uploadService.Get(c.Request.Context(), appID, c.Param("uploadId"))
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	setUploadHeaders(c, upload)
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// @Summary Send a chunk
+// @Description Append a chunk to a tus upload at Upload-Offset. The chunk completing the upload turns it into a document, checked like a direct upload; an empty chunk at the end retries that step.
+// @Tags DocumentUploads
+// @Accept application/offset+octet-stream
+// @Param id path int true "Application ID"
+// @Param uploadId path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Offset header int true "Offset the chunk starts at"
+// @Param Upload-Checksum header string false "Algorithm and base64 checksum of the chunk, e.g. sha256 ..."
+// @Success 204
+// @Header 204 {int} Upload-Offset "Bytes received"
+// @Header 204 {int} X-Document-ID "Document the completed upload became"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 460 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/uploads/{uploadId} [patch]
+func (h *UploadHandler) PatchUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "chunks must be sent as " + tusContentType})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset is required"})
+		return
+	}
+	var checksum *service.ChunkChecksum
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		algorithm, encoded, _ := strings.Cut(header, " ")
+		sum, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Checksum must be an algorithm and a base64 checksum"})
+			return
+		}
+		checksum = &service.ChunkChecksum{Algorithm: algorithm, Sum: sum}
+	}
+	if h.chunkTimeout > 0 {
+		rc := http.NewResponseController(c.Writer)
+		deadline := time.Now().Add(h.chunkTimeout)
+		rc.SetReadDeadline(deadline)
+		rc.SetWriteDeadline(deadline)
+	}
+
+	upload, err := h.uploadService.Append(c.Request.Context(), appID, c.Param("uploadId"), offset, c.Request.Body, checksum)
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Abandon an upload
+// @Description Terminate a tus upload and remove the chunks received so far. An upload being turned into a document cannot be terminated.
+// @Tags DocumentUploads
+// @Param id path int true "Application ID"
+// @Param uploadId path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/uploads/{uploadId} [delete]
+func (h *UploadHandler) DeleteUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err := h.uploadService.Terminate(c.Request.Context(), appID, c.Param("uploadId")); err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// checkTusVersion rejects requests for a protocol version other than 1.0.0.
+func checkTusVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "unsupported Tus-Resumable version"})
+		return false
+	}
+	return true
+}
+
+func setUploadHeaders(c *gin.Context, upload *domain.DocumentUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.DocumentID != nil {
+		c.Header("X-Document-ID", strconv.FormatUint(*upload.DocumentID, 10))
+	}
+}
+
+// parseUploadMetadata decodes "key base64value" pairs separated by commas.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.New("Upload-Metadata value of " + key + " is not base64")
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func writeUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUploadExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUploadOffsetMismatch), errors.Is(err, service.ErrUploadInProgress),
+		errors.Is(err, service.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrChecksumMismatch):
+		c.JSON(statusChecksumMismatch, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidUpload), errors.Is(err, service.ErrUnsupportedChecksum),
+		errors.Is(err, service.ErrFileTypeNotFound), errors.Is(err, service.ErrUserRequired),
+		errors.Is(err, service.ErrDocumentNotFound), errors.Is(err, service.ErrFileTypeMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDocumentTooLarge), errors.Is(err, service.ErrQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDocumentTypeNotAllowed), errors.Is(err, service.ErrDocumentMismatch):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUnsafeArchive):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
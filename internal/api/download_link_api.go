@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DownloadLinkHandler struct {
+	linkService *service.DownloadLinkService
+	// writeTimeout bounds each write of a download instead of the server's
+	// write timeout.
+	writeTimeout time.Duration
+}
+
+func NewDownloadLinkHandler(linkService *service.DownloadLinkService, writeTimeout time.Duration) *DownloadLinkHandler {
+	return &DownloadLinkHandler{linkService: linkService, writeTimeout: writeTimeout}
+}
+
+// @Summary Create a download link
+// @Description Issue a signed, expiring link to a document that works without API credentials, e.g. in a browser
+// @Tags ApplicationDocuments
+// @Accept json
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param fileId path int true "Document ID"
+// @Param input body service.CreateDownloadLinkRequest false "Link options"
+// @Success 201 {object} service.DownloadLinkResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/files/{fileId}/links [post]
+func (h *DownloadLinkHandler) CreateDownloadLink(c *gin.Context) {
+	appID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	docID, _ := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	var req service.CreateDownloadLinkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	link, err := h.linkService.CreateLink(c.Request.Context(), appID, docID, req)
+	if err != nil {
+		writeDocumentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, link)
+}
+
+// @Summary Download through a signed link
+// @Description Download a document with the query parameters of a signed link instead of API credentials. Every download is audited.
+// @Tags Downloads
+// @Produce octet-stream
+// @Param docId path int true "Document ID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /downloads/{docId} [get]
+func (h *DownloadLinkHandler) Download(c *gin.Context) {
+	docID, _ := strconv.ParseUint(c.Param("docId"), 10, 64)
+	doc, content, err := h.linkService.OpenLink(c.Request.Context(), docID, c.Query, service.AccessInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Range:     c.GetHeader("Range"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLink):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrLinkExpired), errors.Is(err, service.ErrLinkUsed):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		default:
+			writeDocumentError(c, err)
+		}
+		return
+	}
+	defer content.Close()
+	serveDocument(c, doc, content, h.writeTimeout)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MeResponse struct {
+	Principal   *auth.Principal `json:"principal"`
+	TenantID    string          `json:"tenantId"`
+	Permissions []string        `json:"permissions"`
+}
+
+type MeHandler struct{}
+
+func NewMeHandler() *MeHandler {
+	return &MeHandler{}
+}
+
+// @Summary Current principal
+// @Description Return the authenticated principal, its tenant and its effective permissions
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} api.MeResponse
+// @Router /me [get]
+func (h *MeHandler) GetMe(c *gin.Context) {
+	principal := middleware.GetPrincipal(c)
+	c.JSON(http.StatusOK, MeResponse{
+		Principal:   principal,
+		TenantID:    c.GetString(middleware.TenantKey),
+		Permissions: principal.Permissions(),
+	})
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PolicyHandler struct {
+	appService *service.ApplicationService
+}
+
+func NewPolicyHandler(appService *service.ApplicationService) *PolicyHandler {
+	return &PolicyHandler{appService: appService}
+}
+
+// @Summary Explain a policy decision
+// @Description Evaluate the authorization policies for an action on an application and report which rules matched. The principal defaults to the caller; request holds the attributes of the change being made, e.g. {"status": "approved"}.
+// @Tags Policies
+// @Accept json
+// @Produce json
+// @Param input body service.ExplainPolicyRequest true "Decision input"
+// @Success 200 {object} policy.Decision
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /policies/explain [post]
+func (h *PolicyHandler) Explain(c *gin.Context) {
+	var req service.ExplainPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	decision, err := h.appService.Explain(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrApplicationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, decision)
+}
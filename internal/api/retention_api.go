@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RetentionHandler struct {
+	retentionService *service.RetentionService
+}
+
+func NewRetentionHandler(retentionService *service.RetentionService) *RetentionHandler {
+	return &RetentionHandler{retentionService: retentionService}
+}
+
+// @Summary Retention report
+// @Description Dry run of the retention job: list the applications past the retention of the terminal status they closed with, and the documents past the retention of their document type, that a purge at the given day would delete. Nothing is deleted. Entries on legal hold are listed but would be kept.
+// @Tags Retention
+// @Produce json
+// @Param at query string false "Day of the purge, YYYY-MM-DD; defaults to now"
+// @Success 200 {object} service.RetentionReport
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /retention/report [get]
+func (h *RetentionHandler) GetReport(c *gin.Context) {
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be a date in the format YYYY-MM-DD"})
+			return
+		}
+		at = day
+	}
+	report, err := h.retentionService.Report(c.Request.Context(), at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// @Summary Place a legal hold
+// @Description Keep the application and its documents from being purged by retention or deleted until the hold is released
+// @Tags Retention
+// @Accept json
+// @Produce json
+// @Param id path int true "Application ID"
+// @Param input body service.LegalHoldRequest true "Legal hold"
+// @Success 200 {object} domain.Application
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/legal-hold [put]
+func (h *RetentionHandler) PlaceLegalHold(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req service.LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	app, err := h.retentionService.PlaceLegalHold(c.Request.Context(), id, req.Reason)
+	if err != nil {
+		writeLegalHoldError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// @Summary Release a legal hold
+// @Description Let retention purge the application again once it is due
+// @Tags Retention
+// @Produce json
+// @Param id path int true "Application ID"
+// @Success 200 {object} domain.Application
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /applications/{id}/legal-hold [delete]
+func (h *RetentionHandler) ReleaseLegalHold(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	app, err := h.retentionService.ReleaseLegalHold(c.Request.Context(), id)
+	if err != nil {
+		writeLegalHoldError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+func writeLegalHoldError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrLegalHoldReason):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
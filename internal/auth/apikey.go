@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+
+	// Requests authenticated with the shared API key come from a trusted
+	// gateway, which may name the end user it is acting for.
+	UserIDHeader    = "X-User-ID"
+	UserRolesHeader = "X-User-Roles"
+)
+
+// APIKeyAuthenticator accepts the single shared API key.
+type APIKeyAuthenticator struct {
+	key string
+}
+
+func NewAPIKeyAuthenticator(key string) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{key: key}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" || a.key == "" {
+		return nil, ErrNoCredentials
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(a.key)) != 1 {
+		return nil, errors.New("invalid API key")
+	}
+
+	principal := &Principal{Subject: "api-key", Roles: []string{RoleAdmin}, CrossTenant: true}
+	if header := r.Header.Get(UserIDHeader); header != "" {
+		userID, err := strconv.ParseUint(header, 10, 64)
+		if err != nil || userID == 0 {
+			return nil, errors.New("invalid " + UserIDHeader + " header")
+		}
+		principal.Subject = "user:" + header
+		principal.UserID = userID
+		principal.Roles = []string{RoleApplicant}
+		if roles := r.Header.Get(UserRolesHeader); roles != "" {
+			principal.Roles = splitList(roles, ",")
+		}
+	}
+	return principal, nil
+}
+
+func splitList(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,15 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request does not
+// carry the kind of credential it handles, so the next one can be tried.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator turns the credential on a request into a Principal.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/pkg/hmacsign"
+)
+
+const (
+	// maxSignedBodySize bounds the body buffered to verify a signature.
+	maxSignedBodySize = 32 << 20
+	maxNonceLength    = 128
+)
+
+// HMACClient is a service allowed to call us with signed requests.
+type HMACClient struct {
+	ID       string
+	Secret   []byte
+	Roles    []string
+	TenantID string
+	// CrossTenant lets a client without a tenant name one per request.
+	CrossTenant bool
+}
+
+// NonceStore remembers the nonces of signed requests, shared by every
+// instance of the service.
+type NonceStore interface {
+	// UseNonce records the nonce of a client and reports false when it was
+	// used before.
+	UseNonce(ctx context.Context, clientID, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// HMACAuthenticator verifies requests signed with pkg/hmacsign. A signature
+// is accepted once: its nonce is remembered for as long as its timestamp
+// is within the allowed skew.
+type HMACAuthenticator struct {
+	clients map[string]HMACClient
+	skew    time.Duration
+	nonces  NonceStore
+	now     func() time.Time
+}
+
+func NewHMACAuthenticator(clients []HMACClient, skew time.Duration, nonces NonceStore) (*HMACAuthenticator, error) {
+	byID := make(map[string]HMACClient, len(clients))
+	for _, client := range clients {
+		if client.ID == "" || len(client.Secret) == 0 || len(client.Roles) == 0 {
+			return nil, errors.New("HMAC clients need an id, a secret and at least one role")
+		}
+		if client.CrossTenant && client.TenantID != "" {
+			return nil, fmt.Errorf("HMAC client %q cannot be bound to a tenant and cross-tenant", client.ID)
+		}
+		if _, dup := byID[client.ID]; dup {
+			return nil, fmt.Errorf("duplicate HMAC client %q", client.ID)
+		}
+		byID[client.ID] = client
+	}
+	return &HMACAuthenticator{
+		clients: byID,
+		skew:    skew,
+		nonces:  nonces,
+		now:     time.Now,
+	}, nil
+}
+
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	clientID := r.Header.Get(hmacsign.ClientIDHeader)
+	signature := r.Header.Get(hmacsign.SignatureHeader)
+	if clientID == "" && signature == "" {
+		return nil, ErrNoCredentials
+	}
+	timestamp := r.Header.Get(hmacsign.TimestampHeader)
+	nonce := r.Header.Get(hmacsign.NonceHeader)
+	if clientID == "" || signature == "" || timestamp == "" || nonce == "" {
+		return nil, errors.New("incomplete request signature")
+	}
+	if len(nonce) > maxNonceLength {
+		return nil, errors.New("signature nonce is too long")
+	}
+
+	client, ok := a.clients[clientID]
+	if !ok {
+		return nil, errors.New("invalid request signature")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid signature timestamp")
+	}
+	signedAt := time.Unix(unix, 0)
+	now := a.now()
+	if signedAt.Before(now.Add(-a.skew)) || signedAt.After(now.Add(a.skew)) {
+		return nil, errors.New("signature timestamp outside the allowed clock skew")
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	canonical := hmacsign.CanonicalString(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !hmacsign.Equal(signature, hmacsign.Signature(client.Secret, canonical)) {
+		return nil, errors.New("invalid request signature")
+	}
+
+	// Only remember nonces of valid signatures, so forged requests cannot
+	// burn a client's nonces. The nonce must outlive the skew window on
+	// both sides of its timestamp.
+	fresh, err := a.nonces.UseNonce(r.Context(), clientID, nonce, signedAt.Add(a.skew))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record signature nonce: %w", err)
+	}
+	if !fresh {
+		return nil, errors.New("replayed request signature")
+	}
+
+	return &Principal{
+		Subject:     "hmac:" + client.ID,
+		Roles:       client.Roles,
+		TenantID:    client.TenantID,
+		CrossTenant: client.CrossTenant,
+	}, nil
+}
+
+// readBody reads the request body and puts it back for the handler.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) > maxSignedBodySize {
+		return nil, errors.New("signed request body is too large")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/pkg/hmacsign"
+)
+
+// memoryNonces stands in for the nonce table shared by all instances.
+type memoryNonces struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func (m *memoryNonces) UseNonce(_ context.Context, clientID, nonce string, _ time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := clientID + "\x00" + nonce
+	if m.used[key] {
+		return false, nil
+	}
+	m.used[key] = true
+	return true, nil
+}
+
+func newTestHMACAuthenticator(t *testing.T, nonces NonceStore) *HMACAuthenticator {
+	t.Helper()
+	a, err := NewHMACAuthenticator([]HMACClient{
+		{ID: "billing", Secret: []byte("billing-secret"), Roles: []string{RoleSupervisor}, TenantID: "acme"},
+	}, 5*time.Minute, nonces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func signedRequest(t *testing.T, secret, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/applications?x=1", strings.NewReader(body))
+	if err := hmacsign.NewSigner("billing", []byte(secret)).Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	a := newTestHMACAuthenticator(t, &memoryNonces{used: map[string]bool{}})
+
+	req := signedRequest(t, "billing-secret", `{"name":"x"}`)
+	p, err := a.Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "hmac:billing" || p.TenantID != "acme" || !p.HasRole(RoleSupervisor) {
+		t.Errorf("unexpected principal %+v", p)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"name":"x"}` {
+		t.Errorf("body was not put back, got %q", body)
+	}
+}
+
+func TestHMACAuthenticatorCrossTenant(t *testing.T) {
+	if _, err := NewHMACAuthenticator([]HMACClient{
+		{ID: "billing", Secret: []byte("billing-secret"), Roles: []string{RoleSupervisor}, TenantID: "acme", CrossTenant: true},
+	}, 5*time.Minute, nil); err == nil {
+		t.Error("accepted a client bound to a tenant and cross-tenant")
+	}
+
+	a, err := NewHMACAuthenticator([]HMACClient{
+		{ID: "billing", Secret: []byte("billing-secret"), Roles: []string{RoleSupervisor}, CrossTenant: true},
+	}, 5*time.Minute, &memoryNonces{used: map[string]bool{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate(signedRequest(t, "billing-secret", "{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.CrossTenant || p.TenantID != "" {
+		t.Errorf("unexpected principal %+v", p)
+	}
+}
+
+func TestHMACAuthenticatorRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(r *http.Request)
+		err    string
+	}{
+		{"wrong secret", func(r *http.Request) {
+			*r = *signedRequest(t, "other-secret", "body")
+		}, "invalid request signature"},
+		{"unknown client", func(r *http.Request) { r.Header.Set(hmacsign.ClientIDHeader, "shipping") }, "invalid request signature"},
+		{"tampered body", func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader("other")) }, "invalid request signature"},
+		{"tampered path", func(r *http.Request) { r.URL.RawQuery = "x=2" }, "invalid request signature"},
+		{"missing nonce", func(r *http.Request) { r.Header.Del(hmacsign.NonceHeader) }, "incomplete request signature"},
+		{"long nonce", func(r *http.Request) { r.Header.Set(hmacsign.NonceHeader, strings.Repeat("n", maxNonceLength+1)) }, "too long"},
+		{"old timestamp", func(r *http.Request) {
+			r.Header.Set(hmacsign.TimestampHeader, "1000000000")
+		}, "clock skew"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestHMACAuthenticator(t, &memoryNonces{used: map[string]bool{}})
+			req := signedRequest(t, "billing-secret", "body")
+			tt.mutate(req)
+			_, err := a.Authenticate(req)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Authenticate() error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHMACAuthenticatorUnsigned(t *testing.T) {
+	a := newTestHMACAuthenticator(t, &memoryNonces{used: map[string]bool{}})
+	_, err := a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != ErrNoCredentials {
+		t.Fatalf("Authenticate() error = %v, want ErrNoCredentials", err)
+	}
+}
+
+// A replay is rejected by any instance, since they share the nonce store.
+func TestHMACAuthenticatorReplayAcrossInstances(t *testing.T) {
+	nonces := &memoryNonces{used: map[string]bool{}}
+	first := newTestHMACAuthenticator(t, nonces)
+	second := newTestHMACAuthenticator(t, nonces)
+
+	req := signedRequest(t, "billing-secret", "body")
+	replay := req.Clone(context.Background())
+	replay.Body = io.NopCloser(strings.NewReader("body"))
+
+	if _, err := first.Authenticate(req); err != nil {
+		t.Fatal(err)
+	}
+	_, err := second.Authenticate(replay)
+	if err == nil || !strings.Contains(err.Error(), "replayed") {
+		t.Fatalf("replay error = %v, want a replay to be rejected", err)
+	}
+}
+
+// Forged requests must not use up the nonces of a client.
+func TestHMACAuthenticatorInvalidSignatureKeepsNonce(t *testing.T) {
+	nonces := &memoryNonces{used: map[string]bool{}}
+	a := newTestHMACAuthenticator(t, nonces)
+
+	req := signedRequest(t, "billing-secret", "body")
+	forged := req.Clone(context.Background())
+	forged.Body = io.NopCloser(strings.NewReader("forged"))
+	if _, err := a.Authenticate(forged); err == nil {
+		t.Fatal("forged request was accepted")
+	}
+	if _, err := a.Authenticate(req); err != nil {
+		t.Fatalf("genuine request after a forgery: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type JWTConfig struct {
+	Issuer     string
+	Audience   string
+	Algorithms []string
+	Leeway     time.Duration
+
+	// Claim names are dot-separated paths, e.g. "realm_access.roles".
+	UserIDClaim string
+	RolesClaim  string
+	TenantClaim string
+}
+
+// JWTAuthenticator validates "Authorization: Bearer" tokens against a JWKS
+// and maps their claims to a Principal.
+type JWTAuthenticator struct {
+	cfg    JWTConfig
+	keys   *KeySet
+	parser *jwt.Parser
+}
+
+// NewJWTAuthenticator requires an issuer and an audience: without them any
+// token signed by a key of the JWKS, including ones minted for other
+// services, would be accepted.
+func NewJWTAuthenticator(cfg JWTConfig, keys *KeySet) (*JWTAuthenticator, error) {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("a JWT issuer and audience are required")
+	}
+	if len(cfg.Algorithms) == 0 {
+		return nil, errors.New("at least one JWT algorithm must be allowed")
+	}
+	for _, alg := range cfg.Algorithms {
+		switch alg {
+		case "RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA":
+		default:
+			return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+		}
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(cfg.Algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+	)
+	return &JWTAuthenticator{cfg: cfg, keys: keys, parser: parser}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	scheme, raw, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(strings.TrimSpace(raw), claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return a.keys.Key(r.Context(), kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	return a.principal(claims)
+}
+
+func (a *JWTAuthenticator) principal(claims jwt.MapClaims) (*Principal, error) {
+	subject, _ := claims["sub"].(string)
+	principal := &Principal{Subject: subject}
+
+	switch v := claimValue(claims, a.cfg.UserIDClaim).(type) {
+	case string:
+		if v != "" {
+			userID, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("claim %q is not a numeric user ID", a.cfg.UserIDClaim)
+			}
+			principal.UserID = userID
+		}
+	case float64:
+		if v < 0 || v != float64(uint64(v)) {
+			return nil, fmt.Errorf("claim %q is not a numeric user ID", a.cfg.UserIDClaim)
+		}
+		principal.UserID = uint64(v)
+	}
+
+	switch v := claimValue(claims, a.cfg.RolesClaim).(type) {
+	case string:
+		principal.Roles = splitList(strings.ReplaceAll(v, ",", " "), " ")
+	case []interface{}:
+		for _, role := range v {
+			if s, ok := role.(string); ok && s != "" {
+				principal.Roles = append(principal.Roles, s)
+			}
+		}
+	}
+	// A user token without roles gets the least privileged one.
+	if len(principal.Roles) == 0 {
+		principal.Roles = []string{RoleApplicant}
+	}
+
+	if tenantID, ok := claimValue(claims, a.cfg.TenantClaim).(string); ok {
+		principal.TenantID = tenantID
+	}
+	return principal, nil
+}
+
+// claimValue follows a dot-separated path through nested claims.
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var current interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
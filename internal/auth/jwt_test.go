@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testJWKS serves the public halves of its keys as a JWKS document and
+// counts how often it was fetched.
+type testJWKS struct {
+	mu      sync.Mutex
+	keys    map[string]ed25519.PrivateKey
+	fetches atomic.Int32
+}
+
+func newTestJWKS(t *testing.T, kids ...string) *testJWKS {
+	s := &testJWKS{keys: map[string]ed25519.PrivateKey{}}
+	for _, kid := range kids {
+		s.add(t, kid)
+	}
+	return s
+}
+
+func (s *testJWKS) add(t *testing.T, kid string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+}
+
+func (s *testJWKS) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, key := range s.keys {
+		doc.Keys = append(doc.Keys, jsonWebKey{
+			Kty: "OKP",
+			Kid: kid,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		})
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (s *testJWKS) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	s.mu.Lock()
+	key := s.keys[kid]
+	s.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func testJWTConfig() JWTConfig {
+	return JWTConfig{
+		Issuer:      "https://issuer.example",
+		Audience:    "app-service",
+		Algorithms:  []string{"EdDSA"},
+		UserIDClaim: "sub",
+		RolesClaim:  "roles",
+		TenantClaim: "tenant_id",
+	}
+}
+
+func TestNewJWTAuthenticatorConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(*JWTConfig)
+		wantErr bool
+	}{
+		{"valid", func(*JWTConfig) {}, false},
+		{"every asymmetric algorithm", func(c *JWTConfig) {
+			c.Algorithms = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+		}, false},
+		{"no algorithms", func(c *JWTConfig) { c.Algorithms = nil }, true},
+		{"HMAC algorithm", func(c *JWTConfig) { c.Algorithms = []string{"HS256"} }, true},
+		{"none", func(c *JWTConfig) { c.Algorithms = []string{"none"} }, true},
+		{"no issuer", func(c *JWTConfig) { c.Issuer = "" }, true},
+		{"no audience", func(c *JWTConfig) { c.Audience = "" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJWTConfig()
+			tt.edit(&cfg)
+			if _, err := NewJWTAuthenticator(cfg, nil); (err != nil) != tt.wantErr {
+				t.Errorf("NewJWTAuthenticator() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	jwks := newTestJWKS(t, "k1")
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+	keys, err := NewKeySet(server.URL, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewJWTAuthenticator(testJWTConfig(), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://issuer.example",
+			"aud":   "app-service",
+			"sub":   "42",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{RoleAdmin},
+		}
+	}
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{"valid", func() string { return jwks.sign(t, "k1", valid()) }, false},
+		{"other issuer", func() string {
+			claims := valid()
+			claims["iss"] = "https://other.example"
+			return jwks.sign(t, "k1", claims)
+		}, true},
+		{"other audience", func() string {
+			claims := valid()
+			claims["aud"] = "other-service"
+			return jwks.sign(t, "k1", claims)
+		}, true},
+		{"expired", func() string {
+			claims := valid()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return jwks.sign(t, "k1", claims)
+		}, true},
+		{"no expiry", func() string {
+			claims := valid()
+			delete(claims, "exp")
+			return jwks.sign(t, "k1", claims)
+		}, true},
+		{"algorithm not allowed", func() string { return hs256 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token())
+			principal, err := a.Authenticate(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (principal.UserID != 42 || !principal.HasRole(RoleAdmin)) {
+				t.Errorf("principal = %+v", principal)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	if _, err := a.Authenticate(req); err != ErrNoCredentials {
+		t.Errorf("Authenticate() without a bearer token = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestJWTPrincipal(t *testing.T) {
+	cfg := testJWTConfig()
+	cfg.UserIDClaim = "ext.user_id"
+	cfg.RolesClaim = "realm_access.roles"
+	cfg.TenantClaim = "org.tenant"
+	a, err := NewJWTAuthenticator(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    *Principal
+		wantErr bool
+	}{
+		{
+			"nested claims",
+			jwt.MapClaims{
+				"sub":          "alice",
+				"ext":          map[string]interface{}{"user_id": "7"},
+				"realm_access": map[string]interface{}{"roles": []interface{}{RoleAdmin, "", RoleApplicant}},
+				"org":          map[string]interface{}{"tenant": "acme"},
+			},
+			&Principal{Subject: "alice", UserID: 7, Roles: []string{RoleAdmin, RoleApplicant}, TenantID: "acme"},
+			false,
+		},
+		{
+			"numeric user ID and space-separated roles",
+			jwt.MapClaims{
+				"sub":          "bob",
+				"ext":          map[string]interface{}{"user_id": float64(8)},
+				"realm_access": map[string]interface{}{"roles": "admin, applicant"},
+			},
+			&Principal{Subject: "bob", UserID: 8, Roles: []string{RoleAdmin, RoleApplicant}},
+			false,
+		},
+		{
+			"no roles default to applicant",
+			jwt.MapClaims{"sub": "carol"},
+			&Principal{Subject: "carol", Roles: []string{RoleApplicant}},
+			false,
+		},
+		{
+			"claim path through a non-object",
+			jwt.MapClaims{"sub": "dave", "realm_access": "admin"},
+			&Principal{Subject: "dave", Roles: []string{RoleApplicant}},
+			false,
+		},
+		{"non-numeric user ID", jwt.MapClaims{"ext": map[string]interface{}{"user_id": "alice"}}, nil, true},
+		{"negative user ID", jwt.MapClaims{"ext": map[string]interface{}{"user_id": float64(-1)}}, nil, true},
+		{"fractional user ID", jwt.MapClaims{"ext": map[string]interface{}{"user_id": 1.5}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.principal(tt.claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("principal() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("principal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySetRefresh(t *testing.T) {
+	jwks := newTestJWKS(t, "k1")
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+	ctx := context.Background()
+
+	keys, err := NewKeySet(server.URL, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := jwks.fetches.Load(); n != 1 {
+		t.Fatalf("fetched %d times on load, want once", n)
+	}
+	if _, err := keys.Key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Key(ctx, ""); err != nil {
+		t.Errorf("empty kid with a single key: %v", err)
+	}
+
+	// A rotated key is not fetched again right after a load.
+	jwks.add(t, "k2")
+	if _, err := keys.Key(ctx, "k2"); err == nil {
+		t.Error("found k2 without refetching")
+	}
+	if n := jwks.fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times within minForcedRefresh, want once", n)
+	}
+
+	// Past minForcedRefresh an unknown kid refetches once.
+	keys.mu.Lock()
+	keys.lastAttempt = time.Now().Add(-2 * minForcedRefresh)
+	keys.mu.Unlock()
+	if _, err := keys.Key(ctx, "k2"); err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+	if _, err := keys.Key(ctx, "made-up"); err == nil {
+		t.Error("found a made-up kid")
+	}
+	if n := jwks.fetches.Load(); n != 2 {
+		t.Errorf("fetched %d times, want 2", n)
+	}
+
+	// Stale keys are refreshed, and still served when the refresh fails.
+	keys.mu.Lock()
+	keys.loadedAt = time.Now().Add(-2 * time.Hour)
+	keys.lastAttempt = keys.loadedAt
+	keys.mu.Unlock()
+	server.Close()
+	if _, err := keys.Key(ctx, "k1"); err != nil {
+		t.Errorf("stale key after a failed refresh: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"gorm.io/gorm"
+)
+
+func TestParseManagedKey(t *testing.T) {
+	tests := []struct {
+		key        string
+		wantPrefix string
+		wantOK     bool
+	}{
+		{"aps_0a1b2c_c2VjcmV0", "0a1b2c", true},
+		// The secret is base64url and may contain underscores itself.
+		{"aps_0a1b2c_sec_ret", "0a1b2c", true},
+		{"aps__secret", "", false},
+		{"aps_0a1b2c_", "", false},
+		{"aps_0a1b2c", "", false},
+		{"key_0a1b2c_secret", "", false},
+		{"shared-api-key", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		prefix, ok := parseManagedKey(tt.key)
+		if prefix != tt.wantPrefix || ok != tt.wantOK {
+			t.Errorf("parseManagedKey(%q) = %q, %v, want %q, %v", tt.key, prefix, ok, tt.wantPrefix, tt.wantOK)
+		}
+	}
+
+	key, prefix, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := parseManagedKey(key); !ok || got != prefix {
+		t.Errorf("parseManagedKey(GenerateAPIKey()) = %q, %v, want %q", got, ok, prefix)
+	}
+}
+
+// memoryAPIKeys is an APIKeyStore over a map keyed by prefix.
+type memoryAPIKeys struct {
+	mu      sync.Mutex
+	keys    map[string]domain.APIKey
+	touched []uint64
+}
+
+func (m *memoryAPIKeys) GetByPrefix(_ context.Context, prefix string) (*domain.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[prefix]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &key, nil
+}
+
+func (m *memoryAPIKeys) TouchLastUsed(_ context.Context, id uint64, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.touched = append(m.touched, id)
+	return nil
+}
+
+func TestManagedKeyAuthenticator(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	store := &memoryAPIKeys{keys: map[string]domain.APIKey{}}
+	secrets := map[string]string{}
+	add := func(name string, key domain.APIKey) {
+		secret, prefix, err := GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		key.Prefix = prefix
+		key.KeyHash = HashAPIKey(secret)
+		store.keys[prefix] = key
+		secrets[name] = secret
+	}
+	add("valid", domain.APIKey{ID: 1, TenantID: "acme", Scopes: []string{ScopeApplicationsRead}, ExpiresAt: &future, LastUsedAt: &now})
+	add("expired", domain.APIKey{ID: 2, Scopes: []string{ScopeApplicationsRead}, ExpiresAt: &past})
+	add("revoked", domain.APIKey{ID: 3, Scopes: []string{ScopeApplicationsRead}, RevokedAt: &past})
+	_, unknownPrefix, _ := GenerateAPIKey()
+	valid, _ := parseManagedKey(secrets["valid"])
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{"valid", secrets["valid"], nil},
+		{"expired", secrets["expired"], errAny},
+		{"revoked", secrets["revoked"], errAny},
+		{"wrong secret", "aps_" + valid + "_wrong", errAny},
+		{"unknown prefix", "aps_" + unknownPrefix + "_secret", errAny},
+		{"shared key format", "shared-api-key", ErrNoCredentials},
+		{"no key", "", ErrNoCredentials},
+	}
+	a := NewManagedKeyAuthenticator(store)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			principal, err := a.Authenticate(req)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Authenticate() error = %v", err)
+			case tt.wantErr == errAny && (err == nil || errors.Is(err, ErrNoCredentials)):
+				t.Fatalf("Authenticate() error = %v, want a rejection", err)
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := &Principal{Subject: "api-key:1", Roles: []string{RoleAdmin}, TenantID: "acme", Scopes: []string{ScopeApplicationsRead}}
+			if !reflect.DeepEqual(principal, want) {
+				t.Errorf("principal = %+v, want %+v", principal, want)
+			}
+		})
+	}
+	// The valid key was used a moment ago, so its use is not written again.
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.touched) != 0 {
+		t.Errorf("last use recorded for %v within lastUsedResolution", store.touched)
+	}
+}
+
+// errAny stands for any error other than ErrNoCredentials.
+var errAny = errors.New("any error")
@@ -0,0 +1,79 @@
+package auth
+
+import "context"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject  string   `json:"subject"`
+	UserID   uint64   `json:"userId,omitempty"`
+	Roles    []string `json:"roles"`
+	TenantID string   `json:"tenantId,omitempty"`
+	// Scopes limits what a credential may do. Nil means the principal is
+	// not scope-limited, as with user tokens and the shared API key.
+	Scopes []string `json:"scopes,omitempty"`
+	// CrossTenant marks credentials that may pick the tenant of a request
+	// with the X-Tenant-ID header. Other credentials without a tenant are
+	// pinned to the default tenant.
+	CrossTenant bool `json:"crossTenant,omitempty"`
+}
+
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOwnerRestricted reports whether the principal is restricted to its own
+// applications, which is the case unless it holds a staff role; roles this
+// service does not know never lift the restriction. A nil principal stands
+// for the service itself, as in background jobs, and is not restricted.
+func (p *Principal) IsOwnerRestricted() bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range staffRoles {
+		if p.HasRole(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Owns reports whether the principal is the applicant owning userID's data.
+func (p *Principal) Owns(userID uint64) bool {
+	return p != nil && p.UserID != 0 && p.UserID == userID
+}
+
+type ctxKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx, or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,48 @@
+package auth
+
+import "testing"
+
+func TestIsOwnerRestricted(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *Principal
+		want      bool
+	}{
+		{"service", nil, false},
+		{"applicant", &Principal{Roles: []string{RoleApplicant}}, true},
+		{"applicant with unknown role", &Principal{Roles: []string{RoleApplicant, "newsletter"}}, true},
+		{"unknown role only", &Principal{Roles: []string{"newsletter"}}, true},
+		{"no roles", &Principal{}, true},
+		{"reviewer", &Principal{Roles: []string{RoleReviewer}}, false},
+		{"applicant and supervisor", &Principal{Roles: []string{RoleApplicant, RoleSupervisor}}, false},
+		{"admin", &Principal{Roles: []string{RoleAdmin}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.IsOwnerRestricted(); got != tt.want {
+				t.Errorf("IsOwnerRestricted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanDeleteReviewedDocuments(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *Principal
+		want      bool
+	}{
+		{"applicant", &Principal{Roles: []string{RoleApplicant}}, false},
+		{"reviewer", &Principal{Roles: []string{RoleReviewer}}, false},
+		{"supervisor", &Principal{Roles: []string{RoleSupervisor}}, true},
+		{"admin", &Principal{Roles: []string{RoleAdmin}}, true},
+		{"supervisor key without the scope", &Principal{Roles: []string{RoleSupervisor}, Scopes: []string{ScopeDocumentsWrite}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.Can(ScopeDocumentsDelete); got != tt.want {
+				t.Errorf("Can(%q) = %v, want %v", ScopeDocumentsDelete, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+const (
+	// RoleApplicant callers may only see and act on their own applications.
+	RoleApplicant  = "applicant"
+	RoleReviewer   = "reviewer"
+	RoleSupervisor = "supervisor"
+	RoleAdmin      = "admin"
+)
+
+// staffRoles may see applications other than their own.
+var staffRoles = []string{RoleReviewer, RoleSupervisor, RoleAdmin}
+
+var (
+	applicantPermissions = []string{
+		ScopeApplicationsRead,
+		ScopeApplicationsWrite,
+		ScopeStatusesRead,
+		ScopeStatusesWrite,
+		ScopeFileTypesRead,
+		ScopeFileTypesWrite,
+		ScopeDocumentsRead,
+		ScopeDocumentsWrite,
+	}
+	reviewerPermissions = append(append([]string{}, applicantPermissions...),
+		ScopeDocumentsReview,
+	)
+	supervisorPermissions = append(append([]string{}, reviewerPermissions...),
+		ScopeApplicationsDelete,
+		ScopeApplicationsMerge,
+		ScopeStatusesTerminal,
+		ScopeDocumentsDelete,
+		ScopeDocumentsScan,
+		ScopeDocumentTypesManage,
+		ScopePoliciesExplain,
+	)
+)
+
+// rolePermissions is the permission matrix. Reviewers have the applicants'
+// permissions, which for applicants are limited to their own applications,
+// and may review documents; only supervisors may delete, merge or close
+// applications, delete reviewed documents, rescan documents and manage the
+// document type catalog. Retention and legal holds are left to admins.
+var rolePermissions = map[string][]string{
+	RoleApplicant:  applicantPermissions,
+	RoleReviewer:   reviewerPermissions,
+	RoleSupervisor: supervisorPermissions,
+	RoleAdmin:      AllScopes,
+}
+
+// Can reports whether the principal's roles grant the permission and, for a
+// scope-limited credential, whether it was granted that scope.
+func (p *Principal) Can(permission string) bool {
+	if p == nil || !p.HasScope(permission) {
+		return false
+	}
+	for _, role := range p.Roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Permissions returns the principal's effective permissions.
+func (p *Principal) Permissions() []string {
+	permissions := []string{}
+	for _, permission := range AllScopes {
+		if p.Can(permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
@@ -0,0 +1,66 @@
+package auth
+
+// Permissions are named "<resource>:<action>". Roles grant sets of them, and
+// managed API keys are granted them directly as scopes.
+const (
+	ScopeApplicationsRead   = "applications:read"
+	ScopeApplicationsWrite  = "applications:write"
+	ScopeApplicationsDelete = "applications:delete"
+	ScopeApplicationsMerge  = "applications:merge"
+	ScopeStatusesRead       = "statuses:read"
+	ScopeStatusesWrite      = "statuses:write"
+	// ScopeStatusesTerminal allows moving an application to a terminal
+	// status of its workflow.
+	ScopeStatusesTerminal = "statuses:terminal"
+	ScopeFileTypesRead    = "filetypes:read"
+	ScopeFileTypesWrite   = "filetypes:write"
+	// ScopeDocumentTypesManage allows changing the document type catalog.
+	ScopeDocumentTypesManage = "documenttypes:manage"
+	ScopeDocumentsRead       = "documents:read"
+	ScopeDocumentsWrite      = "documents:write"
+	// ScopeDocumentsDelete allows deleting documents with a version that
+	// was already reviewed; documents:write only deletes unreviewed ones.
+	ScopeDocumentsDelete = "documents:delete"
+	// ScopeDocumentsScan allows queueing documents for another malware scan.
+	ScopeDocumentsScan = "documents:scan"
+	// ScopeDocumentsReview allows accepting and rejecting documents.
+	ScopeDocumentsReview = "documents:review"
+	ScopeAPIKeysManage   = "apikeys:manage"
+	// ScopePoliciesExplain allows asking how the authorization policies
+	// decide an action, for any principal.
+	ScopePoliciesExplain = "policies:explain"
+	// ScopeRetentionManage allows placing and releasing legal holds and
+	// reporting what retention would purge.
+	ScopeRetentionManage = "retention:manage"
+)
+
+// AllScopes lists every permission, in a stable order.
+var AllScopes = []string{
+	ScopeApplicationsRead,
+	ScopeApplicationsWrite,
+	ScopeApplicationsDelete,
+	ScopeApplicationsMerge,
+	ScopeStatusesRead,
+	ScopeStatusesWrite,
+	ScopeStatusesTerminal,
+	ScopeFileTypesRead,
+	ScopeFileTypesWrite,
+	ScopeDocumentTypesManage,
+	ScopeDocumentsRead,
+	ScopeDocumentsWrite,
+	ScopeDocumentsDelete,
+	ScopeDocumentsScan,
+	ScopeDocumentsReview,
+	ScopeAPIKeysManage,
+	ScopePoliciesExplain,
+	ScopeRetentionManage,
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_applications_name_trgm;
+ALTER TABLE applications DROP COLUMN IF EXISTS possible_duplicate;
@@ -0,0 +1,6 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE applications ADD COLUMN possible_duplicate BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Trigram index backing the similarity() lookups done on create
+CREATE INDEX idx_applications_name_trgm ON applications USING GIN (name gin_trgm_ops);
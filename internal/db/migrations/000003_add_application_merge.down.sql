@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_applications_merged_into_id;
+ALTER TABLE applications
+    DROP CONSTRAINT IF EXISTS fk_applications_merged_into,
+    DROP COLUMN IF EXISTS merged_at,
+    DROP COLUMN IF EXISTS merged_into_id;
@@ -0,0 +1,9 @@
+ALTER TABLE applications
+    ADD COLUMN merged_into_id BIGINT,
+    ADD COLUMN merged_at TIMESTAMPTZ,
+    ADD CONSTRAINT fk_applications_merged_into
+        FOREIGN KEY(merged_into_id)
+        REFERENCES applications(id)
+        ON DELETE SET NULL;
+
+CREATE INDEX idx_applications_merged_into_id ON applications(merged_into_id);
@@ -0,0 +1,12 @@
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (key);
+
+DROP INDEX IF EXISTS idx_application_file_type_tenant_id;
+DROP INDEX IF EXISTS idx_application_status_tenant_id;
+DROP INDEX IF EXISTS uq_applications_tenant_code;
+DROP INDEX IF EXISTS idx_applications_tenant_id;
+
+ALTER TABLE application_uploaded_file_type DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE application_status DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE applications DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,23 @@
+-- The initial migration never created these although the model maps them.
+ALTER TABLE applications ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE applications ADD COLUMN IF NOT EXISTS code VARCHAR(50);
+UPDATE applications SET code = 'APP-' || id WHERE code IS NULL;
+ALTER TABLE applications ALTER COLUMN code SET NOT NULL;
+
+-- Existing rows belong to the default tenant; new rows must name theirs.
+ALTER TABLE applications ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE applications ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE application_status ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE application_status ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE application_uploaded_file_type ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE application_uploaded_file_type ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE idempotency_keys ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE idempotency_keys ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX idx_applications_tenant_id ON applications(tenant_id);
+CREATE UNIQUE INDEX uq_applications_tenant_code ON applications(tenant_id, code);
+CREATE INDEX idx_application_status_tenant_id ON application_status(tenant_id);
+CREATE INDEX idx_application_file_type_tenant_id ON application_uploaded_file_type(tenant_id);
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, key);
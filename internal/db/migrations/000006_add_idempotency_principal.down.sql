@@ -0,0 +1,7 @@
+-- Keys of different callers may collide once the column is gone; they are
+-- short-lived, so drop them.
+DELETE FROM idempotency_keys;
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS principal;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, key);
@@ -0,0 +1,6 @@
+-- Keys are per caller: existing keys were recorded without one.
+ALTER TABLE idempotency_keys ADD COLUMN principal VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys ALTER COLUMN principal DROP DEFAULT;
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, principal, key);
@@ -0,0 +1,23 @@
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    rotated_from_id BIGINT,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT uq_api_keys_prefix UNIQUE(prefix),
+
+    CONSTRAINT fk_api_keys_rotated_from
+        FOREIGN KEY(rotated_from_id)
+        REFERENCES api_keys(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX idx_api_keys_tenant_id ON api_keys(tenant_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_applications_assignee_id;
+ALTER TABLE applications
+    DROP COLUMN IF EXISTS assignee_id;
@@ -0,0 +1,4 @@
+ALTER TABLE applications
+    ADD COLUMN assignee_id BIGINT;
+
+CREATE INDEX idx_applications_assignee_id ON applications(assignee_id);
@@ -0,0 +1,13 @@
+-- Nonces of signed requests are remembered here, so a signature is accepted
+-- once across all instances. Rows can go once expires_at passed: the
+-- signature's timestamp is outside the allowed clock skew by then.
+CREATE TABLE hmac_nonces (
+    client_id VARCHAR(255) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (client_id, nonce)
+);
+
+CREATE INDEX idx_hmac_nonces_expires_at ON hmac_nonces(expires_at);
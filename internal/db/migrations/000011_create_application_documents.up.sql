@@ -0,0 +1,29 @@
+CREATE TABLE application_documents (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    application_id BIGINT NOT NULL,
+    file_type_id BIGINT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    storage_key VARCHAR(512) NOT NULL,
+    uploaded_by BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_application_documents_application
+        FOREIGN KEY(application_id)
+        REFERENCES applications(id)
+        ON DELETE CASCADE,
+
+    -- A file type with documents cannot be removed; its documents have to
+    -- be deleted first so their blobs are cleaned up.
+    CONSTRAINT fk_application_documents_file_type
+        FOREIGN KEY(file_type_id)
+        REFERENCES application_uploaded_file_type(id)
+        ON DELETE RESTRICT
+);
+
+CREATE INDEX idx_application_documents_tenant_id ON application_documents(tenant_id);
+CREATE INDEX idx_application_documents_application_id ON application_documents(application_id);
+CREATE INDEX idx_application_documents_file_type_id ON application_documents(file_type_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_applications_category;
+ALTER TABLE applications
+    DROP COLUMN IF EXISTS category;
@@ -0,0 +1,4 @@
+ALTER TABLE applications
+    ADD COLUMN category VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_applications_category ON applications(tenant_id, category);
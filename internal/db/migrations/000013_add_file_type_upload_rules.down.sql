@@ -0,0 +1,4 @@
+ALTER TABLE application_uploaded_file_type
+    DROP COLUMN IF EXISTS max_size,
+    DROP COLUMN IF EXISTS allowed_extensions,
+    DROP COLUMN IF EXISTS allowed_mime_types;
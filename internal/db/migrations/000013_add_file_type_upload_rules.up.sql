@@ -0,0 +1,4 @@
+ALTER TABLE application_uploaded_file_type
+    ADD COLUMN allowed_mime_types JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN allowed_extensions JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN max_size BIGINT NOT NULL DEFAULT 0;
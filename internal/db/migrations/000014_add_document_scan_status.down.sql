@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_application_documents_scan_pending;
+ALTER TABLE application_documents
+    DROP COLUMN IF EXISTS scanned_at,
+    DROP COLUMN IF EXISTS scan_signature,
+    DROP COLUMN IF EXISTS scan_status;
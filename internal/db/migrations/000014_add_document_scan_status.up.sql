@@ -0,0 +1,9 @@
+-- Documents uploaded before scanning existed are pending too, so the scan
+-- worker picks them up. scanned_at is the time of the last scan attempt.
+ALTER TABLE application_documents
+    ADD COLUMN scan_status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    ADD COLUMN scan_signature VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN scanned_at TIMESTAMPTZ;
+
+CREATE INDEX idx_application_documents_scan_pending ON application_documents(scanned_at NULLS FIRST, id)
+    WHERE scan_status = 'pending';
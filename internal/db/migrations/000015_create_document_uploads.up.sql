@@ -0,0 +1,37 @@
+CREATE TABLE document_uploads (
+    id VARCHAR(32) PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    application_id BIGINT NOT NULL,
+    file_type_id BIGINT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    length BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    checksum VARCHAR(64) NOT NULL DEFAULT '',
+    part_keys JSONB NOT NULL DEFAULT '[]',
+    uploaded_by BIGINT NOT NULL,
+    document_id BIGINT,
+    -- A request completing the upload claims it until finalizing_until, so
+    -- concurrent final chunks cannot turn it into two documents.
+    finalizing_until TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    -- The service removes the parts of an application's uploads before
+    -- deleting it. file_type_id has no foreign key: an upload whose file
+    -- type is deleted fails when it completes and is dropped then.
+    CONSTRAINT fk_document_uploads_application
+        FOREIGN KEY(application_id)
+        REFERENCES applications(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_document_uploads_document
+        FOREIGN KEY(document_id)
+        REFERENCES application_documents(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX idx_document_uploads_tenant_id ON document_uploads(tenant_id);
+CREATE INDEX idx_document_uploads_application_id ON document_uploads(application_id);
+CREATE INDEX idx_document_uploads_expires_at ON document_uploads(expires_at);
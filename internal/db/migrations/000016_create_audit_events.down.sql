@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS download_link_uses;
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,26 @@
+-- Audit events are kept when the application or document they refer to is
+-- deleted, so the ids carry no foreign keys.
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    application_id BIGINT,
+    document_id BIGINT,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_events_tenant_created ON audit_events(tenant_id, created_at);
+CREATE INDEX idx_audit_events_application_id ON audit_events(application_id);
+
+CREATE TABLE download_link_uses (
+    nonce VARCHAR(32) PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_download_link_uses_expires_at ON download_link_uses(expires_at);
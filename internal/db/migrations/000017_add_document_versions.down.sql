@@ -0,0 +1,14 @@
+ALTER TABLE document_uploads
+    DROP COLUMN IF EXISTS replaces_id;
+
+DROP INDEX IF EXISTS idx_application_documents_lineage_current;
+DROP INDEX IF EXISTS idx_application_documents_lineage_version;
+ALTER TABLE application_documents
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS reviewed_by,
+    DROP COLUMN IF EXISTS review_note,
+    DROP COLUMN IF EXISTS review_reason,
+    DROP COLUMN IF EXISTS review_status,
+    DROP COLUMN IF EXISTS is_current,
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS lineage_id;
@@ -0,0 +1,20 @@
+-- Every upload is a version. Versions of one document share lineage_id, the
+-- id of the first version; exactly one version per lineage is current.
+ALTER TABLE application_documents
+    ADD COLUMN lineage_id BIGINT,
+    ADD COLUMN version INT NOT NULL DEFAULT 1,
+    ADD COLUMN is_current BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN review_status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    ADD COLUMN review_reason VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN review_note TEXT NOT NULL DEFAULT '',
+    ADD COLUMN reviewed_by BIGINT,
+    ADD COLUMN reviewed_at TIMESTAMPTZ;
+
+UPDATE application_documents SET lineage_id = id;
+ALTER TABLE application_documents ALTER COLUMN lineage_id SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_application_documents_lineage_version ON application_documents(lineage_id, version);
+CREATE UNIQUE INDEX idx_application_documents_lineage_current ON application_documents(lineage_id) WHERE is_current;
+
+ALTER TABLE document_uploads
+    ADD COLUMN replaces_id BIGINT;
@@ -0,0 +1,21 @@
+-- Export jobs list the applications they cover when they are requested, so
+-- the ids carry no foreign keys.
+CREATE TABLE export_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    requested_by VARCHAR(255) NOT NULL,
+    application_ids JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    error TEXT NOT NULL DEFAULT '',
+    document_count INT NOT NULL DEFAULT 0,
+    size BIGINT NOT NULL DEFAULT 0,
+    storage_key VARCHAR(512) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_export_jobs_tenant_id ON export_jobs(tenant_id);
+CREATE INDEX idx_export_jobs_status ON export_jobs(status);
+CREATE INDEX idx_export_jobs_expires_at ON export_jobs(expires_at);
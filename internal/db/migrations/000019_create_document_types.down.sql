@@ -0,0 +1,20 @@
+ALTER TABLE application_uploaded_file_type
+    ADD COLUMN allowed_mime_types JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN allowed_extensions JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN max_size BIGINT NOT NULL DEFAULT 0;
+
+UPDATE application_uploaded_file_type f
+SET file_type_name = t.display_name,
+    allowed_mime_types = t.allowed_mime_types,
+    allowed_extensions = t.allowed_extensions,
+    max_size = t.max_size
+FROM document_types t
+WHERE t.id = f.document_type_id;
+
+DROP INDEX IF EXISTS idx_application_file_type_document_type_id;
+ALTER TABLE application_uploaded_file_type
+    DROP CONSTRAINT IF EXISTS uq_application_file_type_document_type,
+    DROP CONSTRAINT IF EXISTS fk_application_file_type_document_type,
+    DROP COLUMN IF EXISTS document_type_id;
+
+DROP TABLE IF EXISTS document_types;
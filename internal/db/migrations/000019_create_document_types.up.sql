@@ -0,0 +1,77 @@
+CREATE TABLE document_types (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    key VARCHAR(100) NOT NULL,
+    display_name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    allowed_mime_types JSONB NOT NULL DEFAULT '[]',
+    allowed_extensions JSONB NOT NULL DEFAULT '[]',
+    max_size BIGINT NOT NULL DEFAULT 0,
+    retention_days INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT uq_document_types_tenant_key
+        UNIQUE(tenant_id, key)
+);
+
+CREATE INDEX idx_document_types_tenant_id ON document_types(tenant_id);
+
+-- Existing free-text names are mapped onto one catalog entry per tenant and
+-- normalized name, so "Passport" and "passport " become the key "passport".
+-- Names that differ in wording, like "Passport copy", get entries of their
+-- own. An entry takes the display name and upload rules of the oldest file
+-- type mapped onto it.
+CREATE TEMP TABLE file_type_keys AS
+SELECT id, tenant_id,
+       COALESCE(NULLIF(TRIM(BOTH '_' FROM REGEXP_REPLACE(LOWER(file_type_name), '[^a-z0-9]+', '_', 'g')), ''), 'unnamed') AS key
+FROM application_uploaded_file_type;
+
+INSERT INTO document_types (tenant_id, key, display_name, allowed_mime_types, allowed_extensions, max_size)
+SELECT DISTINCT ON (k.tenant_id, k.key) k.tenant_id, k.key, TRIM(f.file_type_name), f.allowed_mime_types, f.allowed_extensions, f.max_size
+FROM file_type_keys k
+JOIN application_uploaded_file_type f ON f.id = k.id
+ORDER BY k.tenant_id, k.key, f.id;
+
+ALTER TABLE application_uploaded_file_type ADD COLUMN document_type_id BIGINT;
+
+UPDATE application_uploaded_file_type f
+SET document_type_id = t.id
+FROM file_type_keys k
+JOIN document_types t ON t.tenant_id = k.tenant_id AND t.key = k.key
+WHERE f.id = k.id;
+
+-- File types of one application that now map onto the same entry are
+-- merged into the oldest one.
+CREATE TEMP TABLE file_type_merges AS
+SELECT id, keep_id FROM (
+    SELECT id, MIN(id) OVER (PARTITION BY application_id, document_type_id) AS keep_id
+    FROM application_uploaded_file_type
+) m
+WHERE id <> keep_id;
+
+UPDATE application_documents d SET file_type_id = m.keep_id FROM file_type_merges m WHERE d.file_type_id = m.id;
+UPDATE document_uploads u SET file_type_id = m.keep_id FROM file_type_merges m WHERE u.file_type_id = m.id;
+DELETE FROM application_uploaded_file_type f USING file_type_merges m WHERE f.id = m.id;
+
+UPDATE application_uploaded_file_type f
+SET file_type_name = t.key
+FROM document_types t
+WHERE t.id = f.document_type_id;
+
+DROP TABLE file_type_merges;
+DROP TABLE file_type_keys;
+
+ALTER TABLE application_uploaded_file_type
+    ALTER COLUMN document_type_id SET NOT NULL,
+    ADD CONSTRAINT fk_application_file_type_document_type
+        FOREIGN KEY(document_type_id)
+        REFERENCES document_types(id)
+        ON DELETE RESTRICT,
+    ADD CONSTRAINT uq_application_file_type_document_type
+        UNIQUE(application_id, document_type_id),
+    DROP COLUMN allowed_mime_types,
+    DROP COLUMN allowed_extensions,
+    DROP COLUMN max_size;
+
+CREATE INDEX idx_application_file_type_document_type_id ON application_uploaded_file_type(document_type_id);
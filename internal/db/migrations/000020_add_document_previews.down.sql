@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_application_documents_preview_pending;
+ALTER TABLE application_documents
+    DROP COLUMN IF EXISTS previewed_at,
+    DROP COLUMN IF EXISTS preview_keys,
+    DROP COLUMN IF EXISTS preview_status;
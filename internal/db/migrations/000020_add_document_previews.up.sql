@@ -0,0 +1,12 @@
+-- Existing images and PDFs are pending, so the preview worker picks them up
+-- once they are scanned clean. previewed_at is the time of the last attempt.
+ALTER TABLE application_documents
+    ADD COLUMN preview_status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    ADD COLUMN preview_keys JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN previewed_at TIMESTAMPTZ;
+
+UPDATE application_documents SET preview_status = 'unsupported'
+WHERE content_type NOT IN ('image/jpeg', 'image/png', 'image/webp', 'application/pdf');
+
+CREATE INDEX idx_application_documents_preview_pending ON application_documents(previewed_at NULLS FIRST, id)
+    WHERE preview_status = 'pending' AND scan_status = 'clean';
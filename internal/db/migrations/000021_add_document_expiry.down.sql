@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_applications_document_state;
+DROP INDEX IF EXISTS idx_application_documents_valid_until;
+ALTER TABLE applications
+    DROP COLUMN IF EXISTS document_state;
+ALTER TABLE document_uploads
+    DROP COLUMN IF EXISTS valid_until;
+ALTER TABLE application_documents
+    DROP COLUMN IF EXISTS valid_until;
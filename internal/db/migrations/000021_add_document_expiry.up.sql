@@ -0,0 +1,16 @@
+-- valid_until is the last day a document, e.g. an ID card, is valid.
+-- applications.document_state is set by the expiry check to 'expiring' or
+-- 'expired' while a current document of the application is.
+ALTER TABLE application_documents
+    ADD COLUMN valid_until DATE;
+
+ALTER TABLE document_uploads
+    ADD COLUMN valid_until DATE;
+
+ALTER TABLE applications
+    ADD COLUMN document_state VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_application_documents_valid_until ON application_documents(tenant_id, valid_until)
+    WHERE valid_until IS NOT NULL AND is_current;
+CREATE INDEX idx_applications_document_state ON applications(tenant_id, document_state)
+    WHERE document_state <> '';
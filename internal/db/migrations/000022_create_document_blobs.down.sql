@@ -0,0 +1,11 @@
+-- Documents sharing a blob cannot get copies of their own back from SQL,
+-- and deleting one of them would remove the content of the others. Refuse
+-- until the shared content was copied to keys of their own.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM application_documents GROUP BY storage_key HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'documents share stored content; give each document a copy of its own before migrating down';
+    END IF;
+END $$;
+
+DROP TABLE IF EXISTS document_blobs;
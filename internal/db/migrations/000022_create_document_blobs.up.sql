@@ -0,0 +1,40 @@
+-- Document content is stored once per tenant and checksum. Every document
+-- version referencing a blob counts in ref_count; blobs without references
+-- since unreferenced_at are collected after a grace period. stored is set
+-- once the content is in the blob store.
+CREATE TABLE document_blobs (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    checksum CHAR(64),
+    storage_key VARCHAR(512) NOT NULL UNIQUE,
+    size BIGINT NOT NULL,
+    ref_count INT NOT NULL DEFAULT 0,
+    stored BOOLEAN NOT NULL DEFAULT FALSE,
+    unreferenced_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX uq_document_blobs_tenant_checksum ON document_blobs(tenant_id, checksum);
+CREATE INDEX idx_document_blobs_unreferenced ON document_blobs(unreferenced_at) WHERE ref_count = 0;
+
+-- The oldest copy of each content becomes its blob and the documents holding
+-- other copies move to it.
+INSERT INTO document_blobs (tenant_id, checksum, storage_key, size, stored)
+SELECT DISTINCT ON (tenant_id, checksum) tenant_id, checksum, storage_key, size, TRUE
+FROM application_documents
+ORDER BY tenant_id, checksum, id;
+
+-- The other copies are garbage. They have no checksum, so they are never
+-- reused.
+INSERT INTO document_blobs (tenant_id, storage_key, size, stored, unreferenced_at)
+SELECT DISTINCT ON (d.storage_key) d.tenant_id, d.storage_key, d.size, TRUE, NOW()
+FROM application_documents d
+WHERE NOT EXISTS (SELECT 1 FROM document_blobs b WHERE b.storage_key = d.storage_key);
+
+UPDATE application_documents d SET storage_key = b.storage_key
+FROM document_blobs b
+WHERE b.tenant_id = d.tenant_id AND b.checksum = d.checksum AND b.storage_key <> d.storage_key;
+
+UPDATE document_blobs b SET ref_count = c.refs
+FROM (SELECT storage_key, COUNT(*) AS refs FROM application_documents GROUP BY storage_key) c
+WHERE c.storage_key = b.storage_key;
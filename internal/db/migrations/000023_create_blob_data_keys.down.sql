@@ -0,0 +1,2 @@
+-- Blobs encrypted while the table existed can no longer be read.
+DROP TABLE IF EXISTS blob_data_keys;
@@ -0,0 +1,15 @@
+-- Encrypted blobs start with the ID of their data key. The data key is kept
+-- here wrapped by the master key master_key_id, so rotating master keys only
+-- rewraps these rows. A blob stored again under the same key gets a new data
+-- key; all of them go when the blob is deleted.
+CREATE TABLE blob_data_keys (
+    id CHAR(32) PRIMARY KEY,
+    storage_key VARCHAR(512) NOT NULL,
+    master_key_id VARCHAR(64) NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    rewrapped_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_blob_data_keys_storage_key ON blob_data_keys(storage_key);
+CREATE INDEX idx_blob_data_keys_master_key ON blob_data_keys(master_key_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_application_status_latest;
+DROP INDEX IF EXISTS idx_applications_legal_hold;
+
+ALTER TABLE applications
+    DROP COLUMN IF EXISTS legal_hold_at,
+    DROP COLUMN IF EXISTS legal_hold_by,
+    DROP COLUMN IF EXISTS legal_hold_reason,
+    DROP COLUMN IF EXISTS legal_hold;
@@ -0,0 +1,11 @@
+-- Applications on legal hold are never purged by retention, nor deleted.
+ALTER TABLE applications
+    ADD COLUMN legal_hold BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN legal_hold_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN legal_hold_by BIGINT,
+    ADD COLUMN legal_hold_at TIMESTAMPTZ;
+
+CREATE INDEX idx_applications_legal_hold ON applications(tenant_id) WHERE legal_hold;
+
+-- Retention finds when applications closed from their latest status.
+CREATE INDEX idx_application_status_latest ON application_status(tenant_id, application_id, created_at DESC, id DESC);
@@ -1,20 +1,53 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/Naomejoy/app-service/internal/auth"
 	"github.com/gin-gonic/gin"
 )
 
-func APIKeyAuthMiddleware(apiKey string) gin.HandlerFunc {
+// PrincipalKey holds the *auth.Principal on the gin context.
+const PrincipalKey = "principal"
+
+// AuthMiddleware authenticates the request with the first authenticator that
+// recognises its credential and rejects it when none does.
+func AuthMiddleware(authenticators ...auth.Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		key := c.GetHeader("X-API-KEY")
-		if key != apiKey {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "unauthorized",
-			})
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(c.Request)
+			if errors.Is(err, auth.ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": err.Error()})
+				return
+			}
+			setPrincipal(c, principal)
+			c.Next()
 			return
 		}
-		c.Next()
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+	}
+}
+
+// GetPrincipal returns the principal stored by AuthMiddleware, or nil.
+func GetPrincipal(c *gin.Context) *auth.Principal {
+	principal, _ := c.Get(PrincipalKey)
+	p, _ := principal.(*auth.Principal)
+	return p
+}
+
+// setPrincipal stores the authenticated principal on the gin context and on
+// the request context, where the service layer reads it.
+func setPrincipal(c *gin.Context, principal *auth.Principal) {
+	c.Set(PrincipalKey, principal)
+	if principal.TenantID != "" {
+		c.Set(CredentialTenantKey, principal.TenantID)
 	}
+	c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
 }
@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+
+	// How long a request waits for another instance to finish a request
+	// holding the same key before giving up with 409.
+	idempotencyWaitTimeout  = 10 * time.Second
+	idempotencyPollInterval = 100 * time.Millisecond
+
+	// Request bodies up to this size are held in memory while the request
+	// runs; larger ones are spooled to a temporary file.
+	idempotencyMemoryBody = 1 << 20
+)
+
+// IdempotencyMiddleware makes POST requests carrying an Idempotency-Key header
+// safe to retry. The first request with a key runs normally and its response
+// is stored; replays get the stored response back, a replay with a different
+// body gets 422, and concurrent requests with the same key are serialized.
+// Keys belong to the tenant and the principal that used them; another
+// caller reusing a key gets a request of its own, never their response.
+// Responses with a 5xx status are not stored so the client can retry.
+// Bodies larger than maxBody are rejected with 413 before anything is
+// recorded; zero disables the limit. Reading a body that does not fit in
+// memory may take up to readTimeout instead of the server's read timeout.
+func IdempotencyMiddleware(repo repository.IdempotencyRepository, ttl time.Duration, maxBody int64, readTimeout time.Duration) gin.HandlerFunc {
+	locks := newKeyedMutex()
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		if maxBody > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+		}
+		ctx := c.Request.Context()
+		var subject string
+		if p := auth.FromContext(ctx); p != nil {
+			subject = p.Subject
+		}
+
+		body, fingerprint, err := spoolRequestBody(c, subject, readTimeout)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body is too large"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		defer body.Close()
+		c.Request.Body = body
+
+		unlock := locks.lock(tenant.FromContext(ctx) + "\x00" + subject + "\x00" + key)
+		defer unlock()
+
+		claimed, err := repo.Claim(ctx, &domain.IdempotencyKey{
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(ttl),
+		})
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !claimed {
+			replayIdempotentResponse(c, repo, key, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			err = repo.Release(ctx, key)
+		} else {
+			err = repo.Complete(ctx, key, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("idempotency: failed to record response for key %q: %v", key, err)
+		}
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, repo repository.IdempotencyRepository, key, fingerprint string) {
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for {
+		record, err := repo.Get(c.Request.Context(), key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The original request failed and released the key.
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "previous request with this Idempotency-Key failed, retry the request"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if record.Fingerprint != fingerprint {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			return
+		}
+		if record.Completed {
+			c.Header(idempotencyReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+		if time.Now().After(deadline) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			return
+		}
+		time.Sleep(idempotencyPollInterval)
+	}
+}
+
+// spoolRequestBody reads the request body, fingerprinting the request
+// (caller, method, path, body) as it goes, and returns a copy the handler can read
+// again. Small bodies are kept in memory and larger ones in a temporary
+// file, removed when the copy is closed.
+func spoolRequestBody(c *gin.Context, subject string, readTimeout time.Duration) (io.ReadCloser, string, error) {
+	r := c.Request
+	h := sha256.New()
+	io.WriteString(h, subject)
+	h.Write([]byte{0})
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.Path)
+	h.Write([]byte{0})
+	defer r.Body.Close()
+
+	var buf bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(h, &buf), io.LimitReader(r.Body, idempotencyMemoryBody+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if n <= idempotencyMemoryBody {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if readTimeout > 0 {
+		http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(readTimeout))
+	}
+	f, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, "", err
+	}
+	spooled := &tempFile{File: f}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		spooled.Close()
+		return nil, "", err
+	}
+	if _, err := io.Copy(io.MultiWriter(h, f), r.Body); err != nil {
+		spooled.Close()
+		return nil, "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, "", err
+	}
+	return spooled, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// tempFile removes the file when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// responseRecorder keeps a copy of the response body while writing it through.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keyedMutex serializes requests sharing a key within this instance.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,321 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/Naomejoy/app-service/pkg/config"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// memoryIdempotencyKeys keeps idempotency records in memory, per tenant,
+// caller and key, replacing expired ones on claim like the repository does.
+type memoryIdempotencyKeys struct {
+	mu      sync.Mutex
+	records map[string]domain.IdempotencyKey
+}
+
+func recordID(ctx context.Context, key string) string {
+	var subject string
+	if p := auth.FromContext(ctx); p != nil {
+		subject = p.Subject
+	}
+	return tenant.FromContext(ctx) + "\x00" + subject + "\x00" + key
+}
+
+func newMemoryIdempotencyKeys() *memoryIdempotencyKeys {
+	return &memoryIdempotencyKeys{records: map[string]domain.IdempotencyKey{}}
+}
+
+func (m *memoryIdempotencyKeys) Claim(ctx context.Context, record *domain.IdempotencyKey) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := recordID(ctx, record.Key)
+	if existing, ok := m.records[id]; ok && !existing.ExpiresAt.Before(time.Now()) {
+		return false, nil
+	}
+	record.TenantID = tenant.FromContext(ctx)
+	m.records[id] = *record
+	return true, nil
+}
+
+func (m *memoryIdempotencyKeys) Get(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[recordID(ctx, key)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &record, nil
+}
+
+func (m *memoryIdempotencyKeys) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := recordID(ctx, key)
+	record := m.records[id]
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = body
+	m.records[id] = record
+	return nil
+}
+
+func (m *memoryIdempotencyKeys) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id := recordID(ctx, key); !m.records[id].Completed {
+		delete(m.records, id)
+	}
+	return nil
+}
+
+func (m *memoryIdempotencyKeys) DeleteExpired(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, record := range m.records {
+		if record.ExpiresAt.Before(now) {
+			delete(m.records, key)
+		}
+	}
+	return nil
+}
+
+// testSubjectHeader names the caller of a test request; requests without it
+// come from the shared API key.
+const testSubjectHeader = "X-Test-Subject"
+
+// idempotentServer answers POST / with the number of times the handler
+// ran and the size of the body it read. status is the handler's status.
+type idempotentServer struct {
+	router *gin.Engine
+	calls  atomic.Int32
+	status int
+	// gate, when set, holds the handler until it is closed.
+	gate chan struct{}
+}
+
+func newIdempotentServer(repo *memoryIdempotencyKeys, ttl time.Duration, maxBody int64) *idempotentServer {
+	gin.SetMode(gin.TestMode)
+	s := &idempotentServer{router: gin.New(), status: http.StatusCreated}
+	s.router.Use(func(c *gin.Context) {
+		principal := &auth.Principal{Subject: "api-key", Roles: []string{auth.RoleAdmin}, CrossTenant: true}
+		if subject := c.GetHeader(testSubjectHeader); subject != "" {
+			principal = &auth.Principal{Subject: subject, Roles: []string{auth.RoleApplicant}}
+		}
+		setPrincipal(c, principal)
+	})
+	s.router.Use(TenantMiddleware(map[string]config.TenantConfig{tenant.DefaultID: {}, "acme": {}}))
+	s.router.Use(IdempotencyMiddleware(repo, ttl, maxBody, 0))
+	s.router.POST("/", func(c *gin.Context) {
+		call := s.calls.Add(1)
+		if s.gate != nil {
+			<-s.gate
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.JSON(s.status, gin.H{"call": call, "size": len(body)})
+	})
+	return s
+}
+
+func (s *idempotentServer) post(key string, body []byte) *httptest.ResponseRecorder {
+	return s.send(s.request(key, body))
+}
+
+func (s *idempotentServer) request(key string, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req
+}
+
+func (s *idempotentServer) send(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	s := newIdempotentServer(newMemoryIdempotencyKeys(), time.Hour, 0)
+
+	first := s.post("k1", []byte(`{"name":"a"}`))
+	if first.Code != http.StatusCreated || first.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Fatalf("first request: status %d, replayed %q", first.Code, first.Header().Get(idempotencyReplayedHeader))
+	}
+	replay := s.post("k1", []byte(`{"name":"a"}`))
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	if replay.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Error("replay is not marked as replayed")
+	}
+	if got := replay.Header().Get("Content-Type"); got != first.Header().Get("Content-Type") {
+		t.Errorf("replay content type = %q", got)
+	}
+
+	if w := s.post("k1", []byte(`{"name":"b"}`)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reuse with another body: status = %d, want 422", w.Code)
+	}
+	if w := s.post("k2", []byte(`{"name":"a"}`)); w.Code != http.StatusCreated {
+		t.Errorf("other key: status = %d, want 201", w.Code)
+	}
+	if w := s.post("", []byte(`{"name":"a"}`)); w.Code != http.StatusCreated {
+		t.Errorf("no key: status = %d, want 201", w.Code)
+	}
+	if calls := s.calls.Load(); calls != 3 {
+		t.Errorf("handler ran %d times, want 3", calls)
+	}
+}
+
+func TestIdempotencyKeysPerTenant(t *testing.T) {
+	s := newIdempotentServer(newMemoryIdempotencyKeys(), time.Hour, 0)
+
+	s.post("k1", []byte("{}"))
+	req := s.request("k1", []byte("{}"))
+	req.Header.Set(TenantHeader, "acme")
+	if w := s.send(req); w.Code != http.StatusCreated || w.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("same key in another tenant: status = %d, replayed %q", w.Code, w.Header().Get(idempotencyReplayedHeader))
+	}
+	if calls := s.calls.Load(); calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
+
+func TestIdempotencyKeysPerPrincipal(t *testing.T) {
+	s := newIdempotentServer(newMemoryIdempotencyKeys(), time.Hour, 0)
+
+	for _, subject := range []string{"user:1", "user:2"} {
+		req := s.request("k1", []byte("{}"))
+		req.Header.Set(testSubjectHeader, subject)
+		if w := s.send(req); w.Code != http.StatusCreated || w.Header().Get(idempotencyReplayedHeader) != "" {
+			t.Errorf("%s: status = %d, replayed %q", subject, w.Code, w.Header().Get(idempotencyReplayedHeader))
+		}
+	}
+	if calls := s.calls.Load(); calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
+
+func TestIdempotencyServerErrorsAreNotStored(t *testing.T) {
+	s := newIdempotentServer(newMemoryIdempotencyKeys(), time.Hour, 0)
+	s.status = http.StatusServiceUnavailable
+
+	s.post("k1", []byte("{}"))
+	s.status = http.StatusCreated
+	if w := s.post("k1", []byte("{}")); w.Code != http.StatusCreated || w.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("retry after a 5xx: status = %d, replayed %q", w.Code, w.Header().Get(idempotencyReplayedHeader))
+	}
+	if calls := s.calls.Load(); calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
+
+func TestIdempotencyConcurrentRequests(t *testing.T) {
+	s := newIdempotentServer(newMemoryIdempotencyKeys(), time.Hour, 0)
+	s.gate = make(chan struct{})
+
+	const n = 5
+	responses := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = s.post("k1", []byte("{}"))
+		}(i)
+	}
+	// Let the handler run once the first request reached it.
+	for s.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(s.gate)
+	wg.Wait()
+
+	if calls := s.calls.Load(); calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+	replayed := 0
+	for _, w := range responses {
+		if w.Code != http.StatusCreated || w.Body.String() != responses[0].Body.String() {
+			t.Errorf("response %d %s, want %d %s", w.Code, w.Body, responses[0].Code, responses[0].Body)
+		}
+		if w.Header().Get(idempotencyReplayedHeader) == "true" {
+			replayed++
+		}
+	}
+	if replayed != n-1 {
+		t.Errorf("%d responses replayed, want %d", replayed, n-1)
+	}
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	repo := newMemoryIdempotencyKeys()
+	s := newIdempotentServer(repo, time.Hour, 0)
+
+	before := time.Now()
+	s.post("k1", []byte("{}"))
+	ctx := tenant.WithID(auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "api-key"}), tenant.DefaultID)
+	record, err := repo.Get(ctx, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ExpiresAt.Before(before.Add(time.Hour)) || record.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("key expires at %v, want an hour after the request", record.ExpiresAt)
+	}
+
+	// Once expired, the key starts over.
+	record.ExpiresAt = time.Now().Add(-time.Second)
+	repo.records[recordID(ctx, "k1")] = *record
+	if w := s.post("k1", []byte(`{"other":true}`)); w.Code != http.StatusCreated || w.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("expired key: status = %d, replayed %q", w.Code, w.Header().Get(idempotencyReplayedHeader))
+	}
+	if calls := s.calls.Load(); calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+
+	if err := repo.DeleteExpired(ctx, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.records) != 0 {
+		t.Errorf("%d records left after they expired", len(repo.records))
+	}
+}
+
+func TestIdempotencyBodyLimit(t *testing.T) {
+	repo := newMemoryIdempotencyKeys()
+	const limit = 2 << 20
+	s := newIdempotentServer(repo, time.Hour, limit)
+
+	if w := s.post("k1", make([]byte, limit+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status = %d, want 413", w.Code)
+	}
+	if s.calls.Load() != 0 || len(repo.records) != 0 {
+		t.Errorf("oversized body reached the handler or was recorded")
+	}
+
+	// Bodies larger than what is kept in memory reach the handler intact.
+	for _, size := range []int{0, idempotencyMemoryBody, idempotencyMemoryBody + 1, limit} {
+		w := s.post("size-"+strconv.Itoa(size), make([]byte, size))
+		if want := `"size":` + strconv.Itoa(size); w.Code != http.StatusCreated || !bytes.Contains(w.Body.Bytes(), []byte(want)) {
+			t.Errorf("body of %d bytes: %d %s", size, w.Code, w.Body)
+		}
+	}
+}
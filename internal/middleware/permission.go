@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects requests whose principal lacks the permission,
+// either because no role grants it or because the credential's scopes do not
+// include it.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !GetPrincipal(c).Can(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden", "missingPermission": permission})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// rejectAll stands in for authentication that always fails.
+type rejectAll struct{ calls int }
+
+func (a *rejectAll) Authenticate(*http.Request) (*auth.Principal, error) {
+	a.calls++
+	return nil, auth.ErrNoCredentials
+}
+
+func TestIPRateLimitRunsBeforeAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator := &rejectAll{}
+	r := gin.New()
+	r.Use(IPRateLimitMiddleware(ratelimit.NewMemoryStore(), ratelimit.PerMinute(1, 2)))
+	r.Use(AuthMiddleware(authenticator))
+	r.GET("/", func(c *gin.Context) {})
+
+	do := func(ip string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := do("192.0.2.1"); code != http.StatusUnauthorized {
+			t.Fatalf("request %d: status = %d, want 401", i, code)
+		}
+	}
+	if code := do("192.0.2.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", code)
+	}
+	if authenticator.calls != 2 {
+		t.Errorf("authenticated %d requests, want 2", authenticator.calls)
+	}
+	if code := do("192.0.2.2"); code != http.StatusUnauthorized {
+		t.Fatalf("other IP: status = %d, want 401", code)
+	}
+}
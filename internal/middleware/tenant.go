@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/Naomejoy/app-service/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TenantHeader = "X-Tenant-ID"
+
+	// CredentialTenantKey is set on the gin context by authentication
+	// middleware when the credential is bound to a tenant.
+	CredentialTenantKey = "credentialTenantId"
+	// TenantKey holds the resolved tenant ID on the gin context.
+	TenantKey = "tenantId"
+)
+
+// TenantMiddleware resolves the tenant of the request and stores it on the
+// request context, where repositories pick it up. A tenant bound to the
+// credential wins. Only cross-tenant credentials may name the tenant with
+// the X-Tenant-ID header; other credentials without a tenant are pinned to
+// the default tenant, so a header naming another one is refused.
+func TenantMiddleware(tenants map[string]config.TenantConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetString(CredentialTenantKey)
+		if principal := auth.FromContext(c.Request.Context()); id == "" && (principal == nil || !principal.CrossTenant) {
+			id = tenant.DefaultID
+		}
+		header := c.GetHeader(TenantHeader)
+		if id != "" && header != "" && header != id {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "credential is not valid for this tenant"})
+			return
+		}
+		if id == "" {
+			id = header
+		}
+		if id == "" {
+			id = tenant.DefaultID
+		}
+		if _, ok := tenants[id]; !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "unknown tenant"})
+			return
+		}
+
+		c.Set(TenantKey, id)
+		c.Request = c.Request.WithContext(tenant.WithID(c.Request.Context(), id))
+		c.Next()
+	}
+}
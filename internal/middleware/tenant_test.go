@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/Naomejoy/app-service/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tenants := map[string]config.TenantConfig{
+		tenant.DefaultID: {ID: tenant.DefaultID},
+		"acme":           {ID: "acme"},
+	}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		header    string
+		status    int
+		tenant    string
+	}{
+		{"bound credential", &auth.Principal{TenantID: "acme"}, "", http.StatusOK, "acme"},
+		{"bound credential, same header", &auth.Principal{TenantID: "acme"}, "acme", http.StatusOK, "acme"},
+		{"bound credential, other header", &auth.Principal{TenantID: "acme"}, tenant.DefaultID, http.StatusForbidden, ""},
+		{"tenantless token", &auth.Principal{}, "", http.StatusOK, tenant.DefaultID},
+		{"tenantless token picks tenant", &auth.Principal{}, "acme", http.StatusForbidden, ""},
+		{"cross-tenant key", &auth.Principal{CrossTenant: true}, "acme", http.StatusOK, "acme"},
+		{"cross-tenant key without header", &auth.Principal{CrossTenant: true}, "", http.StatusOK, tenant.DefaultID},
+		{"cross-tenant key, unknown tenant", &auth.Principal{CrossTenant: true}, "other", http.StatusForbidden, ""},
+		{"no principal", nil, "acme", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.principal != nil {
+					setPrincipal(c, tt.principal)
+				}
+			})
+			r.Use(TenantMiddleware(tenants))
+			var resolved string
+			r.GET("/", func(c *gin.Context) {
+				resolved = tenant.FromContext(c.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if resolved != tt.tenant {
+				t.Errorf("tenant = %q, want %q", resolved, tt.tenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+// costLimit bounds the work a single condition may do.
+const costLimit = 100000
+
+type compiledRule struct {
+	Rule
+	program cel.Program
+}
+
+type ruleSet struct {
+	rules []compiledRule
+	// signature identifies the files the set was loaded from.
+	signature string
+}
+
+// Engine evaluates the policy files of a directory. The compiled rules are
+// swapped atomically, so a reload never disturbs in-flight decisions.
+type Engine struct {
+	dir   string
+	env   *cel.Env
+	rules atomic.Pointer[ruleSet]
+}
+
+// NewEngine loads the *.json policy files in dir. An empty dir yields an
+// engine without rules, which never denies.
+func NewEngine(dir string) (*Engine, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("principal", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("resource", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("action", cel.StringType),
+		cel.Variable("now", cel.TimestampType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	e := &Engine{dir: dir, env: env}
+	e.rules.Store(&ruleSet{})
+	if dir != "" {
+		if err := e.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Reload recompiles the policy files. On any compile error or failing test
+// the previous rules stay in effect and the error is returned.
+func (e *Engine) Reload() error {
+	files, signature, err := e.scan()
+	if err != nil {
+		return err
+	}
+
+	var rules []compiledRule
+	var tests []TestCase
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var file File
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, rule := range file.Policies {
+			compiled, err := e.compile(rule)
+			if err != nil {
+				return fmt.Errorf("%s: policy %q: %w", path, rule.ID, err)
+			}
+			rules = append(rules, compiled)
+		}
+		tests = append(tests, file.Tests...)
+	}
+
+	set := &ruleSet{rules: rules, signature: signature}
+	for _, test := range tests {
+		principal := test.Principal
+		decision := set.decide(Input{
+			Principal: &principal,
+			Action:    test.Action,
+			Resource:  test.Resource,
+			Request:   test.Request,
+		})
+		got := EffectDeny
+		if decision.Allowed {
+			got = EffectAllow
+		}
+		if got != test.Expect {
+			return fmt.Errorf("policy test %q: expected %s, got %s (%s)", test.Name, test.Expect, got, decision.Reason)
+		}
+	}
+
+	e.rules.Store(set)
+	log.Printf("Loaded %d policies and passed %d policy tests from %s", len(rules), len(tests), e.dir)
+	return nil
+}
+
+// Watch reloads the policies whenever the files in the directory change,
+// checking every interval until ctx is done.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if e.dir == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, signature, err := e.scan()
+			if err != nil {
+				log.Printf("Failed to scan policy directory: %v", err)
+				continue
+			}
+			if signature == e.rules.Load().signature {
+				continue
+			}
+			if err := e.Reload(); err != nil {
+				log.Printf("Keeping previous policies, reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// Decide evaluates the rules for the input. Deny rules win over allow
+// rules; when rules cover the action but none allows it, the action is
+// denied. A condition that fails to evaluate counts as a match for deny
+// rules and as no match for allow rules.
+func (e *Engine) Decide(input Input) Decision {
+	return e.rules.Load().decide(input)
+}
+
+func (s *ruleSet) decide(input Input) Decision {
+	vars := activation(input, time.Now())
+	decision := Decision{Rules: []RuleResult{}}
+	var allowedBy, deniedBy []string
+
+	for _, rule := range s.rules {
+		if !rule.covers(input.Action) {
+			continue
+		}
+		decision.Applicable = true
+		result := RuleResult{ID: rule.ID, Description: rule.Description, Effect: rule.Effect}
+
+		matched, err := rule.eval(vars)
+		if err != nil {
+			result.Error = err.Error()
+			matched = rule.Effect == EffectDeny
+		}
+		result.Matched = matched
+		decision.Rules = append(decision.Rules, result)
+
+		if matched {
+			if rule.Effect == EffectDeny {
+				deniedBy = append(deniedBy, rule.ID)
+			} else {
+				allowedBy = append(allowedBy, rule.ID)
+			}
+		}
+	}
+
+	switch {
+	case !decision.Applicable:
+		decision.Allowed = true
+		decision.Reason = "no policy covers " + input.Action
+	case len(deniedBy) > 0:
+		decision.Reason = "denied by " + strings.Join(deniedBy, ", ")
+	case len(allowedBy) > 0:
+		decision.Allowed = true
+		decision.Reason = "allowed by " + strings.Join(allowedBy, ", ")
+	default:
+		decision.Reason = "no policy allows " + input.Action
+	}
+	return decision
+}
+
+func (e *Engine) compile(rule Rule) (compiledRule, error) {
+	if rule.ID == "" {
+		return compiledRule{}, errors.New("id is required")
+	}
+	if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+		return compiledRule{}, fmt.Errorf("effect must be %q or %q", EffectAllow, EffectDeny)
+	}
+	if len(rule.Actions) == 0 {
+		return compiledRule{}, errors.New("at least one action is required")
+	}
+	if strings.TrimSpace(rule.Condition) == "" {
+		return compiledRule{Rule: rule}, nil
+	}
+
+	ast, issues := e.env.Compile(rule.Condition)
+	if issues != nil && issues.Err() != nil {
+		return compiledRule{}, issues.Err()
+	}
+	// Attribute lookups are dynamic; their type is checked at evaluation.
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return compiledRule{}, fmt.Errorf("condition must be a bool expression, got %s", ast.OutputType())
+	}
+	program, err := e.env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return compiledRule{}, err
+	}
+	return compiledRule{Rule: rule, program: program}, nil
+}
+
+func (r compiledRule) covers(action string) bool {
+	for _, a := range r.Actions {
+		if a == action || a == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r compiledRule) eval(vars map[string]interface{}) (bool, error) {
+	if r.program == nil {
+		return true, nil
+	}
+	out, _, err := r.program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("condition returned %T", out.Value())
+	}
+	return matched, nil
+}
+
+// scan lists the policy files of the directory with a signature that
+// changes whenever a file is added, removed or modified.
+func (e *Engine) scan() ([]string, string, error) {
+	files, err := filepath.Glob(filepath.Join(e.dir, "*.json"))
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(files)
+	var signature strings.Builder
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(&signature, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return files, signature.String(), nil
+}
@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Naomejoy/app-service/internal/auth"
+)
+
+func writePolicies(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const testPolicies = `{
+  "policies": [
+    {"id": "owner", "actions": ["applications:update"], "effect": "allow",
+     "condition": "resource.userId == principal.userId"},
+    {"id": "closed", "actions": ["applications:update"], "effect": "deny",
+     "condition": "resource.status == 'approved'"},
+    {"id": "broken", "actions": ["statuses:add"], "effect": "deny",
+     "condition": "resource.missing == 1"}
+  ],
+  "tests": [
+    {"name": "owner may update", "action": "applications:update",
+     "principal": {"userId": 1}, "resource": {"userId": 1, "status": "review"}, "expect": "allow"}
+  ]
+}`
+
+func TestEngineDecide(t *testing.T) {
+	dir := t.TempDir()
+	writePolicies(t, dir, "applications.json", testPolicies)
+	engine, err := NewEngine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := &auth.Principal{UserID: 1}
+	other := &auth.Principal{UserID: 2}
+	tests := []struct {
+		name       string
+		input      Input
+		allowed    bool
+		applicable bool
+		reason     string
+	}{
+		{
+			name:       "allow rule matches",
+			input:      Input{Principal: owner, Action: ActionApplicationUpdate, Resource: map[string]interface{}{"userId": int64(1), "status": "review"}},
+			allowed:    true,
+			applicable: true,
+			reason:     "allowed by owner",
+		},
+		{
+			name:       "no allow rule matches",
+			input:      Input{Principal: other, Action: ActionApplicationUpdate, Resource: map[string]interface{}{"userId": int64(1), "status": "review"}},
+			applicable: true,
+			reason:     "no policy allows applications:update",
+		},
+		{
+			name:       "deny wins over allow",
+			input:      Input{Principal: owner, Action: ActionApplicationUpdate, Resource: map[string]interface{}{"userId": int64(1), "status": "approved"}},
+			applicable: true,
+			reason:     "denied by closed",
+		},
+		{
+			name:       "evaluation error matches deny rules",
+			input:      Input{Principal: owner, Action: ActionStatusAdd},
+			applicable: true,
+			reason:     "denied by broken",
+		},
+		{
+			name:    "uncovered action is left to roles",
+			input:   Input{Principal: owner, Action: ActionApplicationDelete},
+			allowed: true,
+			reason:  "no policy covers applications:delete",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Decide(tt.input)
+			if decision.Allowed != tt.allowed || decision.Applicable != tt.applicable || decision.Reason != tt.reason {
+				t.Errorf("Decide() = allowed %v, applicable %v, %q; want %v, %v, %q",
+					decision.Allowed, decision.Applicable, decision.Reason, tt.allowed, tt.applicable, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEngineRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"missing id", `{"policies": [{"actions": ["*"], "effect": "allow"}]}`, "id is required"},
+		{"bad effect", `{"policies": [{"id": "p", "actions": ["*"], "effect": "maybe"}]}`, "effect must be"},
+		{"no actions", `{"policies": [{"id": "p", "effect": "allow"}]}`, "at least one action"},
+		{"syntax error", `{"policies": [{"id": "p", "actions": ["*"], "effect": "allow", "condition": "resource.("}]}`, "policy \"p\""},
+		{"not a bool", `{"policies": [{"id": "p", "actions": ["*"], "effect": "allow", "condition": "1 + 2"}]}`, "bool expression"},
+		{"failing test", `{"policies": [{"id": "p", "actions": ["*"], "effect": "deny"}],
+		  "tests": [{"name": "t", "action": "applications:read", "expect": "allow"}]}`, "policy test \"t\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePolicies(t, dir, "p.json", tt.content)
+			_, err := NewEngine(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("NewEngine() error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEngineReloadKeepsRulesOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	writePolicies(t, dir, "p.json", `{"policies": [{"id": "deny-all", "actions": ["*"], "effect": "deny"}]}`)
+	engine, err := NewEngine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePolicies(t, dir, "p.json", `{"policies": [{"id": "", "actions": ["*"], "effect": "allow"}]}`)
+	if err := engine.Reload(); err == nil {
+		t.Fatal("Reload() accepted an invalid file")
+	}
+	if engine.Decide(Input{Action: ActionApplicationRead}).Allowed {
+		t.Fatal("a failed reload replaced the rules")
+	}
+
+	writePolicies(t, dir, "p.json", `{"policies": [{"id": "allow-all", "actions": ["*"], "effect": "allow"}]}`)
+	if err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !engine.Decide(Input{Action: ActionApplicationRead}).Allowed {
+		t.Fatal("a successful reload did not replace the rules")
+	}
+}
+
+// The shipped policies must compile and pass their own tests.
+func TestShippedPolicies(t *testing.T) {
+	if _, err := NewEngine(filepath.Join("..", "..", "policies")); err != nil {
+		t.Fatal(err)
+	}
+}
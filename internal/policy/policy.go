@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+)
+
+// Actions the service layer asks the engine about.
+const (
+	ActionApplicationRead   = "applications:read"
+	ActionApplicationUpdate = "applications:update"
+	ActionApplicationDelete = "applications:delete"
+	ActionApplicationMerge  = "applications:merge"
+	ActionStatusAdd         = "statuses:add"
+	ActionFileTypeAdd       = "filetypes:add"
+	ActionFileTypeDelete    = "filetypes:delete"
+	ActionDocumentAdd       = "documents:add"
+	ActionDocumentDelete    = "documents:delete"
+	ActionDocumentRescan    = "documents:rescan"
+	ActionDocumentReview    = "documents:review"
+	ActionLegalHold         = "applications:hold"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Rule is one policy. Condition is a CEL expression over the variables
+// principal, resource, request, action and now; the rule applies when it
+// evaluates to true. An empty condition always applies.
+type Rule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Actions     []string `json:"actions"`
+	Effect      string   `json:"effect"`
+	Condition   string   `json:"condition"`
+}
+
+// TestCase pins the expected decision for an input. The tests of a policy
+// file run whenever it is loaded, and a failing test rejects the file.
+type TestCase struct {
+	Name      string                 `json:"name"`
+	Action    string                 `json:"action"`
+	Principal auth.Principal         `json:"principal"`
+	Resource  map[string]interface{} `json:"resource"`
+	Request   map[string]interface{} `json:"request"`
+	Expect    string                 `json:"expect"`
+}
+
+// File is the JSON layout of a policy file.
+type File struct {
+	Policies []Rule     `json:"policies"`
+	Tests    []TestCase `json:"tests"`
+}
+
+// Input is what a decision is made about.
+type Input struct {
+	Principal *auth.Principal
+	Action    string
+	Resource  map[string]interface{}
+	Request   map[string]interface{}
+}
+
+// RuleResult records how one rule contributed to a decision.
+type RuleResult struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Effect      string `json:"effect"`
+	Matched     bool   `json:"matched"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Decision is the outcome of evaluating the rules for an input.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// Applicable is false when no rule covers the action; role checks alone
+	// decide then.
+	Applicable bool         `json:"applicable"`
+	Rules      []RuleResult `json:"rules"`
+}
+
+// ApplicationResource exposes an application to policies. status is the
+// application's current status.
+func ApplicationResource(app *domain.Application, status string) map[string]interface{} {
+	resource := map[string]interface{}{
+		"id":                int64(app.ID),
+		"tenantId":          app.TenantID,
+		"userId":            int64(app.UserID),
+		"name":              app.Name,
+		"code":              app.Code,
+		"description":       app.Description,
+		"status":            status,
+		"assigneeId":        nil,
+		"possibleDuplicate": app.PossibleDuplicate,
+		"merged":            app.MergedIntoID != nil,
+		"legalHold":         app.LegalHold,
+		"createdAt":         app.CreatedAt,
+		"updatedAt":         app.UpdatedAt,
+	}
+	if app.AssigneeID != nil {
+		resource["assigneeId"] = int64(*app.AssigneeID)
+	}
+	return resource
+}
+
+func principalValue(p *auth.Principal) map[string]interface{} {
+	if p == nil {
+		p = &auth.Principal{}
+	}
+	roles := p.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return map[string]interface{}{
+		"subject":  p.Subject,
+		"userId":   int64(p.UserID),
+		"roles":    roles,
+		"tenantId": p.TenantID,
+	}
+}
+
+func activation(input Input, now time.Time) map[string]interface{} {
+	resource := input.Resource
+	if resource == nil {
+		resource = map[string]interface{}{}
+	}
+	request := input.Request
+	if request == nil {
+		request = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"principal": principalValue(input.Principal),
+		"resource":  resource,
+		"request":   request,
+		"action":    input.Action,
+		"now":       now,
+	}
+}
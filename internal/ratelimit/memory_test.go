@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := PerMinute(60, 3) // one token a second, three at most
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := store.Take(ctx, "a", limit)
+		if err != nil || !res.Allowed {
+			t.Fatalf("take %d: allowed = %v, err = %v", i, res.Allowed, err)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("take %d: remaining = %d, want %d", i, res.Remaining, 2-i)
+		}
+	}
+
+	res, _ := store.Take(ctx, "a", limit)
+	if res.Allowed {
+		t.Fatal("took a token from an empty bucket")
+	}
+	if res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Errorf("retry after %v, reset %v; want 1s, 3s", res.RetryAfter, res.Reset)
+	}
+
+	if res, _ := store.Take(ctx, "b", limit); !res.Allowed {
+		t.Fatal("buckets are not separate per key")
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if res, _ := store.Take(ctx, "a", limit); !res.Allowed {
+		t.Fatal("bucket did not refill")
+	}
+	if res, _ := store.Take(ctx, "a", limit); res.Allowed {
+		t.Fatal("bucket refilled more than the elapsed time allows")
+	}
+
+	now = now.Add(time.Hour)
+	if res, _ := store.Take(ctx, "a", limit); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("bucket refilled beyond its burst: remaining %d", res.Remaining)
+	}
+}
+
+func TestLimitEnabled(t *testing.T) {
+	tests := []struct {
+		limit Limit
+		want  bool
+	}{
+		{PerMinute(60, 10), true},
+		{PerMinute(0, 10), false},
+		{PerMinute(60, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.limit.Enabled(); got != tt.want {
+			t.Errorf("%+v.Enabled() = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
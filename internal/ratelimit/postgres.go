@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresStore keeps the buckets in the rate_limit_buckets table so all
+// instances share them. Each Take is a single upsert; refills use the
+// database clock, so instance clock drift does not matter.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// The refill expression is repeated because SET clauses cannot refer to each
+// other; they all see the row as it was before the update.
+const takeSQL = `
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES (@key, CAST(@burst AS DOUBLE PRECISION) - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+	allowed = LEAST(CAST(@burst AS DOUBLE PRECISION), b.tokens + CAST(EXTRACT(EPOCH FROM now() - b.updated_at) AS DOUBLE PRECISION) * CAST(@rate AS DOUBLE PRECISION)) >= 1,
+	tokens = LEAST(CAST(@burst AS DOUBLE PRECISION), b.tokens + CAST(EXTRACT(EPOCH FROM now() - b.updated_at) AS DOUBLE PRECISION) * CAST(@rate AS DOUBLE PRECISION))
+		- CASE WHEN LEAST(CAST(@burst AS DOUBLE PRECISION), b.tokens + CAST(EXTRACT(EPOCH FROM now() - b.updated_at) AS DOUBLE PRECISION) * CAST(@rate AS DOUBLE PRECISION)) >= 1 THEN 1 ELSE 0 END,
+	updated_at = now()
+RETURNING tokens, allowed`
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	var row struct {
+		Tokens  float64
+		Allowed bool
+	}
+	err := s.db.WithContext(ctx).Raw(takeSQL, map[string]interface{}{
+		"key":   key,
+		"burst": float64(limit.Burst),
+		"rate":  limit.Rate,
+	}).Scan(&row).Error
+	if err != nil {
+		return Result{}, err
+	}
+	return result(limit, row.Tokens, row.Allowed), nil
+}
+
+// DeleteIdle removes buckets untouched since before the given time.
+func (s *PostgresStore) DeleteIdle(before time.Time) error {
+	return s.db.Exec("DELETE FROM rate_limit_buckets WHERE updated_at < ?", before).Error
+}
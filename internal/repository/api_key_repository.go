@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByID(ctx context.Context, id uint64) (*domain.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	List(ctx context.Context) ([]domain.APIKey, error)
+	Rotate(ctx context.Context, old *domain.APIKey, replacement *domain.APIKey, oldExpiresAt time.Time) error
+	Revoke(ctx context.Context, id uint64, at time.Time) error
+	TouchLastUsed(ctx context.Context, id uint64, at time.Time) error
+}
+
+type apiKeyRepo struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepo{db: db}
+}
+
+func (r *apiKeyRepo) Create(ctx context.Context, key *domain.APIKey) error {
+	key.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *apiKeyRepo) GetByID(ctx context.Context, id uint64) (*domain.APIKey, error) {
+	var key domain.APIKey
+	if err := scoped(ctx, r.db).First(&key, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByPrefix looks a key up across all tenants. It is used to authenticate
+// a request, before its tenant is known.
+func (r *apiKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	if err := r.db.WithContext(ctx).First(&key, "prefix = ?", prefix).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepo) List(ctx context.Context) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	err := scoped(ctx, r.db).Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+// Rotate stores the replacement key and shortens the old key's lifetime to
+// the overlap window in one transaction.
+func (r *apiKeyRepo) Rotate(ctx context.Context, old *domain.APIKey, replacement *domain.APIKey, oldExpiresAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		replacement.TenantID = old.TenantID
+		if err := tx.Create(replacement).Error; err != nil {
+			return err
+		}
+		return tx.Model(&domain.APIKey{}).Scopes(tenantScope(ctx)).
+			Where("id = ?", old.ID).
+			Update("expires_at", oldExpiresAt).Error
+	})
+}
+
+func (r *apiKeyRepo) Revoke(ctx context.Context, id uint64, at time.Time) error {
+	return scoped(ctx, r.db).Model(&domain.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, id uint64, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&domain.APIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", at).Error
+}
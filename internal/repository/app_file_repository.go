@@ -1,14 +1,18 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
 	"gorm.io/gorm"
 )
 
 type ApplicationFileTypeRepository interface {
-	Add(fileType *domain.ApplicationUploadedFileType) error
-	Delete(id uint64) error
-	ListByApplication(appID uint64) ([]domain.ApplicationUploadedFileType, error)
+	Add(ctx context.Context, fileType *domain.ApplicationUploadedFileType) error
+	GetByID(ctx context.Context, appID, id uint64) (*domain.ApplicationUploadedFileType, error)
+	Delete(ctx context.Context, appID, id uint64) error
+	ListByApplication(ctx context.Context, appID uint64) ([]domain.ApplicationUploadedFileType, error)
 }
 
 type fileTypeRepo struct {
@@ -19,16 +23,25 @@ func NewApplicationFileTypeRepository(db *gorm.DB) ApplicationFileTypeRepository
 	return &fileTypeRepo{db: db}
 }
 
-func (r *fileTypeRepo) Add(fileType *domain.ApplicationUploadedFileType) error {
-	return r.db.Create(fileType).Error
+func (r *fileTypeRepo) Add(ctx context.Context, fileType *domain.ApplicationUploadedFileType) error {
+	fileType.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Create(fileType).Error
+}
+
+func (r *fileTypeRepo) GetByID(ctx context.Context, appID, id uint64) (*domain.ApplicationUploadedFileType, error) {
+	var fileType domain.ApplicationUploadedFileType
+	if err := scoped(ctx, r.db).Preload("DocumentType").First(&fileType, "application_id = ? AND id = ?", appID, id).Error; err != nil {
+		return nil, err
+	}
+	return &fileType, nil
 }
 
-func (r *fileTypeRepo) Delete(id uint64) error {
-	return r.db.Delete(&domain.ApplicationUploadedFileType{}, "id = ?", id).Error
+func (r *fileTypeRepo) Delete(ctx context.Context, appID, id uint64) error {
+	return scoped(ctx, r.db).Delete(&domain.ApplicationUploadedFileType{}, "application_id = ? AND id = ?", appID, id).Error
 }
 
-func (r *fileTypeRepo) ListByApplication(appID uint64) ([]domain.ApplicationUploadedFileType, error) {
+func (r *fileTypeRepo) ListByApplication(ctx context.Context, appID uint64) ([]domain.ApplicationUploadedFileType, error) {
 	var fileTypes []domain.ApplicationUploadedFileType
-	err := r.db.Where("application_id = ?", appID).Find(&fileTypes).Error
+	err := scoped(ctx, r.db).Preload("DocumentType").Where("application_id = ?", appID).Find(&fileTypes).Error
 	return fileTypes, err
 }
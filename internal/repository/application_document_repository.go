@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotCurrentVersion is returned by CreateVersion when the replaced
+// version is no longer the current one.
+var ErrNotCurrentVersion = errors.New("document version is not current")
+
+type ApplicationDocumentRepository interface {
+	// Create records the first version of a new document. Its content has
+	// to be a blob reserved with DocumentBlobRepository.Reserve; the document
+	// holds a reference to it until it is deleted.
+	Create(ctx context.Context, doc *domain.ApplicationDocument) error
+	// CreateVersion records doc as the version following previous, which
+	// has to be current.
+	CreateVersion(ctx context.Context, doc, previous *domain.ApplicationDocument) error
+	GetByID(ctx context.Context, appID, id uint64) (*domain.ApplicationDocument, error)
+	// FindByID returns a document of any of the tenant's applications.
+	FindByID(ctx context.Context, id uint64) (*domain.ApplicationDocument, error)
+	// ListByApplication returns the current version of each document.
+	ListByApplication(ctx context.Context, appID uint64) ([]domain.ApplicationDocument, error)
+	// ListVersions returns every version of a document, newest first.
+	ListVersions(ctx context.Context, appID, lineageID uint64) ([]domain.ApplicationDocument, error)
+	// DeleteLineage deletes every version of a document and releases their
+	// blobs.
+	DeleteLineage(ctx context.Context, appID, lineageID uint64) error
+	// DeleteVersions deletes the document versions with the given IDs,
+	// except those of applications on legal hold, and releases their blobs.
+	// It returns the versions deleted.
+	DeleteVersions(ctx context.Context, ids []uint64) ([]domain.ApplicationDocument, error)
+	SetReview(ctx context.Context, appID, id uint64, status, reason, note string, by *uint64, at time.Time) error
+	CountByFileType(ctx context.Context, fileTypeID uint64) (int64, error)
+	// StorageKeysByApplication returns the keys of the blobs only the
+	// application uses: document previews and the parts of unfinished
+	// uploads. Document content is shared, and released with the documents.
+	StorageKeysByApplication(ctx context.Context, appID uint64) ([]string, error)
+	// StorageUsed returns the total size of every version of the
+	// application's documents.
+	StorageUsed(ctx context.Context, appID uint64) (int64, error)
+	// StorageUsedByOwner returns the total size of every version of the
+	// documents of the applications of a user.
+	StorageUsedByOwner(ctx context.Context, userID uint64) (int64, error)
+	MarkForRescan(ctx context.Context, appID, id uint64) error
+	ListPendingScans(ctx context.Context, limit int) ([]domain.ApplicationDocument, error)
+	SetScanResult(ctx context.Context, id uint64, status, signature string, at time.Time) (bool, error)
+	ListPendingPreviews(ctx context.Context, limit int) ([]domain.ApplicationDocument, error)
+	SetPreviewResult(ctx context.Context, id uint64, status string, keys map[string]string, at time.Time) error
+}
+
+type documentRepo struct {
+	db *gorm.DB
+}
+
+func NewApplicationDocumentRepository(db *gorm.DB) ApplicationDocumentRepository {
+	return &documentRepo{db: db}
+}
+
+func (r *documentRepo) Create(ctx context.Context, doc *domain.ApplicationDocument) error {
+	doc.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The first version starts the lineage, so its ID is needed up front.
+		err := tx.Raw("SELECT nextval(pg_get_serial_sequence('application_documents', 'id'))").
+			Scan(&doc.ID).Error
+		if err != nil {
+			return err
+		}
+		doc.LineageID = doc.ID
+		doc.Version = 1
+		doc.IsCurrent = true
+		if err := retainBlob(tx, doc.TenantID, doc.StorageKey); err != nil {
+			return err
+		}
+		return tx.Create(doc).Error
+	})
+}
+
+func (r *documentRepo) CreateVersion(ctx context.Context, doc, previous *domain.ApplicationDocument) error {
+	doc.TenantID = tenant.FromContext(ctx)
+	doc.LineageID = previous.LineageID
+	doc.Version = previous.Version + 1
+	doc.IsCurrent = true
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Scopes(tenantScope(ctx)).Model(&domain.ApplicationDocument{}).
+			Where("id = ? AND is_current", previous.ID).
+			Update("is_current", false)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotCurrentVersion
+		}
+		if err := retainBlob(tx, doc.TenantID, doc.StorageKey); err != nil {
+			return err
+		}
+		return tx.Create(doc).Error
+	})
+}
+
+func (r *documentRepo) GetByID(ctx context.Context, appID, id uint64) (*domain.ApplicationDocument, error) {
+	var doc domain.ApplicationDocument
+	err := scoped(ctx, r.db).Preload("FileType").
+		First(&doc, "application_id = ? AND id = ?", appID, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (r *documentRepo) FindByID(ctx context.Context, id uint64) (*domain.ApplicationDocument, error) {
+	var doc domain.ApplicationDocument
+	if err := scoped(ctx, r.db).First(&doc, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (r *documentRepo) ListByApplication(ctx context.Context, appID uint64) ([]domain.ApplicationDocument, error) {
+	var docs []domain.ApplicationDocument
+	err := scoped(ctx, r.db).Preload("FileType").
+		Where("application_id = ? AND is_current", appID).
+		Order("created_at desc").
+		Find(&docs).Error
+	return docs, err
+}
+
+func (r *documentRepo) ListVersions(ctx context.Context, appID, lineageID uint64) ([]domain.ApplicationDocument, error) {
+	var docs []domain.ApplicationDocument
+	err := scoped(ctx, r.db).Preload("FileType").
+		Where("application_id = ? AND lineage_id = ?", appID, lineageID).
+		Order("version desc").
+		Find(&docs).Error
+	return docs, err
+}
+
+func (r *documentRepo) DeleteLineage(ctx context.Context, appID, lineageID uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deleted []domain.ApplicationDocument
+		err := tx.Scopes(tenantScope(ctx)).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "storage_key"}}}).
+			Where("application_id = ? AND lineage_id = ?", appID, lineageID).
+			Delete(&deleted).Error
+		if err != nil {
+			return err
+		}
+		keys := make([]string, len(deleted))
+		for i, doc := range deleted {
+			keys[i] = doc.StorageKey
+		}
+		return releaseBlobs(tx, tenant.FromContext(ctx), keys)
+	})
+}
+
+func (r *documentRepo) DeleteVersions(ctx context.Context, ids []uint64) ([]domain.ApplicationDocument, error) {
+	var deleted []domain.ApplicationDocument
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Scopes(tenantScope(ctx)).
+			Clauses(clause.Returning{}).
+			Where("id IN ?", ids).
+			Where("application_id IN (?)", tx.Model(&domain.Application{}).Select("id").Where("NOT legal_hold")).
+			Delete(&deleted).Error
+		if err != nil {
+			return err
+		}
+		keys := make([]string, len(deleted))
+		for i, doc := range deleted {
+			keys[i] = doc.StorageKey
+		}
+		return releaseBlobs(tx, tenant.FromContext(ctx), keys)
+	})
+	return deleted, err
+}
+
+// SetReview records a review decision on one version.
+func (r *documentRepo) SetReview(ctx context.Context, appID, id uint64, status, reason, note string, by *uint64, at time.Time) error {
+	return scoped(ctx, r.db).Model(&domain.ApplicationDocument{}).
+		Where("application_id = ? AND id = ?", appID, id).
+		Updates(map[string]interface{}{
+			"review_status": status,
+			"review_reason": reason,
+			"review_note":   note,
+			"reviewed_by":   by,
+			"reviewed_at":   at,
+		}).Error
+}
+
+func (r *documentRepo) CountByFileType(ctx context.Context, fileTypeID uint64) (int64, error) {
+	var count int64
+	err := scoped(ctx, r.db).Model(&domain.ApplicationDocument{}).
+		Where("file_type_id = ?", fileTypeID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *documentRepo) StorageKeysByApplication(ctx context.Context, appID uint64) ([]string, error) {
+	var keys []string
+	err := r.db.WithContext(ctx).Raw(`
+SELECT value FROM application_documents, jsonb_each_text(preview_keys) WHERE tenant_id = @tenant AND application_id = @app
+UNION ALL
+SELECT jsonb_array_elements_text(part_keys) FROM document_uploads WHERE tenant_id = @tenant AND application_id = @app`,
+		map[string]interface{}{"tenant": tenant.FromContext(ctx), "app": appID}).
+		Scan(&keys).Error
+	return keys, err
+}
+
+func (r *documentRepo) StorageUsed(ctx context.Context, appID uint64) (int64, error) {
+	var used int64
+	err := scoped(ctx, r.db).Model(&domain.ApplicationDocument{}).
+		Where("application_id = ?", appID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&used).Error
+	return used, err
+}
+
+func (r *documentRepo) StorageUsedByOwner(ctx context.Context, userID uint64) (int64, error) {
+	var used int64
+	err := r.db.WithContext(ctx).Raw(`
+SELECT COALESCE(SUM(d.size), 0)
+FROM application_documents d
+JOIN applications a ON a.id = d.application_id
+WHERE d.tenant_id = @tenant AND a.user_id = @user`,
+		map[string]interface{}{"tenant": tenant.FromContext(ctx), "user": userID}).
+		Scan(&used).Error
+	return used, err
+}
+
+// MarkForRescan puts the document back into quarantine until it is scanned
+// again.
+func (r *documentRepo) MarkForRescan(ctx context.Context, appID, id uint64) error {
+	return scoped(ctx, r.db).Model(&domain.ApplicationDocument{}).
+		Where("application_id = ? AND id = ?", appID, id).
+		Updates(map[string]interface{}{
+			"scan_status":    domain.ScanPending,
+			"scan_signature": "",
+			"scanned_at":     nil,
+		}).Error
+}
+
+// ListPendingScans returns documents of every tenant that wait for a scan,
+// those never attempted first.
+func (r *documentRepo) ListPendingScans(ctx context.Context, limit int) ([]domain.ApplicationDocument, error) {
+	var docs []domain.ApplicationDocument
+	err := r.db.WithContext(ctx).
+		Where("scan_status = ?", domain.ScanPending).
+		Order("scanned_at NULLS FIRST, id").
+		Limit(limit).
+		Find(&docs).Error
+	return docs, err
+}
+
+// SetScanResult records a scan of any tenant's document. A failed scan is
+// recorded as pending with the time of the attempt. Only pending documents
+// are updated, so a scan that raced with another instance cannot overwrite
+// its verdict; it reports whether the document was.
+func (r *documentRepo) SetScanResult(ctx context.Context, id uint64, status, signature string, at time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&domain.ApplicationDocument{}).
+		Where("id = ? AND scan_status = ?", id, domain.ScanPending).
+		Updates(map[string]interface{}{
+			"scan_status":    status,
+			"scan_signature": signature,
+			"scanned_at":     at,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// ListPendingPreviews returns documents of every tenant that were scanned
+// clean and wait for their previews, those never attempted first.
+func (r *documentRepo) ListPendingPreviews(ctx context.Context, limit int) ([]domain.ApplicationDocument, error) {
+	var docs []domain.ApplicationDocument
+	err := r.db.WithContext(ctx).
+		Where("preview_status = ? AND scan_status = ?", domain.PreviewPending, domain.ScanClean).
+		Order("previewed_at NULLS FIRST, id").
+		Limit(limit).
+		Find(&docs).Error
+	return docs, err
+}
+
+// SetPreviewResult records a preview attempt on any tenant's document. A
+// failed attempt that may be retried is recorded as pending with the time of
+// the attempt.
+func (r *documentRepo) SetPreviewResult(ctx context.Context, id uint64, status string, keys map[string]string, at time.Time) error {
+	if keys == nil {
+		keys = map[string]string{}
+	}
+	encoded, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(&domain.ApplicationDocument{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"preview_status": status,
+			"preview_keys":   gorm.Expr("CAST(? AS JSONB)", string(encoded)),
+			"previewed_at":   at,
+		}).Error
+}
@@ -1,19 +1,54 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrAlreadyMerged is returned by Merge when one of the applications was
+// merged by a concurrent request.
+var ErrAlreadyMerged = errors.New("application has already been merged")
+
+// ErrLegalHold is returned by Delete and Merge for an application on legal
+// hold.
+var ErrLegalHold = errors.New("application is on legal hold")
+
 type ApplicationRepository interface {
-	Create(app *domain.Application) error
-	GetByID(id uint64) (*domain.Application, error)
-	Update(app *domain.Application) error
-	Delete(id uint64) error
-	List(params ApplicationListParams) ([]domain.Application, int64, error)
+	Create(ctx context.Context, app *domain.Application) error
+	GetByID(ctx context.Context, id uint64) (*domain.Application, error)
+	FindByID(ctx context.Context, id uint64) (*domain.Application, error)
+	Update(ctx context.Context, app *domain.Application) error
+	Delete(ctx context.Context, id uint64) error
+	List(ctx context.Context, params ApplicationListParams) ([]domain.Application, int64, error)
+	FindSimilar(ctx context.Context, userID uint64, name string, since time.Time, threshold float64) ([]domain.Application, error)
+	Merge(ctx context.Context, target *domain.Application, sourceIDs []uint64) error
+	// ListDocumentStateChanges returns the applications whose document state
+	// no longer matches their current documents: documents valid until
+	// before today are expired, those valid until warnUntil are expiring.
+	// Rejected documents do not count.
+	ListDocumentStateChanges(ctx context.Context, today, warnUntil time.Time) ([]DocumentStateChange, error)
+	// SetDocumentState moves the application from the document state
+	// previous to state. It reports false when the application is no longer
+	// in previous, because another instance already moved it.
+	SetDocumentState(ctx context.Context, id uint64, previous, state string) (bool, error)
+	// SetLegalHold puts the application on legal hold or releases it.
+	SetLegalHold(ctx context.Context, id uint64, hold bool, reason string, by *uint64, at *time.Time) error
+}
+
+// DocumentStateChange is the document state an application moves to.
+type DocumentStateChange struct {
+	ApplicationID uint64
+	Previous      string
+	State         string
+	// ValidUntil is the day the first of its documents expires, if any.
+	ValidUntil *time.Time
 }
 
 type appRepo struct {
@@ -29,19 +64,27 @@ type ApplicationListParams struct {
 	PageSize int
 	Q        string
 	UserID   uint64
-	From     *time.Time
-	To       *time.Time
-	Sort     string
-	Order    string
+	// AssigneeID limits the list to applications assigned to that user.
+	AssigneeID uint64
+	Category   string
+	From       *time.Time
+	To         *time.Time
+	Sort       string
+	Order      string
+
+	PossibleDuplicate *bool
+	// DocumentState limits the list to applications in that document state.
+	DocumentState string
 }
 
-func (r *appRepo) Create(app *domain.Application) error {
-	return r.db.Create(app).Error
+func (r *appRepo) Create(ctx context.Context, app *domain.Application) error {
+	app.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Create(app).Error
 }
 
-func (r *appRepo) GetByID(id uint64) (*domain.Application, error) {
+func (r *appRepo) GetByID(ctx context.Context, id uint64) (*domain.Application, error) {
 	var app domain.Application
-	err := r.db.Preload("Statuses").Preload("FileTypes").
+	err := scoped(ctx, r.db).Preload("Statuses").Preload("FileTypes").
 		First(&app, "id = ?", id).Error
 	if err != nil {
 		return nil, err
@@ -49,15 +92,58 @@ func (r *appRepo) GetByID(id uint64) (*domain.Application, error) {
 	return &app, nil
 }
 
-func (r *appRepo) Update(app *domain.Application) error {
-	return r.db.Save(app).Error
+// FindByID loads an application without preloading its associations.
+func (r *appRepo) FindByID(ctx context.Context, id uint64) (*domain.Application, error) {
+	var app domain.Application
+	if err := scoped(ctx, r.db).First(&app, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &app, nil
 }
 
-func (r *appRepo) Delete(id uint64) error {
-	return r.db.Delete(&domain.Application{}, "id = ?", id).Error
+// Update writes every column of app. Unlike Save it never falls back to an
+// insert, so a row outside the tenant cannot be created or overwritten.
+func (r *appRepo) Update(ctx context.Context, app *domain.Application) error {
+	return scoped(ctx, r.db).Model(app).
+		Select("*").Omit("id", "tenant_id", "created_at", "document_state", "legal_hold", "legal_hold_reason", "legal_hold_by", "legal_hold_at", clause.Associations).
+		Updates(app).Error
 }
 
-func (r *appRepo) List(params ApplicationListParams) ([]domain.Application, int64, error) {
+// Delete deletes the application with its documents, whose blobs are
+// released, and the applications merged into it. Applications on legal hold
+// are not deleted.
+func (r *appRepo) Delete(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the application keeps documents from being added until it
+		// is gone.
+		var app domain.Application
+		err := tx.Scopes(tenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&app, "id = ?", id).Error
+		if err != nil {
+			return err
+		}
+		if app.LegalHold {
+			return ErrLegalHold
+		}
+		var keys []string
+		err = tx.Scopes(tenantScope(ctx)).Model(&domain.ApplicationDocument{}).
+			Where("application_id = ?", id).
+			Pluck("storage_key", &keys).Error
+		if err != nil {
+			return err
+		}
+		// Merged applications have no documents left; they only redirect.
+		if err := tx.Scopes(tenantScope(ctx)).Delete(&domain.Application{}, "merged_into_id = ? AND NOT legal_hold", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Scopes(tenantScope(ctx)).Delete(&domain.Application{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return releaseBlobs(tx, tenant.FromContext(ctx), keys)
+	})
+}
+
+func (r *appRepo) List(ctx context.Context, params ApplicationListParams) ([]domain.Application, int64, error) {
 	var apps []domain.Application
 	var total int64
 
@@ -68,7 +154,7 @@ func (r *appRepo) List(params ApplicationListParams) ([]domain.Application, int6
 		params.PageSize = 20
 	}
 
-	query := r.db.Model(&domain.Application{})
+	query := scoped(ctx, r.db).Model(&domain.Application{}).Where("merged_into_id IS NULL")
 
 	if params.Q != "" {
 		q := "%" + params.Q + "%"
@@ -79,6 +165,18 @@ func (r *appRepo) List(params ApplicationListParams) ([]domain.Application, int6
 	if params.UserID > 0 {
 		query = query.Where("user_id = ?", params.UserID)
 	}
+	if params.AssigneeID > 0 {
+		query = query.Where("assignee_id = ?", params.AssigneeID)
+	}
+	if params.Category != "" {
+		query = query.Where("category = ?", params.Category)
+	}
+	if params.PossibleDuplicate != nil {
+		query = query.Where("possible_duplicate = ?", *params.PossibleDuplicate)
+	}
+	if params.DocumentState != "" {
+		query = query.Where("document_state = ?", params.DocumentState)
+	}
 	if params.From != nil {
 		query = query.Where("created_at >= ?", params.From)
 	}
@@ -105,3 +203,159 @@ func (r *appRepo) List(params ApplicationListParams) ([]domain.Application, int6
 
 	return apps, total, err
 }
+
+// FindSimilar returns the user's applications created since the given time
+// whose name has a trigram similarity of at least threshold with name.
+// Merged applications only redirect to their survivor and are left out.
+func (r *appRepo) FindSimilar(ctx context.Context, userID uint64, name string, since time.Time, threshold float64) ([]domain.Application, error) {
+	var apps []domain.Application
+	err := scoped(ctx, r.db).
+		Where("user_id = ? AND created_at >= ? AND similarity(name, ?) >= ? AND merged_into_id IS NULL", userID, since, name, threshold).
+		Order("created_at desc").
+		Limit(10).
+		Find(&apps).Error
+	return apps, err
+}
+
+// Merge moves the statuses, file types and documents of the source
+// applications onto target, saves target and marks the sources as merged into
+// it, all in one transaction. File types the survivor already has are dropped
+// from the sources rather than duplicated.
+func (r *appRepo) Merge(ctx context.Context, target *domain.Application, sourceIDs []uint64) error {
+	allIDs := append([]uint64{target.ID}, sourceIDs...)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the rows through the tenant scope also guarantees every
+		// application below belongs to the caller's tenant.
+		var locked []domain.Application
+		if err := tx.Scopes(tenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND merged_into_id IS NULL", allIDs).
+			Find(&locked).Error; err != nil {
+			return err
+		}
+		if len(locked) != len(allIDs) {
+			return ErrAlreadyMerged
+		}
+		// A held application has to stay as it is, and the documents of a
+		// held source would lose their hold on the target.
+		for _, app := range locked {
+			if app.LegalHold {
+				return ErrLegalHold
+			}
+		}
+
+		if err := tx.Model(&domain.ApplicationStatus{}).
+			Where("application_id IN ?", sourceIDs).
+			Update("application_id", target.ID).Error; err != nil {
+			return err
+		}
+
+		// Documents of a source file type that is about to be dropped as a
+		// duplicate move to the file type of the same document type that is
+		// kept.
+		if err := tx.Exec(`
+			UPDATE application_documents d
+			SET file_type_id = k.id
+			FROM application_uploaded_file_type f
+			JOIN LATERAL (
+				SELECT o.id FROM application_uploaded_file_type o
+				WHERE o.application_id IN ?
+				  AND o.document_type_id = f.document_type_id
+				ORDER BY (o.application_id = ?) DESC, o.id
+				LIMIT 1
+			) k ON TRUE
+			WHERE d.file_type_id = f.id
+			  AND f.application_id IN ?
+			  AND k.id <> f.id`, allIDs, target.ID, sourceIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			DELETE FROM application_uploaded_file_type f
+			WHERE f.application_id IN ?
+			  AND EXISTS (
+				SELECT 1 FROM application_uploaded_file_type o
+				WHERE o.application_id IN ?
+				  AND o.document_type_id = f.document_type_id
+				  AND (o.application_id = ? OR o.id < f.id)
+			  )`, sourceIDs, allIDs, target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.ApplicationUploadedFileType{}).
+			Where("application_id IN ?", sourceIDs).
+			Update("application_id", target.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&domain.ApplicationDocument{}).
+			Where("application_id IN ?", sourceIDs).
+			Update("application_id", target.ID).Error; err != nil {
+			return err
+		}
+
+		// Keep redirect chains one hop long.
+		if err := tx.Model(&domain.Application{}).Scopes(tenantScope(ctx)).
+			Where("merged_into_id IN ?", sourceIDs).
+			Update("merged_into_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.Application{}).
+			Where("id IN ?", sourceIDs).
+			Updates(map[string]interface{}{"merged_into_id": target.ID, "merged_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(target).
+			Select("*").Omit("id", "tenant_id", "created_at", "document_state", "legal_hold", "legal_hold_reason", "legal_hold_by", "legal_hold_at", clause.Associations).
+			Updates(target).Error
+	})
+}
+
+func (r *appRepo) ListDocumentStateChanges(ctx context.Context, today, warnUntil time.Time) ([]DocumentStateChange, error) {
+	var changes []DocumentStateChange
+	err := r.db.WithContext(ctx).Raw(`
+SELECT * FROM (
+    SELECT a.id AS application_id, a.document_state AS previous, d.valid_until,
+        CASE WHEN d.valid_until < CAST(@today AS DATE) THEN @expired
+             WHEN d.valid_until <= CAST(@warn AS DATE) THEN @expiring
+             ELSE '' END AS state
+    FROM applications a
+    LEFT JOIN (
+        SELECT application_id, MIN(valid_until) AS valid_until
+        FROM application_documents
+        WHERE tenant_id = @tenant AND is_current AND valid_until IS NOT NULL AND review_status <> @rejected
+        GROUP BY application_id
+    ) d ON d.application_id = a.id
+    WHERE a.tenant_id = @tenant AND a.merged_into_id IS NULL
+      AND (a.document_state <> '' OR d.valid_until <= CAST(@warn AS DATE))
+) s
+WHERE state <> previous
+ORDER BY application_id`,
+		map[string]interface{}{
+			"tenant":   tenant.FromContext(ctx),
+			"today":    today.Format("2006-01-02"),
+			"warn":     warnUntil.Format("2006-01-02"),
+			"expired":  domain.DocumentsExpired,
+			"expiring": domain.DocumentsExpiring,
+			"rejected": domain.ReviewRejected,
+		}).
+		Scan(&changes).Error
+	return changes, err
+}
+
+func (r *appRepo) SetDocumentState(ctx context.Context, id uint64, previous, state string) (bool, error) {
+	res := scoped(ctx, r.db).Model(&domain.Application{}).
+		Where("id = ? AND document_state = ?", id, previous).
+		UpdateColumn("document_state", state)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *appRepo) SetLegalHold(ctx context.Context, id uint64, hold bool, reason string, by *uint64, at *time.Time) error {
+	return scoped(ctx, r.db).Model(&domain.Application{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"legal_hold":        hold,
+			"legal_hold_reason": reason,
+			"legal_hold_by":     by,
+			"legal_hold_at":     at,
+		}).Error
+}
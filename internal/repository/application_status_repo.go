@@ -1,13 +1,17 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
 	"gorm.io/gorm"
 )
 
 type ApplicationStatusRepository interface {
-	Add(status *domain.ApplicationStatus) error
-	ListByApplication(appID uint64, page, pageSize int) ([]domain.ApplicationStatus, int64, error)
+	Add(ctx context.Context, status *domain.ApplicationStatus) error
+	Latest(ctx context.Context, appID uint64) (*domain.ApplicationStatus, error)
+	ListByApplication(ctx context.Context, appID uint64, page, pageSize int) ([]domain.ApplicationStatus, int64, error)
 }
 
 type statusRepo struct {
@@ -18,11 +22,26 @@ func NewApplicationStatusRepository(db *gorm.DB) ApplicationStatusRepository {
 	return &statusRepo{db: db}
 }
 
-func (r *statusRepo) Add(status *domain.ApplicationStatus) error {
-	return r.db.Create(status).Error
+func (r *statusRepo) Add(ctx context.Context, status *domain.ApplicationStatus) error {
+	status.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Create(status).Error
+}
+
+// Latest returns the most recent status of an application, or nil when it
+// has none.
+func (r *statusRepo) Latest(ctx context.Context, appID uint64) (*domain.ApplicationStatus, error) {
+	var statuses []domain.ApplicationStatus
+	err := scoped(ctx, r.db).Where("application_id = ?", appID).
+		Order("created_at desc, id desc").
+		Limit(1).
+		Find(&statuses).Error
+	if err != nil || len(statuses) == 0 {
+		return nil, err
+	}
+	return &statuses[0], nil
 }
 
-func (r *statusRepo) ListByApplication(appID uint64, page, pageSize int) ([]domain.ApplicationStatus, int64, error) {
+func (r *statusRepo) ListByApplication(ctx context.Context, appID uint64, page, pageSize int) ([]domain.ApplicationStatus, int64, error) {
 	var statuses []domain.ApplicationStatus
 	var total int64
 
@@ -33,7 +52,7 @@ func (r *statusRepo) ListByApplication(appID uint64, page, pageSize int) ([]doma
 		pageSize = 20
 	}
 
-	query := r.db.Model(&domain.ApplicationStatus{}).Where("application_id = ?", appID)
+	query := scoped(ctx, r.db).Model(&domain.ApplicationStatus{}).Where("application_id = ?", appID)
 
 	query.Count(&total)
 
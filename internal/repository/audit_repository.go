@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AuditRepository interface {
+	Record(ctx context.Context, event *domain.AuditEvent) error
+	// UseDownloadLink marks a single-use link as used. It reports false when
+	// the link was used before.
+	UseDownloadLink(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+	DeleteExpiredDownloadLinks(ctx context.Context, now time.Time) error
+}
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Record(ctx context.Context, event *domain.AuditEvent) error {
+	event.TenantID = tenant.FromContext(ctx)
+	if event.Details == nil {
+		event.Details = map[string]interface{}{}
+	}
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *auditRepo) UseDownloadLink(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.DownloadLinkUse{Nonce: nonce, TenantID: tenant.FromContext(ctx), ExpiresAt: expiresAt})
+	return res.RowsAffected == 1, res.Error
+}
+
+// DeleteExpiredDownloadLinks forgets used links of every tenant once they
+// expired; they are rejected for their expiry then.
+func (r *auditRepo) DeleteExpiredDownloadLinks(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&domain.DownloadLinkUse{}).Error
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"gorm.io/gorm"
+)
+
+// BlobDataKeyRepository keeps the data keys of encrypted blobs. Blob keys
+// carry the tenant, so the keys are not scoped to one.
+type BlobDataKeyRepository interface {
+	Create(ctx context.Context, key *domain.BlobDataKey) error
+	GetByID(ctx context.Context, id string) (*domain.BlobDataKey, error)
+	Delete(ctx context.Context, id string) error
+	DeleteByStorageKey(ctx context.Context, storageKey string) error
+	// ListNotWrappedWith returns up to limit data keys wrapped by another
+	// master key than masterKeyID, in order of ID starting after the ID
+	// after.
+	ListNotWrappedWith(ctx context.Context, masterKeyID, after string, limit int) ([]domain.BlobDataKey, error)
+	// Rewrap replaces the wrapped key if it is still wrapped by
+	// previousMasterKeyID, and reports whether it was.
+	Rewrap(ctx context.Context, id, previousMasterKeyID, masterKeyID string, wrapped []byte, at time.Time) (bool, error)
+}
+
+type blobDataKeyRepo struct {
+	db *gorm.DB
+}
+
+func NewBlobDataKeyRepository(db *gorm.DB) BlobDataKeyRepository {
+	return &blobDataKeyRepo{db: db}
+}
+
+func (r *blobDataKeyRepo) Create(ctx context.Context, key *domain.BlobDataKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *blobDataKeyRepo) GetByID(ctx context.Context, id string) (*domain.BlobDataKey, error) {
+	var key domain.BlobDataKey
+	if err := r.db.WithContext(ctx).First(&key, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *blobDataKeyRepo) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&domain.BlobDataKey{}, "id = ?", id).Error
+}
+
+func (r *blobDataKeyRepo) DeleteByStorageKey(ctx context.Context, storageKey string) error {
+	return r.db.WithContext(ctx).Delete(&domain.BlobDataKey{}, "storage_key = ?", storageKey).Error
+}
+
+func (r *blobDataKeyRepo) ListNotWrappedWith(ctx context.Context, masterKeyID, after string, limit int) ([]domain.BlobDataKey, error) {
+	var keys []domain.BlobDataKey
+	err := r.db.WithContext(ctx).
+		Where("master_key_id <> ? AND id > ?", masterKeyID, after).
+		Order("id").
+		Limit(limit).
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *blobDataKeyRepo) Rewrap(ctx context.Context, id, previousMasterKeyID, masterKeyID string, wrapped []byte, at time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&domain.BlobDataKey{}).
+		Where("id = ? AND master_key_id = ?", id, previousMasterKeyID).
+		Updates(map[string]interface{}{
+			"master_key_id": masterKeyID,
+			"wrapped_key":   wrapped,
+			"rewrapped_at":  at,
+		})
+	return res.RowsAffected > 0, res.Error
+}
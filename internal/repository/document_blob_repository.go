@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBlobMissing is returned when a document is recorded for content whose
+// blob was collected in the meantime.
+var ErrBlobMissing = errors.New("document blob is missing")
+
+type DocumentBlobRepository interface {
+	// Reserve returns the tenant's blob of the content with the checksum,
+	// recording one under key when there is none. A blob without
+	// references is kept from collection for another grace period, so a
+	// document can be recorded for it.
+	Reserve(ctx context.Context, checksum string, size int64, key string) (*domain.DocumentBlob, error)
+	MarkStored(ctx context.Context, id uint64) error
+	// CollectGarbage deletes up to limit blobs of every tenant that have had
+	// no references since before. The blobs are marked as not stored, and
+	// that is committed, before remove deletes the content of each; blobs
+	// whose content could not be removed are kept. It returns how many
+	// blobs were deleted.
+	CollectGarbage(ctx context.Context, before time.Time, limit int, remove func(key string) error) (int, error)
+}
+
+type documentBlobRepo struct {
+	db *gorm.DB
+}
+
+func NewDocumentBlobRepository(db *gorm.DB) DocumentBlobRepository {
+	return &documentBlobRepo{db: db}
+}
+
+func (r *documentBlobRepo) Reserve(ctx context.Context, checksum string, size int64, key string) (*domain.DocumentBlob, error) {
+	var blobs []domain.DocumentBlob
+	err := r.db.WithContext(ctx).Raw(`
+INSERT INTO document_blobs (tenant_id, checksum, storage_key, size, unreferenced_at)
+VALUES (@tenant, @checksum, @key, @size, NOW())
+ON CONFLICT (tenant_id, checksum) DO UPDATE
+SET unreferenced_at = CASE WHEN document_blobs.ref_count = 0 THEN NOW() ELSE document_blobs.unreferenced_at END
+RETURNING *`,
+		map[string]interface{}{"tenant": tenant.FromContext(ctx), "checksum": checksum, "key": key, "size": size}).
+		Scan(&blobs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(blobs) == 0 {
+		return nil, ErrBlobMissing
+	}
+	return &blobs[0], nil
+}
+
+func (r *documentBlobRepo) MarkStored(ctx context.Context, id uint64) error {
+	return scoped(ctx, r.db).Model(&domain.DocumentBlob{}).
+		Where("id = ?", id).
+		Update("stored", true).Error
+}
+
+func (r *documentBlobRepo) CollectGarbage(ctx context.Context, before time.Time, limit int, remove func(key string) error) (int, error) {
+	// First mark the blobs as no longer stored and commit, so that a
+	// concurrent Reserve stores the content again rather than reuse content
+	// about to be deleted, even if the delete below never commits.
+	var ids []uint64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.DocumentBlob{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("ref_count = 0 AND unreferenced_at < ?", before).
+			Order("unreferenced_at").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		return tx.Model(&domain.DocumentBlob{}).
+			Where("id IN ?", ids).
+			Update("stored", false).Error
+	})
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	collected := 0
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A blob reserved in the meantime has a later unreferenced_at, and
+		// one reserved while the rows are locked waits and records the
+		// blob again.
+		var blobs []domain.DocumentBlob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id IN ? AND ref_count = 0 AND NOT stored AND unreferenced_at < ?", ids, before).
+			Find(&blobs).Error
+		if err != nil {
+			return err
+		}
+		var removed []uint64
+		for _, blob := range blobs {
+			if remove(blob.StorageKey) == nil {
+				removed = append(removed, blob.ID)
+			}
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+		collected = len(removed)
+		return tx.Delete(&domain.DocumentBlob{}, "id IN ?", removed).Error
+	})
+	return collected, err
+}
+
+// retainBlob adds a reference to the tenant's blob stored under key.
+func retainBlob(tx *gorm.DB, tenantID, key string) error {
+	res := tx.Model(&domain.DocumentBlob{}).
+		Where("tenant_id = ? AND storage_key = ?", tenantID, key).
+		Updates(map[string]interface{}{
+			"ref_count":       gorm.Expr("ref_count + 1"),
+			"unreferenced_at": nil,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBlobMissing
+	}
+	return nil
+}
+
+// releaseBlobs drops a reference to the tenant's blob stored under each key;
+// keys repeat for blobs referenced more than once. Blobs left without
+// references become garbage.
+func releaseBlobs(tx *gorm.DB, tenantID string, keys []string) error {
+	refs := map[string]int{}
+	for _, key := range keys {
+		refs[key]++
+	}
+	for key, n := range refs {
+		err := tx.Exec(`
+UPDATE document_blobs
+SET ref_count = GREATEST(ref_count - ?, 0),
+    unreferenced_at = CASE WHEN ref_count <= ? THEN NOW() ELSE unreferenced_at END
+WHERE tenant_id = ? AND storage_key = ?`, n, n, tenantID, key).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+)
+
+type DocumentTypeRepository interface {
+	Create(ctx context.Context, docType *domain.DocumentType) error
+	GetByID(ctx context.Context, id uint64) (*domain.DocumentType, error)
+	GetByKey(ctx context.Context, key string) (*domain.DocumentType, error)
+	List(ctx context.Context) ([]domain.DocumentType, error)
+	Update(ctx context.Context, docType *domain.DocumentType) error
+	Delete(ctx context.Context, id uint64) error
+	// CountFileTypes returns how many application file types refer to the
+	// document type.
+	CountFileTypes(ctx context.Context, id uint64) (int64, error)
+}
+
+type documentTypeRepo struct {
+	db *gorm.DB
+}
+
+func NewDocumentTypeRepository(db *gorm.DB) DocumentTypeRepository {
+	return &documentTypeRepo{db: db}
+}
+
+func (r *documentTypeRepo) Create(ctx context.Context, docType *domain.DocumentType) error {
+	docType.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Create(docType).Error
+}
+
+func (r *documentTypeRepo) GetByID(ctx context.Context, id uint64) (*domain.DocumentType, error) {
+	var docType domain.DocumentType
+	if err := scoped(ctx, r.db).First(&docType, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &docType, nil
+}
+
+func (r *documentTypeRepo) GetByKey(ctx context.Context, key string) (*domain.DocumentType, error) {
+	var docType domain.DocumentType
+	if err := scoped(ctx, r.db).First(&docType, "key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return &docType, nil
+}
+
+func (r *documentTypeRepo) List(ctx context.Context) ([]domain.DocumentType, error) {
+	var docTypes []domain.DocumentType
+	err := scoped(ctx, r.db).Order("key").Find(&docTypes).Error
+	return docTypes, err
+}
+
+// Update saves everything but the key.
+func (r *documentTypeRepo) Update(ctx context.Context, docType *domain.DocumentType) error {
+	return scoped(ctx, r.db).Model(docType).
+		Select("display_name", "description", "allowed_mime_types", "allowed_extensions", "max_size", "retention_days", "updated_at").
+		Updates(docType).Error
+}
+
+func (r *documentTypeRepo) Delete(ctx context.Context, id uint64) error {
+	return scoped(ctx, r.db).Delete(&domain.DocumentType{}, "id = ?", id).Error
+}
+
+func (r *documentTypeRepo) CountFileTypes(ctx context.Context, id uint64) (int64, error) {
+	var count int64
+	err := scoped(ctx, r.db).Model(&domain.ApplicationUploadedFileType{}).
+		Where("document_type_id = ?", id).
+		Count(&count).Error
+	return count, err
+}
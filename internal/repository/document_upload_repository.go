@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+)
+
+type DocumentUploadRepository interface {
+	Create(ctx context.Context, upload *domain.DocumentUpload) error
+	GetByID(ctx context.Context, appID uint64, id string) (*domain.DocumentUpload, error)
+	// AppendPart records a chunk stored under partKey. It only succeeds while
+	// the upload is still at offset, so concurrent chunks cannot both land.
+	AppendPart(ctx context.Context, upload *domain.DocumentUpload, offset int64, partKey string, size int64, expiresAt time.Time) (bool, error)
+	// Claim reserves a complete upload for the request turning it into a
+	// document, until until. It fails while another request holds an
+	// unexpired claim and once the upload became a document.
+	Claim(ctx context.Context, id string, now, until time.Time) (bool, error)
+	// Unclaim gives up a claim, so the upload can be completed again.
+	Unclaim(ctx context.Context, id string) error
+	// SetDocument records the document a completed upload became; its parts
+	// are no longer needed.
+	SetDocument(ctx context.Context, id string, documentID uint64) error
+	Delete(ctx context.Context, appID uint64, id string) error
+	// DeleteUnclaimed deletes the upload unless a request holds an unexpired
+	// claim on it. It reports whether the upload was deleted.
+	DeleteUnclaimed(ctx context.Context, appID uint64, id string, now time.Time) (bool, error)
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.DocumentUpload, error)
+	DeleteByID(ctx context.Context, id string) error
+}
+
+type documentUploadRepo struct {
+	db *gorm.DB
+}
+
+func NewDocumentUploadRepository(db *gorm.DB) DocumentUploadRepository {
+	return &documentUploadRepo{db: db}
+}
+
+func (r *documentUploadRepo) Create(ctx context.Context, upload *domain.DocumentUpload) error {
+	upload.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Create(upload).Error
+}
+
+func (r *documentUploadRepo) GetByID(ctx context.Context, appID uint64, id string) (*domain.DocumentUpload, error) {
+	var upload domain.DocumentUpload
+	err := scoped(ctx, r.db).First(&upload, "application_id = ? AND id = ?", appID, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *documentUploadRepo) AppendPart(ctx context.Context, upload *domain.DocumentUpload, offset int64, partKey string, size int64, expiresAt time.Time) (bool, error) {
+	parts := append(append([]string{}, upload.PartKeys...), partKey)
+	res := scoped(ctx, r.db).Model(&domain.DocumentUpload{}).
+		Where("id = ? AND upload_offset = ?", upload.ID, offset).
+		Updates(map[string]interface{}{
+			"upload_offset": offset + size,
+			"part_keys":     gorm.Expr("part_keys || jsonb_build_array(CAST(? AS TEXT))", partKey),
+			"expires_at":    expiresAt,
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	upload.Offset = offset + size
+	upload.PartKeys = parts
+	upload.ExpiresAt = expiresAt
+	return true, nil
+}
+
+func (r *documentUploadRepo) Claim(ctx context.Context, id string, now, until time.Time) (bool, error) {
+	res := scoped(ctx, r.db).Model(&domain.DocumentUpload{}).
+		Where("id = ? AND document_id IS NULL AND (finalizing_until IS NULL OR finalizing_until < ?)", id, now).
+		Update("finalizing_until", until)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *documentUploadRepo) Unclaim(ctx context.Context, id string) error {
+	return scoped(ctx, r.db).Model(&domain.DocumentUpload{}).
+		Where("id = ?", id).
+		Update("finalizing_until", nil).Error
+}
+
+func (r *documentUploadRepo) SetDocument(ctx context.Context, id string, documentID uint64) error {
+	return scoped(ctx, r.db).Model(&domain.DocumentUpload{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"document_id":      documentID,
+			"part_keys":        gorm.Expr("'[]'::jsonb"),
+			"finalizing_until": nil,
+		}).Error
+}
+
+func (r *documentUploadRepo) Delete(ctx context.Context, appID uint64, id string) error {
+	return scoped(ctx, r.db).Delete(&domain.DocumentUpload{}, "application_id = ? AND id = ?", appID, id).Error
+}
+
+func (r *documentUploadRepo) DeleteUnclaimed(ctx context.Context, appID uint64, id string, now time.Time) (bool, error) {
+	res := scoped(ctx, r.db).
+		Where("application_id = ? AND id = ? AND (finalizing_until IS NULL OR finalizing_until < ?)", appID, id, now).
+		Delete(&domain.DocumentUpload{})
+	return res.RowsAffected == 1, res.Error
+}
+
+// ListExpired returns expired uploads of every tenant.
+func (r *documentUploadRepo) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.DocumentUpload, error) {
+	var uploads []domain.DocumentUpload
+	err := r.db.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
+
+// DeleteByID removes an upload of any tenant.
+func (r *documentUploadRepo) DeleteByID(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&domain.DocumentUpload{}, "id = ?", id).Error
+}
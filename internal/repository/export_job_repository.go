@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+)
+
+type ExportJobRepository interface {
+	Create(ctx context.Context, job *domain.ExportJob) error
+	GetByID(ctx context.Context, id uint64) (*domain.ExportJob, error)
+	// Claim marks the oldest pending job of any tenant as running and
+	// returns it, or nil when there is none. Jobs left running since before
+	// staleBefore, e.g. by an instance that died, are claimed again.
+	Claim(ctx context.Context, staleBefore time.Time) (*domain.ExportJob, error)
+	// Finish records the outcome of a job of any tenant.
+	Finish(ctx context.Context, job *domain.ExportJob) error
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.ExportJob, error)
+	DeleteByID(ctx context.Context, id uint64) error
+}
+
+type exportJobRepo struct {
+	db *gorm.DB
+}
+
+func NewExportJobRepository(db *gorm.DB) ExportJobRepository {
+	return &exportJobRepo{db: db}
+}
+
+func (r *exportJobRepo) Create(ctx context.Context, job *domain.ExportJob) error {
+	job.TenantID = tenant.FromContext(ctx)
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *exportJobRepo) GetByID(ctx context.Context, id uint64) (*domain.ExportJob, error) {
+	var job domain.ExportJob
+	if err := scoped(ctx, r.db).First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *exportJobRepo) Claim(ctx context.Context, staleBefore time.Time) (*domain.ExportJob, error) {
+	var jobs []domain.ExportJob
+	err := r.db.WithContext(ctx).Raw(`
+UPDATE export_jobs SET status = @running, started_at = NOW()
+WHERE id = (
+    SELECT id FROM export_jobs
+    WHERE status = @pending OR (status = @running AND started_at < @stale)
+    ORDER BY id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *`,
+		map[string]interface{}{"running": domain.ExportRunning, "pending": domain.ExportPending, "stale": staleBefore}).
+		Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+func (r *exportJobRepo) Finish(ctx context.Context, job *domain.ExportJob) error {
+	return r.db.WithContext(ctx).Model(&domain.ExportJob{}).
+		Where("id = ?", job.ID).
+		Updates(map[string]interface{}{
+			"status":         job.Status,
+			"error":          job.Error,
+			"document_count": job.DocumentCount,
+			"size":           job.Size,
+			"storage_key":    job.StorageKey,
+			"finished_at":    job.FinishedAt,
+			"expires_at":     job.ExpiresAt,
+		}).Error
+}
+
+// ListExpired returns finished jobs of every tenant whose archive expired.
+func (r *exportJobRepo) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.ExportJob, error) {
+	var jobs []domain.ExportJob
+	err := r.db.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// DeleteByID deletes a job of any tenant.
+func (r *exportJobRepo) DeleteByID(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).Delete(&domain.ExportJob{}, "id = ?", id).Error
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HMACNonceRepository remembers the nonces of signed requests. Signing
+// clients are configured per service rather than per tenant, so nonces are
+// not tenant scoped.
+type HMACNonceRepository interface {
+	// UseNonce records the nonce of a client. It reports false when the
+	// nonce was used before.
+	UseNonce(ctx context.Context, clientID, nonce string, expiresAt time.Time) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type hmacNonceRepo struct {
+	db *gorm.DB
+}
+
+func NewHMACNonceRepository(db *gorm.DB) HMACNonceRepository {
+	return &hmacNonceRepo{db: db}
+}
+
+func (r *hmacNonceRepo) UseNonce(ctx context.Context, clientID, nonce string, expiresAt time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.HMACNonce{ClientID: clientID, Nonce: nonce, ExpiresAt: expiresAt})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *hmacNonceRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&domain.HMACNonce{}).Error
+}
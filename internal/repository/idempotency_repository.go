@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	Claim(ctx context.Context, record *domain.IdempotencyKey) (bool, error)
+	Get(ctx context.Context, key string) (*domain.IdempotencyKey, error)
+	Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	Release(ctx context.Context, key string) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type idempotencyRepo struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepo{db: db}
+}
+
+// byCaller limits a query to the keys of the calling principal, so a key
+// only ever replays to the caller that used it.
+func byCaller(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return scoped(ctx, db).Where("principal = ?", callerOf(ctx))
+}
+
+// callerOf returns the subject of the principal on ctx, or "" for
+// unauthenticated requests.
+func callerOf(ctx context.Context) string {
+	if p := auth.FromContext(ctx); p != nil {
+		return p.Subject
+	}
+	return ""
+}
+
+// Claim inserts a pending record for the key. It reports false when an
+// unexpired record already exists; an expired one is replaced.
+func (r *idempotencyRepo) Claim(ctx context.Context, record *domain.IdempotencyKey) (bool, error) {
+	if err := byCaller(ctx, r.db).Where("key = ? AND expires_at < ?", record.Key, time.Now()).
+		Delete(&domain.IdempotencyKey{}).Error; err != nil {
+		return false, err
+	}
+	record.TenantID = tenant.FromContext(ctx)
+	record.Principal = callerOf(ctx)
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *idempotencyRepo) Get(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	var record domain.IdempotencyKey
+	if err := byCaller(ctx, r.db).First(&record, "key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepo) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	return byCaller(ctx, r.db).Model(&domain.IdempotencyKey{}).
+		Where("key = ?", key).
+		Updates(map[string]interface{}{
+			"completed":     true,
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+		}).Error
+}
+
+// Release drops a pending record so the request can be retried.
+func (r *idempotencyRepo) Release(ctx context.Context, key string) error {
+	return byCaller(ctx, r.db).Where("key = ? AND completed = ?", key, false).
+		Delete(&domain.IdempotencyKey{}).Error
+}
+
+// DeleteExpired purges expired keys of every tenant.
+func (r *idempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&domain.IdempotencyKey{}).Error
+}
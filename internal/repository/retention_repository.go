@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+)
+
+// latestStatus joins the latest status of application a as l.
+const latestStatus = `
+JOIN LATERAL (
+    SELECT s.status, s.created_at FROM application_status s
+    WHERE s.tenant_id = a.tenant_id AND s.application_id = a.id
+    ORDER BY s.created_at DESC, s.id DESC
+    LIMIT 1
+) l ON TRUE`
+
+type RetentionRepository interface {
+	// ListExpiredApplications returns up to limit applications whose latest
+	// status is status and was set before closedBefore, in order of ID
+	// starting after the ID after. Applications on legal hold are included.
+	ListExpiredApplications(ctx context.Context, status string, closedBefore time.Time, after uint64, limit int) ([]ExpiredApplication, error)
+	// ListExpiredDocuments returns up to limit document versions whose
+	// application's latest status is one of closed and was set longer ago
+	// than the retention of their document type at now, in order of ID
+	// starting after the ID after. Documents of applications on legal hold
+	// are included.
+	ListExpiredDocuments(ctx context.Context, closed []string, now time.Time, after uint64, limit int) ([]ExpiredDocument, error)
+}
+
+// ExpiredApplication is an application past its retention.
+type ExpiredApplication struct {
+	ApplicationID uint64
+	Code          string
+	Category      string
+	Status        string
+	ClosedAt      time.Time
+	LegalHold     bool
+	// Documents and Size count every version of its documents.
+	Documents int64
+	Size      int64
+}
+
+// ExpiredDocument is a document version past the retention of its document
+// type.
+type ExpiredDocument struct {
+	ID            uint64
+	ApplicationID uint64
+	LineageID     uint64
+	Version       int
+	DocumentType  string
+	RetentionDays int
+	Status        string
+	ClosedAt      time.Time
+	Size          int64
+	Checksum      string
+	UploadedAt    time.Time
+	LegalHold     bool
+}
+
+type retentionRepo struct {
+	db *gorm.DB
+}
+
+func NewRetentionRepository(db *gorm.DB) RetentionRepository {
+	return &retentionRepo{db: db}
+}
+
+func (r *retentionRepo) ListExpiredApplications(ctx context.Context, status string, closedBefore time.Time, after uint64, limit int) ([]ExpiredApplication, error) {
+	var apps []ExpiredApplication
+	err := r.db.WithContext(ctx).Raw(`
+SELECT a.id AS application_id, a.code, a.category, l.status, l.created_at AS closed_at, a.legal_hold,
+    COUNT(d.id) AS documents, COALESCE(SUM(d.size), 0) AS size
+FROM applications a`+latestStatus+`
+LEFT JOIN application_documents d ON d.application_id = a.id
+WHERE a.tenant_id = @tenant AND a.merged_into_id IS NULL AND a.id > @after
+  AND l.status = @status AND l.created_at < @before
+GROUP BY a.id, l.status, l.created_at
+ORDER BY a.id
+LIMIT @limit`,
+		map[string]interface{}{
+			"tenant": tenant.FromContext(ctx),
+			"status": status,
+			"before": closedBefore,
+			"after":  after,
+			"limit":  limit,
+		}).
+		Scan(&apps).Error
+	return apps, err
+}
+
+func (r *retentionRepo) ListExpiredDocuments(ctx context.Context, closed []string, now time.Time, after uint64, limit int) ([]ExpiredDocument, error) {
+	if len(closed) == 0 {
+		return nil, nil
+	}
+	var docs []ExpiredDocument
+	err := r.db.WithContext(ctx).Raw(`
+SELECT d.id, d.application_id, d.lineage_id, d.version, t.key AS document_type, t.retention_days,
+    l.status, l.created_at AS closed_at, d.size, d.checksum, d.created_at AS uploaded_at, a.legal_hold
+FROM application_documents d
+JOIN applications a ON a.id = d.application_id
+JOIN application_uploaded_file_type f ON f.id = d.file_type_id
+JOIN document_types t ON t.id = f.document_type_id`+latestStatus+`
+WHERE d.tenant_id = @tenant AND d.id > @after
+  AND t.retention_days > 0
+  AND l.status IN @closed AND l.created_at + make_interval(days => t.retention_days) < @now
+ORDER BY d.id
+LIMIT @limit`,
+		map[string]interface{}{
+			"tenant": tenant.FromContext(ctx),
+			"closed": closed,
+			"now":    now,
+			"after":  after,
+			"limit":  limit,
+		}).
+		Scan(&docs).Error
+	return docs, err
+}
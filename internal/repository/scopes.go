@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tenantScope restricts a query to the tenant carried by ctx.
+func tenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"},
+			Value:  tenant.FromContext(ctx),
+		})
+	}
+}
+
+// scoped returns a session bound to ctx whose queries only see the tenant's
+// rows. Every read, update and delete in this package goes through it.
+func scoped(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.WithContext(ctx).Scopes(tenantScope(ctx))
+}
@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the INSTREAM chunks. It has to stay below
+// clamd's StreamMaxLength, which only limits the total anyway.
+const chunkSize = 64 << 10
+
+// Clamd scans content with a clamd daemon using the INSTREAM command.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd returns a scanner for the daemon at address, either
+// "tcp://host:port", "unix:///path/to/clamd.sock" or a plain "host:port".
+// Each scan opens its own connection and fails after timeout.
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network, addr := "tcp", address
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		addr = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	}
+	if addr == "" {
+		return nil, errors.New("clamd address is required")
+	}
+	return &Clamd{network: network, address: addr, timeout: timeout}, nil
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The z prefix makes clamd terminate its reply with a NUL byte.
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return Result{}, fmt.Errorf("send to clamd: %w", err)
+	}
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return Result{}, fmt.Errorf("send to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Result{}, fmt.Errorf("send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return Result{}, fmt.Errorf("read clamd reply: %w", err)
+	}
+	return parseReply(strings.TrimRight(reply, "\x00"))
+}
+
+// parseReply reads "stream: OK", "stream: <signature> FOUND" or an error
+// such as "INSTREAM size limit exceeded. ERROR".
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(reply)
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		sig := strings.TrimSuffix(reply, " FOUND")
+		if i := strings.Index(sig, ": "); i >= 0 {
+			sig = sig[i+2:]
+		}
+		return Result{Infected: true, Signature: sig}, nil
+	case strings.HasSuffix(reply, ": OK"):
+		return Result{}, nil
+	default:
+		return Result{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
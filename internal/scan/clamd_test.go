@@ -0,0 +1,87 @@
+package scan_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/internal/scan"
+	"github.com/Naomejoy/app-service/internal/scan/scantest"
+)
+
+func newFakeClamd(t *testing.T) (*scantest.Clamd, *scan.Clamd) {
+	t.Helper()
+	fake, err := scantest.NewClamd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fake.Close() })
+	scanner, err := scan.NewClamd(fake.Address(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, scanner
+}
+
+func TestClamdInstream(t *testing.T) {
+	_, scanner := newFakeClamd(t)
+	// Larger than one INSTREAM chunk, with the signature across the
+	// boundary, so the chunks must be reassembled.
+	infected := bytes.Repeat([]byte("a"), 64<<10-10)
+	infected = append(infected, scantest.EICAR...)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		infected  bool
+		signature string
+	}{
+		{"empty", nil, false, ""},
+		{"clean", []byte("just a passport scan"), false, ""},
+		{"clean over several chunks", bytes.Repeat([]byte("b"), 200<<10), false, ""},
+		{"eicar", []byte(scantest.EICAR), true, "Eicar-Test-Signature"},
+		{"eicar across chunks", infected, true, "Eicar-Test-Signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), bytes.NewReader(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Errorf("Scan() = %+v, want infected %v, signature %q", result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+// An error reply is no verdict: the document stays pending. clamd closes
+// the connection after it, so the error may also come from sending the
+// rest of the stream.
+func TestClamdStreamLimit(t *testing.T) {
+	fake, scanner := newFakeClamd(t)
+	fake.MaxStream = 1 << 10
+
+	if _, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, 4<<10))); err == nil {
+		t.Fatal("Scan() reached a verdict on a stream clamd refused")
+	}
+}
+
+func TestClamdUnreachable(t *testing.T) {
+	fake, scanner := newFakeClamd(t)
+	fake.Close()
+
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("x")); err == nil {
+		t.Fatal("Scan() succeeded without a daemon")
+	}
+}
+
+func TestNewClamdAddress(t *testing.T) {
+	for _, address := range []string{"", "tcp://", "unix://"} {
+		if _, err := scan.NewClamd(address, time.Second); err == nil {
+			t.Errorf("NewClamd(%q) accepted an empty address", address)
+		}
+	}
+}
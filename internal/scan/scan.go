@@ -0,0 +1,28 @@
+// Package scan checks uploaded content for malware.
+package scan
+
+import (
+	"context"
+	"io"
+)
+
+// Result is the verdict on scanned content. Signature names what was found
+// when Infected is set.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner scans content. An error means no verdict was reached, so the scan
+// should be retried; it never means the content is infected.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Noop reports all content as clean. It is meant for development and for
+// deployments that scan elsewhere.
+type Noop struct{}
+
+func (Noop) Scan(_ context.Context, r io.Reader) (Result, error) {
+	return Result{}, nil
+}
@@ -0,0 +1,127 @@
+// Package scantest provides a fake clamd for tests and local development.
+package scantest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// EICAR is the standard antivirus test file. The fake clamd reports it as
+// "Eicar-Test-Signature", like the real one.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!H+H*`
+
+// Clamd speaks enough of the clamd protocol for the scanner: PING and
+// INSTREAM, with the z (NUL) and n (newline) command prefixes. Streams that
+// contain one of the signatures are reported as infected.
+type Clamd struct {
+	// Signatures maps content to the signature name reported for it.
+	Signatures map[string]string
+	// MaxStream makes streams longer than this fail like clamd's
+	// StreamMaxLength; 0 means no limit.
+	MaxStream int
+
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// NewClamd starts a fake clamd on a random local TCP port that detects the
+// EICAR test file.
+func NewClamd() (*Clamd, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	c := &Clamd{
+		Signatures: map[string]string{EICAR: "Eicar-Test-Signature"},
+		listener:   l,
+	}
+	c.wg.Add(1)
+	go c.serve()
+	return c, nil
+}
+
+// Address returns the address to pass to scan.NewClamd.
+func (c *Clamd) Address() string {
+	return "tcp://" + c.listener.Addr().String()
+}
+
+// Close stops the fake and waits for open connections to finish.
+func (c *Clamd) Close() error {
+	err := c.listener.Close()
+	c.wg.Wait()
+	return err
+}
+
+func (c *Clamd) serve() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			defer conn.Close()
+			c.handle(conn)
+		}()
+	}
+}
+
+func (c *Clamd) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	delim := byte('\n')
+	if prefix == 'z' {
+		delim = 0
+	} else if prefix != 'n' {
+		r.UnreadByte()
+	}
+	cmd, err := r.ReadString(delim)
+	if err != nil {
+		return
+	}
+	reply := func(s string) {
+		conn.Write(append([]byte(s), delim))
+	}
+
+	switch strings.TrimSpace(strings.TrimRight(cmd, "\x00")) {
+	case "PING":
+		reply("PONG")
+	case "INSTREAM":
+		var stream bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+				return
+			}
+			if c.MaxStream > 0 && stream.Len() > c.MaxStream {
+				reply("INSTREAM size limit exceeded. ERROR")
+				return
+			}
+		}
+		for content, name := range c.Signatures {
+			if bytes.Contains(stream.Bytes(), []byte(content)) {
+				reply("stream: " + name + " FOUND")
+				return
+			}
+		}
+		reply("stream: OK")
+	default:
+		reply("UNKNOWN COMMAND")
+	}
+}
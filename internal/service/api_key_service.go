@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"gorm.io/gorm"
+)
+
+const defaultRotationOverlap = 24 * time.Hour
+
+var (
+	ErrAPIKeyNotFound   = errors.New("API key not found")
+	ErrInvalidAPIKeyReq = errors.New("invalid API key request")
+)
+
+type APIKeyService struct {
+	keyRepo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(keyRepo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{keyRepo: keyRepo}
+}
+
+func (s *APIKeyService) Create(ctx context.Context, req CreateAPIKeyRequest) (*APIKeySecretResponse, error) {
+	if err := checkGrantableScopes(ctx, req.Scopes); err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("%w: expiresIn must be a positive duration", ErrInvalidAPIKeyReq)
+		}
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	secret, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &domain.APIKey{
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   auth.HashAPIKey(secret),
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+		CreatedBy: principalSubject(ctx),
+	}
+	if err := s.keyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	return &APIKeySecretResponse{APIKey: key, Secret: secret}, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context) ([]domain.APIKey, error) {
+	return s.keyRepo.List(ctx)
+}
+
+// Rotate issues a replacement with the same name, scopes and lifetime, and
+// lets the old key keep working for the overlap window.
+func (s *APIKeyService) Rotate(ctx context.Context, id uint64, req RotateAPIKeyRequest) (*APIKeySecretResponse, error) {
+	overlap := defaultRotationOverlap
+	if req.Overlap != "" {
+		d, err := time.ParseDuration(req.Overlap)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%w: overlap must be a non-negative duration", ErrInvalidAPIKeyReq)
+		}
+		overlap = d
+	}
+
+	old, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !old.Active(now) {
+		return nil, fmt.Errorf("%w: key is expired or revoked", ErrInvalidAPIKeyReq)
+	}
+	if err := checkGrantableScopes(ctx, old.Scopes); err != nil {
+		return nil, err
+	}
+
+	secret, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	replacement := &domain.APIKey{
+		Name:          old.Name,
+		Prefix:        prefix,
+		KeyHash:       auth.HashAPIKey(secret),
+		Scopes:        old.Scopes,
+		RotatedFromID: &old.ID,
+		CreatedBy:     principalSubject(ctx),
+	}
+	if old.ExpiresAt != nil {
+		t := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		replacement.ExpiresAt = &t
+	}
+
+	oldExpiresAt := now.Add(overlap)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(oldExpiresAt) {
+		oldExpiresAt = *old.ExpiresAt
+	}
+	if err := s.keyRepo.Rotate(ctx, old, replacement, oldExpiresAt); err != nil {
+		return nil, err
+	}
+	return &APIKeySecretResponse{APIKey: replacement, Secret: secret}, nil
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, id uint64) error {
+	if _, err := s.get(ctx, id); err != nil {
+		return err
+	}
+	return s.keyRepo.Revoke(ctx, id, time.Now())
+}
+
+func (s *APIKeyService) get(ctx context.Context, id uint64) (*domain.APIKey, error) {
+	key, err := s.keyRepo.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrAPIKeyNotFound, id)
+	}
+	return key, err
+}
+
+// checkGrantableScopes rejects unknown scopes and scopes the caller does not
+// hold itself, so a key cannot be used to mint a more powerful one.
+func checkGrantableScopes(ctx context.Context, scopes []string) error {
+	p := auth.FromContext(ctx)
+	for _, scope := range scopes {
+		if !auth.IsValidScope(scope) {
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyReq, scope)
+		}
+		if !p.Can(scope) {
+			return fmt.Errorf("%w: cannot grant scope %q", ErrInvalidAPIKeyReq, scope)
+		}
+	}
+	return nil
+}
+
+func principalSubject(ctx context.Context) string {
+	if p := auth.FromContext(ctx); p != nil {
+		return p.Subject
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"gorm.io/gorm"
+)
+
+// rotatableAPIKeys serves keys by ID and records creates and rotations.
+type rotatableAPIKeys struct {
+	repository.APIKeyRepository
+	keys         map[uint64]domain.APIKey
+	created      []domain.APIKey
+	replacement  *domain.APIKey
+	oldExpiresAt time.Time
+}
+
+func (r *rotatableAPIKeys) GetByID(_ context.Context, id uint64) (*domain.APIKey, error) {
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &key, nil
+}
+
+func (r *rotatableAPIKeys) Create(_ context.Context, key *domain.APIKey) error {
+	r.created = append(r.created, *key)
+	return nil
+}
+
+func (r *rotatableAPIKeys) Rotate(_ context.Context, _ *domain.APIKey, replacement *domain.APIKey, oldExpiresAt time.Time) error {
+	r.replacement = replacement
+	r.oldExpiresAt = oldExpiresAt
+	return nil
+}
+
+func TestCreateAPIKeyScopes(t *testing.T) {
+	limited := &auth.Principal{Subject: "api-key:1", Roles: []string{auth.RoleAdmin}, Scopes: []string{auth.ScopeAPIKeysManage, auth.ScopeApplicationsRead}}
+	shared := &auth.Principal{Subject: "api-key", Roles: []string{auth.RoleAdmin}}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		scopes    []string
+		wantErr   bool
+	}{
+		{"scope the caller holds", limited, []string{auth.ScopeApplicationsRead}, false},
+		{"scope the caller lacks", limited, []string{auth.ScopeApplicationsRead, auth.ScopeApplicationsWrite}, true},
+		{"unknown scope", limited, []string{"applications:own"}, true},
+		{"unlimited caller", shared, []string{auth.ScopeApplicationsWrite, auth.ScopeAPIKeysManage}, false},
+		{"unlimited caller, unknown scope", shared, []string{"everything"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &rotatableAPIKeys{}
+			s := NewAPIKeyService(repo)
+			ctx := auth.WithPrincipal(context.Background(), tt.principal)
+
+			resp, err := s.Create(ctx, CreateAPIKeyRequest{Name: "ci", Scopes: tt.scopes})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAPIKeyReq) || len(repo.created) != 0 {
+					t.Fatalf("Create() error = %v, created %d, want ErrInvalidAPIKeyReq", err, len(repo.created))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.APIKey.KeyHash != auth.HashAPIKey(resp.Secret) || resp.APIKey.CreatedBy != tt.principal.Subject {
+				t.Errorf("created %+v", resp.APIKey)
+			}
+		})
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	now := time.Now()
+	created := now.Add(-24 * time.Hour)
+	soon := now.Add(time.Hour)
+	inMonth := created.Add(30 * 24 * time.Hour)
+	past := now.Add(-time.Minute)
+	keys := map[uint64]domain.APIKey{
+		1: {ID: 1, Name: "ci", Scopes: []string{auth.ScopeApplicationsRead}, CreatedAt: created},
+		2: {ID: 2, Name: "ci", Scopes: []string{auth.ScopeApplicationsRead}, CreatedAt: created, ExpiresAt: &soon},
+		3: {ID: 3, Name: "ci", Scopes: []string{auth.ScopeApplicationsRead}, CreatedAt: created, ExpiresAt: &inMonth},
+		4: {ID: 4, Name: "ci", Scopes: []string{auth.ScopeApplicationsRead}, CreatedAt: created, RevokedAt: &past},
+		5: {ID: 5, Name: "ci", Scopes: []string{auth.ScopeApplicationsRead}, CreatedAt: created, ExpiresAt: &past},
+	}
+	tests := []struct {
+		name        string
+		id          uint64
+		overlap     string
+		wantErr     error
+		wantOld     time.Time
+		wantNewLife time.Duration
+	}{
+		{"default overlap", 1, "", nil, now.Add(defaultRotationOverlap), 0},
+		{"custom overlap", 1, "2h", nil, now.Add(2 * time.Hour), 0},
+		{"immediate", 1, "0s", nil, now, 0},
+		// The old key never lives longer than it would have.
+		{"overlap past expiry", 2, "24h", nil, soon, time.Hour + 24*time.Hour},
+		{"replacement keeps the lifetime", 3, "1h", nil, now.Add(time.Hour), 30 * 24 * time.Hour},
+		{"negative overlap", 1, "-1h", ErrInvalidAPIKeyReq, time.Time{}, 0},
+		{"revoked key", 4, "", ErrInvalidAPIKeyReq, time.Time{}, 0},
+		{"expired key", 5, "", ErrInvalidAPIKeyReq, time.Time{}, 0},
+		{"missing key", 6, "", ErrAPIKeyNotFound, time.Time{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &rotatableAPIKeys{keys: keys}
+			s := NewAPIKeyService(repo)
+			principal := &auth.Principal{Subject: "api-key", Roles: []string{auth.RoleAdmin}}
+			ctx := auth.WithPrincipal(context.Background(), principal)
+
+			resp, err := s.Rotate(ctx, tt.id, RotateAPIKeyRequest{Overlap: tt.overlap})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rotate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if repo.replacement != nil {
+					t.Error("rotated after an error")
+				}
+				return
+			}
+			if d := repo.oldExpiresAt.Sub(tt.wantOld); d < -time.Second || d > time.Second {
+				t.Errorf("old key expires at %v, want %v", repo.oldExpiresAt, tt.wantOld)
+			}
+			replacement := resp.APIKey
+			if replacement.RotatedFromID == nil || *replacement.RotatedFromID != tt.id || replacement.Name != "ci" {
+				t.Errorf("replacement = %+v", replacement)
+			}
+			switch {
+			case tt.wantNewLife == 0 && replacement.ExpiresAt != nil:
+				t.Errorf("replacement expires at %v, want no expiry", replacement.ExpiresAt)
+			case tt.wantNewLife != 0:
+				if replacement.ExpiresAt == nil {
+					t.Fatal("replacement does not expire")
+				}
+				if d := replacement.ExpiresAt.Sub(now.Add(tt.wantNewLife)); d < -time.Second || d > time.Second {
+					t.Errorf("replacement expires at %v, want %v", replacement.ExpiresAt, now.Add(tt.wantNewLife))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/storage"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/Naomejoy/app-service/pkg/config"
+	"github.com/gabriel-vasile/mimetype"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDocumentNotFound = errors.New("document not found")
+	ErrFileTypeNotFound = errors.New("file type not found")
+)
+
+// UploadDocumentInput describes an uploaded file.
+type UploadDocumentInput struct {
+	FileTypeID uint64
+	FileName   string
+	// ContentType is the type declared by the client. It is only checked
+	// against the content; the stored type is sniffed from the content.
+	ContentType string
+	Size        int64
+	Content     io.Reader
+	// UserID lets staff upload on behalf of another user.
+	UserID uint64
+	// Checksum is the hex SHA-256 the content must have. Optional.
+	Checksum string
+	// ReplacesID makes the upload a new version of that document. The file
+	// type may then be left out.
+	ReplacesID uint64
+	// ValidUntil is the last day the document is valid. Optional.
+	ValidUntil *time.Time
+}
+
+type ApplicationDocumentService struct {
+	docRepo  repository.ApplicationDocumentRepository
+	fileRepo repository.ApplicationFileTypeRepository
+	appRepo  repository.ApplicationRepository
+	blobRepo repository.DocumentBlobRepository
+	blobs    storage.BlobStore
+	limits   UploadLimits
+	scans    *ScanWorker
+	tenants  map[string]config.TenantConfig
+	authz    *Authorizer
+}
+
+func NewApplicationDocumentService(docRepo repository.ApplicationDocumentRepository, fileRepo repository.ApplicationFileTypeRepository, appRepo repository.ApplicationRepository, blobRepo repository.DocumentBlobRepository, blobs storage.BlobStore, limits UploadLimits, scans *ScanWorker, tenants map[string]config.TenantConfig, authz *Authorizer) *ApplicationDocumentService {
+	return &ApplicationDocumentService{docRepo: docRepo, fileRepo: fileRepo, appRepo: appRepo, blobRepo: blobRepo, blobs: blobs, limits: limits, scans: scans, tenants: tenants, authz: authz}
+}
+
+// Upload stores the content in the blob store and records the document
+// against one of the file types declared on the application. The content
+// is checked against the rules of the file type's document type and the
+// storage quotas before anything is recorded, is stored only once per
+// tenant however often it is uploaded, and the document stays quarantined
+// until the scan worker finds it clean. A replacement becomes the document's current
+// version; earlier versions are kept.
+func (s *ApplicationDocumentService) Upload(ctx context.Context, appID uint64, in UploadDocumentInput) (*domain.ApplicationDocument, error) {
+	request := map[string]interface{}{
+		"fileTypeId":  int64(in.FileTypeID),
+		"fileName":    in.FileName,
+		"contentType": in.ContentType,
+		"size":        in.Size,
+		"replacesId":  int64(in.ReplacesID),
+	}
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionDocumentAdd, request)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := actingUserID(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	previous, fileTypeID, err := s.replacement(ctx, appID, in.ReplacesID, in.FileTypeID)
+	if err != nil {
+		return nil, err
+	}
+	fileType, err := s.fileRepo.GetByID(ctx, appID, fileTypeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrFileTypeNotFound, fileTypeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize := s.limits.maxSizeFor(fileType.DocumentType)
+	if maxSize > 0 && in.Size > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d allowed", ErrDocumentTooLarge, in.Size, maxSize)
+	}
+	if err := checkExtension(fileType.DocumentType, in.FileName); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx, app, in.Size); err != nil {
+		return nil, err
+	}
+
+	content := bufio.NewReaderSize(in.Content, sniffLen)
+	// A short or failing read leaves less to sniff; the error surfaces
+	// again when the content is stored.
+	head, _ := content.Peek(sniffLen)
+	detected := mimetype.Detect(head)
+	if err := checkContentType(fileType.DocumentType, detected, in.ContentType, in.FileName); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = content
+	if maxSize > 0 {
+		body = &limitedReader{r: content, n: maxSize}
+	}
+	hash := sha256.New()
+	body = io.TeeReader(body, hash)
+
+	// The content is spooled to a temporary file: its checksum decides
+	// whether it needs storing at all, and archives are unpacked before they
+	// are accepted.
+	spool, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	spooled, err := io.Copy(spool, body)
+	if err != nil {
+		return nil, err
+	}
+	if spooled != in.Size {
+		return nil, fmt.Errorf("failed to store document: expected %d bytes, got %d", in.Size, spooled)
+	}
+	if isArchive(detected) {
+		if err := s.limits.checkArchive(detected, spool, spooled); err != nil {
+			return nil, err
+		}
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if in.Checksum != "" && !strings.EqualFold(in.Checksum, checksum) {
+		return nil, fmt.Errorf("%w: content has SHA-256 %s", ErrChecksumMismatch, checksum)
+	}
+	key, err := s.storeContent(ctx, checksum, spool, spooled, detected.String())
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &domain.ApplicationDocument{
+		ApplicationID: appID,
+		FileTypeID:    fileType.ID,
+		FileName:      in.FileName,
+		ContentType:   detected.String(),
+		Size:          in.Size,
+		Checksum:      checksum,
+		StorageKey:    key,
+		UploadedBy:    userID,
+		ScanStatus:    domain.ScanPending,
+		ReviewStatus:  domain.ReviewPending,
+		PreviewStatus: previewStatusFor(detected.String()),
+		PreviewKeys:   map[string]string{},
+		ValidUntil:    in.ValidUntil,
+	}
+	if previous != nil {
+		err = s.docRepo.CreateVersion(ctx, doc, previous)
+	} else {
+		err = s.docRepo.Create(ctx, doc)
+	}
+	// Content stored for a document that could not be recorded is left
+	// for garbage collection, as another upload may be using it already.
+	if err != nil {
+		if errors.Is(err, repository.ErrNotCurrentVersion) {
+			return nil, fmt.Errorf("%w: document %d was replaced", ErrVersionConflict, previous.ID)
+		}
+		return nil, err
+	}
+	s.scans.Notify()
+	doc.FileType = fileType
+	return doc, nil
+}
+
+func (s *ApplicationDocumentService) List(ctx context.Context, appID uint64) ([]domain.ApplicationDocument, error) {
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionApplicationRead, nil); err != nil {
+		return nil, err
+	}
+	return s.docRepo.ListByApplication(ctx, appID)
+}
+
+// Open returns the document and its content. The caller closes the content.
+// Documents not scanned clean cannot be opened.
+func (s *ApplicationDocumentService) Open(ctx context.Context, appID, docID uint64) (*domain.ApplicationDocument, io.ReadSeekCloser, error) {
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionApplicationRead, nil); err != nil {
+		return nil, nil, err
+	}
+	doc, err := s.getDocument(ctx, appID, docID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkScanned(doc); err != nil {
+		return nil, nil, err
+	}
+	content, err := s.openContent(ctx, doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, content, nil
+}
+
+func (s *ApplicationDocumentService) openContent(ctx context.Context, doc *domain.ApplicationDocument) (io.ReadSeekCloser, error) {
+	content, err := s.blobs.Get(ctx, doc.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: content of document %d is missing", ErrDocumentNotFound, doc.ID)
+	}
+	return content, err
+}
+
+// Delete deletes the document docID belongs to, with all its versions. Their
+// content is released for garbage collection; their previews are deleted.
+// Documents of applications on legal hold cannot be deleted.
+func (s *ApplicationDocumentService) Delete(ctx context.Context, appID, docID uint64) error {
+	request := map[string]interface{}{"documentId": int64(docID)}
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionDocumentDelete, request)
+	if err != nil {
+		return err
+	}
+	if app.LegalHold {
+		return fmt.Errorf("%w: %d", ErrLegalHold, appID)
+	}
+	doc, err := s.getDocument(ctx, appID, docID)
+	if err != nil {
+		return err
+	}
+	versions, err := s.docRepo.ListVersions(ctx, appID, doc.LineageID)
+	if err != nil {
+		return err
+	}
+	// Reviewed versions are part of the application's record; only staff
+	// may remove them.
+	for _, version := range versions {
+		if version.ReviewStatus != domain.ReviewPending && !auth.FromContext(ctx).Can(auth.ScopeDocumentsDelete) {
+			return fmt.Errorf("%w: deleting a reviewed document requires the %s permission", ErrForbidden, auth.ScopeDocumentsDelete)
+		}
+	}
+	if err := s.docRepo.DeleteLineage(ctx, appID, doc.LineageID); err != nil {
+		return err
+	}
+	var keys []string
+	for _, version := range versions {
+		for _, key := range version.PreviewKeys {
+			keys = append(keys, key)
+		}
+	}
+	s.removeBlobs(ctx, keys)
+	return nil
+}
+
+func (s *ApplicationDocumentService) getDocument(ctx context.Context, appID, docID uint64) (*domain.ApplicationDocument, error) {
+	doc, err := s.docRepo.GetByID(ctx, appID, docID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, docID)
+	}
+	return doc, err
+}
+
+// removeBlobs deletes blobs whose rows are gone. A failure only leaves an
+// unreferenced blob behind, so it is logged rather than returned.
+func (s *ApplicationDocumentService) removeBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %q: %v", key, err)
+		}
+	}
+}
+
+// newStorageKey returns a fresh blob key below the application's prefix.
+func newStorageKey(ctx context.Context, appID uint64) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tenant.FromContext(ctx) + "/" + strconv.FormatUint(appID, 10) + "/" + hex.EncodeToString(b), nil
+}
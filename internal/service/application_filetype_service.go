@@ -1,30 +1,85 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Naomejoy/app-service/internal/policy"
 	"github.com/Naomejoy/app-service/internal/repository"
+	"gorm.io/gorm"
 
 	"github.com/Naomejoy/app-service/domain"
 )
 
+// ErrFileTypeInUse is returned when deleting a file type that still has
+// documents.
+var ErrFileTypeInUse = errors.New("file type has documents")
+
+// ErrFileTypeExists is returned when an application already declares the
+// document type.
+var ErrFileTypeExists = errors.New("application already has this file type")
+
 type ApplicationFileTypeService struct {
 	fileRepo repository.ApplicationFileTypeRepository
+	typeRepo repository.DocumentTypeRepository
+	docRepo  repository.ApplicationDocumentRepository
+	appRepo  repository.ApplicationRepository
+	authz    *Authorizer
 }
 
-func NewApplicationFileTypeService(fileRepo repository.ApplicationFileTypeRepository) *ApplicationFileTypeService {
-	return &ApplicationFileTypeService{fileRepo: fileRepo}
+func NewApplicationFileTypeService(fileRepo repository.ApplicationFileTypeRepository, typeRepo repository.DocumentTypeRepository, docRepo repository.ApplicationDocumentRepository, appRepo repository.ApplicationRepository, authz *Authorizer) *ApplicationFileTypeService {
+	return &ApplicationFileTypeService{fileRepo: fileRepo, typeRepo: typeRepo, docRepo: docRepo, appRepo: appRepo, authz: authz}
 }
 
-func (s *ApplicationFileTypeService) Add(appID uint64, fileTypeName string) error {
-	return s.fileRepo.Add(&domain.ApplicationUploadedFileType{
-		ApplicationID: appID,
-		FileTypeName:  fileTypeName,
+// Add declares that the application takes documents of a catalog document
+// type.
+func (s *ApplicationFileTypeService) Add(ctx context.Context, appID uint64, req AddFileTypeRequest) error {
+	docType, err := s.typeRepo.GetByID(ctx, req.DocumentTypeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %d", ErrDocumentTypeNotFound, req.DocumentTypeID)
+	}
+	if err != nil {
+		return err
+	}
+	request := map[string]interface{}{"documentTypeId": int64(docType.ID), "fileTypeName": docType.Key}
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionFileTypeAdd, request); err != nil {
+		return err
+	}
+	declared, err := s.fileRepo.ListByApplication(ctx, appID)
+	if err != nil {
+		return err
+	}
+	for _, fileType := range declared {
+		if fileType.DocumentTypeID == docType.ID {
+			return fmt.Errorf("%w: %q", ErrFileTypeExists, docType.Key)
+		}
+	}
+	return s.fileRepo.Add(ctx, &domain.ApplicationUploadedFileType{
+		ApplicationID:  appID,
+		DocumentTypeID: docType.ID,
+		FileTypeName:   docType.Key,
 	})
 }
 
-func (s *ApplicationFileTypeService) Delete(fileTypeID uint64) error {
-	return s.fileRepo.Delete(fileTypeID)
+func (s *ApplicationFileTypeService) Delete(ctx context.Context, appID, fileTypeID uint64) error {
+	request := map[string]interface{}{"fileTypeId": int64(fileTypeID)}
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionFileTypeDelete, request); err != nil {
+		return err
+	}
+	count, err := s.docRepo.CountByFileType(ctx, fileTypeID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: delete its %d document(s) first", ErrFileTypeInUse, count)
+	}
+	return s.fileRepo.Delete(ctx, appID, fileTypeID)
 }
 
-func (s *ApplicationFileTypeService) List(appID uint64) ([]domain.ApplicationUploadedFileType, error) {
-	return s.fileRepo.ListByApplication(appID)
+func (s *ApplicationFileTypeService) List(ctx context.Context, appID uint64) ([]domain.ApplicationUploadedFileType, error) {
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionApplicationRead, nil); err != nil {
+		return nil, err
+	}
+	return s.fileRepo.ListByApplication(ctx, appID)
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+)
+
+const (
+	MergeStrategyTarget  = "target"
+	MergeStrategyNewest  = "newest"
+	MergeStrategyOldest  = "oldest"
+	MergeStrategyLongest = "longest"
+)
+
+var ErrInvalidMerge = errors.New("invalid merge request")
+
+// mergeFields lists the scalar fields a merge resolves. Code is unique per
+// application, so the survivor always keeps its own.
+var mergeFields = map[string]func(app *domain.Application) *string{
+	"name":        func(app *domain.Application) *string { return &app.Name },
+	"description": func(app *domain.Application) *string { return &app.Description },
+}
+
+// Merge absorbs the source applications into the application targetID.
+func (s *ApplicationService) Merge(ctx context.Context, targetID uint64, req MergeApplicationsRequest) (*domain.Application, error) {
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = MergeStrategyTarget
+	}
+	if !validMergeStrategy(strategy) {
+		return nil, fmt.Errorf("%w: unknown strategy %q", ErrInvalidMerge, strategy)
+	}
+	for field, fieldStrategy := range req.Fields {
+		if _, ok := mergeFields[field]; !ok {
+			return nil, fmt.Errorf("%w: field %q cannot be merged", ErrInvalidMerge, field)
+		}
+		if !validMergeStrategy(fieldStrategy) {
+			return nil, fmt.Errorf("%w: unknown strategy %q for field %q", ErrInvalidMerge, fieldStrategy, field)
+		}
+	}
+
+	request := map[string]interface{}{"sourceIds": req.SourceIDs, "strategy": strategy}
+	target, err := s.loadForMerge(ctx, targetID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[uint64]bool{targetID: true}
+	var sourceIDs []uint64
+	var sources []*domain.Application
+	for _, id := range req.SourceIDs {
+		if seen[id] {
+			if id == targetID {
+				return nil, fmt.Errorf("%w: an application cannot be merged into itself", ErrInvalidMerge)
+			}
+			continue
+		}
+		seen[id] = true
+
+		source, err := s.loadForMerge(ctx, id, request)
+		if err != nil {
+			return nil, err
+		}
+		if source.UserID != target.UserID {
+			return nil, fmt.Errorf("%w: application %d belongs to a different user", ErrInvalidMerge, id)
+		}
+		sourceIDs = append(sourceIDs, id)
+		sources = append(sources, source)
+	}
+
+	for field, value := range mergeFields {
+		fieldStrategy := strategy
+		if override, ok := req.Fields[field]; ok {
+			fieldStrategy = override
+		}
+		*value(target) = resolveMergeField(fieldStrategy, target, sources, value)
+	}
+	target.PossibleDuplicate = false
+
+	if err := s.appRepo.Merge(ctx, target, sourceIDs); err != nil {
+		if errors.Is(err, repository.ErrAlreadyMerged) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMerge, err)
+		}
+		if errors.Is(err, repository.ErrLegalHold) {
+			return nil, fmt.Errorf("%w: an application being merged was put on hold", ErrLegalHold)
+		}
+		return nil, err
+	}
+	return s.appRepo.GetByID(ctx, targetID)
+}
+
+// loadForMerge loads an application taking part in a merge. The policies are
+// checked for the target and every source. Applications on legal hold
+// cannot take part.
+func (s *ApplicationService) loadForMerge(ctx context.Context, id uint64, request map[string]interface{}) (*domain.Application, error) {
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, id, policy.ActionApplicationMerge, request)
+	if err != nil {
+		return nil, err
+	}
+	if app.MergedIntoID != nil {
+		return nil, fmt.Errorf("%w: application %d was already merged into %d", ErrInvalidMerge, id, *app.MergedIntoID)
+	}
+	if app.LegalHold {
+		return nil, fmt.Errorf("%w: %d", ErrLegalHold, id)
+	}
+	return app, nil
+}
+
+func validMergeStrategy(strategy string) bool {
+	switch strategy {
+	case MergeStrategyTarget, MergeStrategyNewest, MergeStrategyOldest, MergeStrategyLongest:
+		return true
+	}
+	return false
+}
+
+// resolveMergeField picks the value of one field among the survivor and the
+// sources. Empty values never win over non-empty ones.
+func resolveMergeField(strategy string, target *domain.Application, sources []*domain.Application, value func(*domain.Application) *string) string {
+	chosen := target
+	for _, candidate := range sources {
+		current, next := *value(chosen), *value(candidate)
+		if next == "" {
+			continue
+		}
+		if current == "" {
+			chosen = candidate
+			continue
+		}
+		switch strategy {
+		case MergeStrategyNewest:
+			if candidate.UpdatedAt.After(chosen.UpdatedAt) {
+				chosen = candidate
+			}
+		case MergeStrategyOldest:
+			if candidate.CreatedAt.Before(chosen.CreatedAt) {
+				chosen = candidate
+			}
+		case MergeStrategyLongest:
+			if len(next) > len(current) {
+				chosen = candidate
+			}
+		}
+	}
+	return *value(chosen)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"gorm.io/gorm"
+)
+
+// mergeableApplications serves applications and records merges.
+type mergeableApplications struct {
+	repository.ApplicationRepository
+	apps   map[uint64]domain.Application
+	err    error
+	merged []uint64
+}
+
+func (r *mergeableApplications) FindByID(_ context.Context, id uint64) (*domain.Application, error) {
+	app, ok := r.apps[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &app, nil
+}
+
+func (r *mergeableApplications) GetByID(ctx context.Context, id uint64) (*domain.Application, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *mergeableApplications) Merge(_ context.Context, target *domain.Application, sourceIDs []uint64) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.apps[target.ID] = *target
+	r.merged = append(r.merged, sourceIDs...)
+	return nil
+}
+
+func TestResolveMergeField(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	target := &domain.Application{ID: 1, Name: "Visa", Description: "", CreatedAt: day.AddDate(0, 0, -1), UpdatedAt: day}
+	older := &domain.Application{ID: 2, Name: "Visa application", Description: "old", CreatedAt: day.AddDate(0, 0, -5), UpdatedAt: day.AddDate(0, 0, -2)}
+	newer := &domain.Application{ID: 3, Name: "Visa 2026", Description: "new and longer", CreatedAt: day, UpdatedAt: day.AddDate(0, 0, 1)}
+	sources := []*domain.Application{older, newer}
+	name := mergeFields["name"]
+	description := mergeFields["description"]
+
+	tests := []struct {
+		strategy string
+		value    func(*domain.Application) *string
+		want     string
+	}{
+		{MergeStrategyTarget, name, "Visa"},
+		{MergeStrategyNewest, name, "Visa 2026"},
+		{MergeStrategyOldest, name, "Visa application"},
+		{MergeStrategyLongest, name, "Visa application"},
+		// The target's description is empty, so a source always fills it.
+		{MergeStrategyTarget, description, "old"},
+		{MergeStrategyNewest, description, "new and longer"},
+		{MergeStrategyOldest, description, "old"},
+		{MergeStrategyLongest, description, "new and longer"},
+	}
+	for _, tt := range tests {
+		if got := resolveMergeField(tt.strategy, target, sources, tt.value); got != tt.want {
+			t.Errorf("resolveMergeField(%s) = %q, want %q", tt.strategy, got, tt.want)
+		}
+	}
+	// Empty sources never replace a value.
+	empty := &domain.Application{ID: 4, UpdatedAt: day.AddDate(1, 0, 0)}
+	if got := resolveMergeField(MergeStrategyNewest, target, []*domain.Application{empty}, name); got != "Visa" {
+		t.Errorf("resolveMergeField() with an empty source = %q, want %q", got, "Visa")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	merged := uint64(9)
+	tests := []struct {
+		name     string
+		req      MergeApplicationsRequest
+		wantErr  error
+		wantName string
+		wantDesc string
+	}{
+		{"default strategy keeps the target", MergeApplicationsRequest{SourceIDs: []uint64{2}}, nil, "Visa", "target"},
+		{"strategy applies to every field", MergeApplicationsRequest{SourceIDs: []uint64{2}, Strategy: MergeStrategyNewest}, nil, "Visa renewal", "source"},
+		{"field override", MergeApplicationsRequest{SourceIDs: []uint64{2}, Strategy: MergeStrategyNewest, Fields: map[string]string{"name": MergeStrategyTarget}}, nil, "Visa", "source"},
+		{"unknown strategy", MergeApplicationsRequest{SourceIDs: []uint64{2}, Strategy: "best"}, ErrInvalidMerge, "", ""},
+		{"unknown field strategy", MergeApplicationsRequest{SourceIDs: []uint64{2}, Fields: map[string]string{"name": "best"}}, ErrInvalidMerge, "", ""},
+		{"unknown field", MergeApplicationsRequest{SourceIDs: []uint64{2}, Fields: map[string]string{"code": MergeStrategyNewest}}, ErrInvalidMerge, "", ""},
+		{"into itself", MergeApplicationsRequest{SourceIDs: []uint64{1}}, ErrInvalidMerge, "", ""},
+		{"other user", MergeApplicationsRequest{SourceIDs: []uint64{3}}, ErrInvalidMerge, "", ""},
+		{"already merged", MergeApplicationsRequest{SourceIDs: []uint64{4}}, ErrInvalidMerge, "", ""},
+		{"missing source", MergeApplicationsRequest{SourceIDs: []uint64{5}}, ErrApplicationNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mergeableApplications{apps: map[uint64]domain.Application{
+				1: {ID: 1, UserID: 7, Name: "Visa", Description: "target", UpdatedAt: day},
+				2: {ID: 2, UserID: 7, Name: "Visa renewal", Description: "source", UpdatedAt: day.AddDate(0, 0, 1)},
+				3: {ID: 3, UserID: 8, Name: "Grant"},
+				4: {ID: 4, UserID: 7, Name: "Visa", MergedIntoID: &merged},
+			}}
+			s := NewApplicationService(repo, DuplicateConfig{}, nil, nil, nil, nil)
+
+			app, err := s.Merge(context.Background(), 1, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(repo.merged) != 0 {
+					t.Errorf("merged %v after an error", repo.merged)
+				}
+				return
+			}
+			if app.Name != tt.wantName || app.Description != tt.wantDesc {
+				t.Errorf("merged into name %q, description %q, want %q, %q", app.Name, app.Description, tt.wantName, tt.wantDesc)
+			}
+			if len(repo.merged) != 1 || repo.merged[0] != 2 {
+				t.Errorf("merged %v, want [2]", repo.merged)
+			}
+		})
+	}
+}
+
+func TestMergeLegalHold(t *testing.T) {
+	tests := []struct {
+		name     string
+		held     uint64
+		repoErr  error
+		wantErr  error
+		wantMove bool
+	}{
+		{"no hold", 0, nil, nil, true},
+		{"held target", 1, nil, ErrLegalHold, false},
+		{"held source", 2, nil, ErrLegalHold, false},
+		{"held while merging", 0, repository.ErrLegalHold, ErrLegalHold, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mergeableApplications{
+				apps: map[uint64]domain.Application{
+					1: {ID: 1, UserID: 7, Name: "target"},
+					2: {ID: 2, UserID: 7, Name: "source"},
+				},
+				err: tt.repoErr,
+			}
+			if tt.held != 0 {
+				app := repo.apps[tt.held]
+				app.LegalHold = true
+				repo.apps[tt.held] = app
+			}
+			s := NewApplicationService(repo, DuplicateConfig{}, nil, nil, nil, nil)
+
+			_, err := s.Merge(context.Background(), 1, MergeApplicationsRequest{SourceIDs: []uint64{2}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if moved := len(repo.merged) > 0; moved != tt.wantMove {
+				t.Errorf("merged %v, want a merge %v", repo.merged, tt.wantMove)
+			}
+		})
+	}
+}
@@ -1,48 +1,214 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math"
+	"time"
 
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/policy"
 	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/pkg/config"
+	"gorm.io/gorm"
 
 	"github.com/Naomejoy/app-service/domain"
 )
 
+var ErrApplicationNotFound = errors.New("application not found")
+
+const (
+	DuplicatePolicyOff    = "off"
+	DuplicatePolicyReject = "reject"
+	DuplicatePolicyFlag   = "flag"
+)
+
+// DuplicateConfig controls how Create reacts to applications that look like
+// one the same user submitted recently.
+type DuplicateConfig struct {
+	Policy     string
+	Similarity float64
+	Window     time.Duration
+}
+
+// DuplicateError is returned by Create under the reject policy.
+type DuplicateError struct {
+	Candidates []domain.Application
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("application looks like a duplicate of %d existing application(s)", len(e.Candidates))
+}
+
 type ApplicationService struct {
-	appRepo repository.ApplicationRepository
+	appRepo   repository.ApplicationRepository
+	duplicate DuplicateConfig
+	tenants   map[string]config.TenantConfig
+	authz     *Authorizer
+	docRepo   repository.ApplicationDocumentRepository
+	documents *ApplicationDocumentService
 }
 
-func NewApplicationService(appRepo repository.ApplicationRepository) *ApplicationService {
+func NewApplicationService(appRepo repository.ApplicationRepository, duplicate DuplicateConfig, tenants map[string]config.TenantConfig, authz *Authorizer, docRepo repository.ApplicationDocumentRepository, documents *ApplicationDocumentService) *ApplicationService {
 	return &ApplicationService{
-		appRepo: appRepo,
+		appRepo:   appRepo,
+		duplicate: duplicate,
+		tenants:   tenants,
+		authz:     authz,
+		docRepo:   docRepo,
+		documents: documents,
+	}
+}
+
+func (s *ApplicationService) Create(ctx context.Context, app *domain.Application) error {
+	if app.Name == "" {
+		return errors.New("name is required")
+	}
+	userID, err := actingUserID(ctx, app.UserID)
+	if err != nil {
+		return err
+	}
+	app.UserID = userID
+	if err := validateCategory(tenantConfig(s.tenants, ctx), app.Category); err != nil {
+		return err
+	}
+	if app.Code == "" {
+		code, err := generateCode(tenantConfig(s.tenants, ctx).Prefix())
+		if err != nil {
+			return err
+		}
+		app.Code = code
+	}
+
+	candidates, err := s.findDuplicates(ctx, app)
+	if err != nil {
+		return err
+	}
+	if len(candidates) > 0 {
+		switch s.duplicate.Policy {
+		case DuplicatePolicyReject:
+			return &DuplicateError{Candidates: candidates}
+		case DuplicatePolicyFlag:
+			app.PossibleDuplicate = true
+		}
 	}
+
+	return s.appRepo.Create(ctx, app)
 }
 
-func (s *ApplicationService) Create(app *domain.Application) error {
-	if app.Name == "" || app.Code == "" {
-		return errors.New("name and code are required")
+func (s *ApplicationService) findDuplicates(ctx context.Context, app *domain.Application) ([]domain.Application, error) {
+	if s.duplicate.Policy == "" || s.duplicate.Policy == DuplicatePolicyOff {
+		return nil, nil
 	}
-	return s.appRepo.Create(app)
+	since := time.Now().Add(-s.duplicate.Window)
+	return s.appRepo.FindSimilar(ctx, app.UserID, app.Name, since, s.duplicate.Similarity)
 }
 
-func (s *ApplicationService) GetByID(id uint64) (*domain.Application, error) {
-	return s.appRepo.GetByID(id)
+func (s *ApplicationService) GetByID(ctx context.Context, id uint64) (*domain.Application, error) {
+	app, err := s.appRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess(ctx, app) {
+		return nil, ErrApplicationNotFound
+	}
+	if err := s.authz.authorize(ctx, policy.ActionApplicationRead, app, nil); err != nil {
+		return nil, err
+	}
+	return app, nil
 }
 
-func (s *ApplicationService) Update(app *domain.Application) error {
-	if app.ID == 0 {
-		return errors.New("invalid application ID")
+// Update applies the non-empty fields of req to the application. The
+// policies see the application as stored and the requested changes.
+func (s *ApplicationService) Update(ctx context.Context, id uint64, req UpdateApplicationRequest) (*domain.Application, error) {
+	request := map[string]interface{}{}
+	if req.Name != "" {
+		request["name"] = req.Name
+	}
+	if req.Code != "" {
+		request["code"] = req.Code
+	}
+	if req.Description != "" {
+		request["description"] = req.Description
+	}
+	if req.Category != "" {
+		if err := validateCategory(tenantConfig(s.tenants, ctx), req.Category); err != nil {
+			return nil, err
+		}
+		request["category"] = req.Category
+	}
+	if req.AssigneeID != nil {
+		if auth.FromContext(ctx).IsOwnerRestricted() {
+			return nil, fmt.Errorf("%w: applicants cannot assign applications", ErrForbidden)
+		}
+		request["assigneeId"] = int64(*req.AssigneeID)
+	}
+
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, id, policy.ActionApplicationUpdate, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		app.Name = req.Name
+	}
+	if req.Code != "" {
+		app.Code = req.Code
+	}
+	if req.Description != "" {
+		app.Description = req.Description
+	}
+	if req.Category != "" {
+		app.Category = req.Category
+	}
+	if req.AssigneeID != nil {
+		// 0 clears the assignment.
+		app.AssigneeID = req.AssigneeID
+		if *req.AssigneeID == 0 {
+			app.AssigneeID = nil
+		}
+	}
+
+	if err := s.appRepo.Update(ctx, app); err != nil {
+		return nil, err
 	}
-	return s.appRepo.Update(app)
+	return s.appRepo.GetByID(ctx, id)
 }
 
-func (s *ApplicationService) Delete(id uint64) error {
-	return s.appRepo.Delete(id)
+func (s *ApplicationService) Delete(ctx context.Context, id uint64) error {
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, id, policy.ActionApplicationDelete, nil); err != nil {
+		return err
+	}
+	// The document rows go with the application and release their content;
+	// the previews and upload parts only it uses are removed once the delete
+	// succeeded.
+	keys, err := s.docRepo.StorageKeysByApplication(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.appRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %d", ErrApplicationNotFound, id)
+		}
+		if errors.Is(err, repository.ErrLegalHold) {
+			return fmt.Errorf("%w: %d", ErrLegalHold, id)
+		}
+		return err
+	}
+	s.documents.removeBlobs(ctx, keys)
+	return nil
 }
 
-func (s *ApplicationService) List(params repository.ApplicationListParams) (*ListResponse, error) {
-	apps, total, err := s.appRepo.List(params)
+func (s *ApplicationService) List(ctx context.Context, params repository.ApplicationListParams) (*ListResponse, error) {
+	owner, err := ownerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner != 0 {
+		params.UserID = owner
+	}
+	apps, total, err := s.appRepo.List(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+)
+
+// similarApplications answers FindSimilar from a list, the way the
+// trigram query selects from the applications table, and records creates.
+type similarApplications struct {
+	repository.ApplicationRepository
+	apps    []domain.Application
+	created []domain.Application
+}
+
+func (r *similarApplications) FindSimilar(_ context.Context, userID uint64, name string, since time.Time, threshold float64) ([]domain.Application, error) {
+	var similar []domain.Application
+	for _, app := range r.apps {
+		if app.UserID == userID && !app.CreatedAt.Before(since) && app.Name == name && app.MergedIntoID == nil {
+			similar = append(similar, app)
+		}
+	}
+	return similar, nil
+}
+
+func (r *similarApplications) Create(_ context.Context, app *domain.Application) error {
+	r.created = append(r.created, *app)
+	return nil
+}
+
+func TestCreateDuplicatePolicy(t *testing.T) {
+	now := time.Now()
+	merged := uint64(1)
+	existing := []domain.Application{
+		{ID: 1, UserID: 7, Name: "Visa", Code: "V1", CreatedAt: now.Add(-time.Hour)},
+		{ID: 2, UserID: 7, Name: "Permit", Code: "P1", CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: 3, UserID: 8, Name: "Grant", Code: "G1", CreatedAt: now.Add(-time.Hour)},
+		{ID: 4, UserID: 7, Name: "Loan", Code: "L0", CreatedAt: now.Add(-time.Hour), MergedIntoID: &merged},
+	}
+	tests := []struct {
+		name       string
+		policy     string
+		app        domain.Application
+		wantErr    bool
+		wantFlag   bool
+		candidates int
+	}{
+		{"off ignores duplicates", DuplicatePolicyOff, domain.Application{UserID: 7, Name: "Visa", Code: "V2"}, false, false, 0},
+		{"unset ignores duplicates", "", domain.Application{UserID: 7, Name: "Visa", Code: "V2"}, false, false, 0},
+		{"flag marks duplicates", DuplicatePolicyFlag, domain.Application{UserID: 7, Name: "Visa", Code: "V2"}, false, true, 0},
+		{"flag leaves others", DuplicatePolicyFlag, domain.Application{UserID: 7, Name: "Bond", Code: "B1"}, false, false, 0},
+		{"reject refuses duplicates", DuplicatePolicyReject, domain.Application{UserID: 7, Name: "Visa", Code: "V2"}, true, false, 1},
+		{"reject leaves others", DuplicatePolicyReject, domain.Application{UserID: 7, Name: "Bond", Code: "B1"}, false, false, 0},
+		// Applications of other users and outside the window do not count.
+		{"other user", DuplicatePolicyReject, domain.Application{UserID: 7, Name: "Grant", Code: "G2"}, false, false, 0},
+		{"outside window", DuplicatePolicyReject, domain.Application{UserID: 7, Name: "Permit", Code: "P2"}, false, false, 0},
+		{"merged application", DuplicatePolicyReject, domain.Application{UserID: 7, Name: "Loan", Code: "L1"}, false, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &similarApplications{apps: existing}
+			s := NewApplicationService(repo, DuplicateConfig{Policy: tt.policy, Similarity: 0.6, Window: 24 * time.Hour}, nil, nil, nil, nil)
+
+			app := tt.app
+			err := s.Create(context.Background(), &app)
+			var dup *DuplicateError
+			if tt.wantErr {
+				if !errors.As(err, &dup) || len(dup.Candidates) != tt.candidates {
+					t.Fatalf("Create() error = %v, want a duplicate of %d application(s)", err, tt.candidates)
+				}
+				if len(repo.created) != 0 {
+					t.Error("rejected application was created")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(repo.created) != 1 || repo.created[0].PossibleDuplicate != tt.wantFlag {
+				t.Errorf("created %+v, want possibleDuplicate %v", repo.created, tt.wantFlag)
+			}
+		})
+	}
+}
@@ -1,31 +1,92 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/policy"
 	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/pkg/config"
 
 	"math"
 
 	"github.com/Naomejoy/app-service/domain"
 )
 
+// ErrInvalidTransition is returned when a status is not allowed by the
+// tenant's workflow.
+var ErrInvalidTransition = errors.New("status transition not allowed")
+
 type ApplicationStatusService struct {
 	statusRepo repository.ApplicationStatusRepository
+	appRepo    repository.ApplicationRepository
+	tenants    map[string]config.TenantConfig
+	authz      *Authorizer
+	documents  *ApplicationDocumentService
 }
 
-func NewApplicationStatusService(statusRepo repository.ApplicationStatusRepository) *ApplicationStatusService {
-	return &ApplicationStatusService{statusRepo: statusRepo}
+func NewApplicationStatusService(statusRepo repository.ApplicationStatusRepository, appRepo repository.ApplicationRepository, tenants map[string]config.TenantConfig, authz *Authorizer, documents *ApplicationDocumentService) *ApplicationStatusService {
+	return &ApplicationStatusService{statusRepo: statusRepo, appRepo: appRepo, tenants: tenants, authz: authz, documents: documents}
 }
 
-func (s *ApplicationStatusService) Add(appID, userID uint64, status string) error {
-	return s.statusRepo.Add(&domain.ApplicationStatus{
+func (s *ApplicationStatusService) Add(ctx context.Context, appID, userID uint64, status string) error {
+	request := map[string]interface{}{"status": status}
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionStatusAdd, request)
+	if err != nil {
+		return err
+	}
+	userID, err = actingUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	workflow := tenantConfig(s.tenants, ctx).Workflow
+	if workflow.IsTerminal(status) && !auth.FromContext(ctx).Can(auth.ScopeStatusesTerminal) {
+		return fmt.Errorf("%w: moving an application to %q requires the %s permission", ErrForbidden, status, auth.ScopeStatusesTerminal)
+	}
+	if workflow.Defined() {
+		latest, err := s.statusRepo.Latest(ctx, appID)
+		if err != nil {
+			return err
+		}
+		from := ""
+		if latest != nil {
+			from = latest.Status
+		}
+		if !workflow.Allows(from, status) {
+			if from == "" {
+				return fmt.Errorf("%w: %q is not an initial status", ErrInvalidTransition, status)
+			}
+			return fmt.Errorf("%w: from %q to %q", ErrInvalidTransition, from, status)
+		}
+	}
+	// Submitting needs every required document to be uploaded and not
+	// rejected.
+	if workflow.IsSubmit(status) {
+		checklist, err := s.documents.checklist(ctx, app)
+		if err != nil {
+			return err
+		}
+		if !checklist.Submittable {
+			return &IncompleteChecklistError{Checklist: checklist}
+		}
+	}
+
+	return s.statusRepo.Add(ctx, &domain.ApplicationStatus{
 		ApplicationID: appID,
 		UserID:        userID,
 		Status:        status,
 	})
 }
 
-func (s *ApplicationStatusService) List(appID uint64, page, pageSize int) (*ListResponse, error) {
-	statuses, total, err := s.statusRepo.ListByApplication(appID, page, pageSize)
+func (s *ApplicationStatusService) List(ctx context.Context, appID uint64, page, pageSize int) (*ListResponse, error) {
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionApplicationRead, nil); err != nil {
+		return nil, err
+	}
+
+	statuses, total, err := s.statusRepo.ListByApplication(ctx, appID, page, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/pkg/config"
+)
+
+var ErrUnknownCategory = errors.New("unknown application category")
+
+// ChecklistItem reports how one document requirement is met. Only the
+// current version of each document is counted.
+type ChecklistItem struct {
+	// FileType is the document type key, like "passport", even where the
+	// tenant's checklist still names the display name "Passport".
+	FileType    string `json:"fileType"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Min         int    `json:"min"`
+	Max         int    `json:"max,omitempty"`
+	// Count is the number of accepted documents.
+	Count    int `json:"count"`
+	Pending  int `json:"pending"`
+	Rejected int `json:"rejected"`
+	// Satisfied reports whether enough documents were accepted.
+	Satisfied bool `json:"satisfied"`
+	// Provided reports whether enough documents were accepted or await
+	// review, which is what submission needs.
+	Provided bool `json:"provided"`
+	// Problem explains why the item is not satisfied.
+	Problem string `json:"problem,omitempty"`
+}
+
+// Checklist compares an application's documents with the requirements of
+// its category.
+type Checklist struct {
+	ApplicationID uint64 `json:"applicationId"`
+	Category      string `json:"category"`
+	Complete      bool   `json:"complete"`
+	// Submittable reports whether every item is provided.
+	Submittable bool            `json:"submittable"`
+	Items       []ChecklistItem `json:"items"`
+}
+
+// IncompleteChecklistError is returned when an application is submitted
+// while documents of its checklist are missing or rejected.
+type IncompleteChecklistError struct {
+	Checklist *Checklist
+}
+
+func (e *IncompleteChecklistError) Error() string {
+	var problems []string
+	for _, item := range e.Checklist.Items {
+		if !item.Provided {
+			problems = append(problems, item.Problem)
+		}
+	}
+	return "application cannot be submitted: " + strings.Join(problems, "; ")
+}
+
+// Checklist reports which of the documents required by the application's
+// category were accepted, await review or are missing.
+func (s *ApplicationDocumentService) Checklist(ctx context.Context, appID uint64) (*Checklist, error) {
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionApplicationRead, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.checklist(ctx, app)
+}
+
+func (s *ApplicationDocumentService) checklist(ctx context.Context, app *domain.Application) (*Checklist, error) {
+	docs, err := s.docRepo.ListByApplication(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+	category := tenantConfig(s.tenants, ctx).Categories[app.Category]
+	return buildChecklist(app, category, docs), nil
+}
+
+func buildChecklist(app *domain.Application, category config.Category, docs []domain.ApplicationDocument) *Checklist {
+	type counts struct{ accepted, pending, rejected int }
+	byType := map[string]*counts{}
+	for _, doc := range docs {
+		if doc.FileType == nil {
+			continue
+		}
+		c := byType[doc.FileType.FileTypeName]
+		if c == nil {
+			c = &counts{}
+			byType[doc.FileType.FileTypeName] = c
+		}
+		switch {
+		case doc.ReviewStatus == domain.ReviewRejected || doc.ScanStatus == domain.ScanInfected:
+			c.rejected++
+		case doc.ReviewStatus == domain.ReviewAccepted:
+			c.accepted++
+		default:
+			c.pending++
+		}
+	}
+
+	checklist := &Checklist{ApplicationID: app.ID, Category: app.Category, Complete: true, Submittable: true, Items: []ChecklistItem{}}
+	for _, req := range category.Documents {
+		c := byType[req.FileType]
+		if c == nil {
+			c = &counts{}
+		}
+		item := ChecklistItem{
+			FileType:    req.FileType,
+			Description: req.Description,
+			Required:    req.Required,
+			Min:         req.MinCount(),
+			Max:         req.Max,
+			Count:       c.accepted,
+			Pending:     c.pending,
+			Rejected:    c.rejected,
+			Satisfied:   true,
+			Provided:    true,
+		}
+		provided := item.Count + item.Pending
+		switch {
+		case provided < item.Min:
+			item.Satisfied, item.Provided = false, false
+			item.Problem = fmt.Sprintf("%s: %d of at least %d document(s) uploaded", req.FileType, provided, item.Min)
+			if item.Rejected > 0 {
+				item.Problem += fmt.Sprintf(", %d rejected", item.Rejected)
+			}
+		case item.Max > 0 && provided > item.Max:
+			item.Satisfied, item.Provided = false, false
+			item.Problem = fmt.Sprintf("%s: %d document(s) uploaded, at most %d allowed", req.FileType, provided, item.Max)
+		case item.Count < item.Min:
+			item.Satisfied = false
+			item.Problem = fmt.Sprintf("%s: %d of at least %d document(s) accepted, %d awaiting review", req.FileType, item.Count, item.Min, item.Pending)
+		}
+		if !item.Satisfied {
+			checklist.Complete = false
+		}
+		if !item.Provided {
+			checklist.Submittable = false
+		}
+		checklist.Items = append(checklist.Items, item)
+	}
+	return checklist
+}
+
+// validateCategory accepts an empty category and the categories the tenant
+// declares.
+func validateCategory(t config.TenantConfig, category string) error {
+	if category == "" {
+		return nil
+	}
+	if _, ok := t.Categories[category]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownCategory, category)
+	}
+	return nil
+}
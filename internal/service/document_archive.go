@@ -0,0 +1,209 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/storage"
+)
+
+// ManifestName is the name of the manifest inside an archive.
+const ManifestName = "manifest.json"
+
+// Archive is a ZIP archive of the current documents of one or more
+// applications, built while it is written. Documents go into a folder per
+// file type, below a folder per application when there are several, and
+// ManifestName lists what was included and what was skipped.
+type Archive struct {
+	blobs storage.BlobStore
+	apps  []archiveApplication
+}
+
+type archiveApplication struct {
+	app  *domain.Application
+	docs []domain.ApplicationDocument
+}
+
+type archiveManifest struct {
+	GeneratedAt  time.Time             `json:"generatedAt"`
+	Applications []manifestApplication `json:"applications"`
+}
+
+type manifestApplication struct {
+	ID       uint64 `json:"id"`
+	Name     string `json:"name"`
+	Code     string `json:"code,omitempty"`
+	Category string `json:"category,omitempty"`
+	// Folder is where the application's documents are, when the archive
+	// holds several applications.
+	Folder    string             `json:"folder,omitempty"`
+	Documents []manifestDocument `json:"documents"`
+	Skipped   []manifestSkipped  `json:"skipped,omitempty"`
+}
+
+type manifestDocument struct {
+	ID           uint64    `json:"id"`
+	LineageID    uint64    `json:"lineageId"`
+	Version      int       `json:"version"`
+	Path         string    `json:"path"`
+	FileType     string    `json:"fileType"`
+	FileName     string    `json:"fileName"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	UploadedBy   uint64    `json:"uploadedBy"`
+	ReviewStatus string    `json:"reviewStatus"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type manifestSkipped struct {
+	ID       uint64 `json:"id"`
+	FileName string `json:"fileName"`
+	Reason   string `json:"reason"`
+}
+
+// Write streams the archive to w and returns how many documents it holds.
+// Quarantined documents and those whose content is missing are skipped.
+func (a *Archive) Write(ctx context.Context, w io.Writer) (int, error) {
+	zw := zip.NewWriter(w)
+	manifest := archiveManifest{GeneratedAt: time.Now().UTC(), Applications: []manifestApplication{}}
+	used := map[string]bool{}
+	written := 0
+	for _, entry := range a.apps {
+		folder := ""
+		if len(a.apps) > 1 {
+			folder = uniquePath(used, archiveSegment(strconv.FormatUint(entry.app.ID, 10)+"-"+entry.app.Name))
+		}
+		m := manifestApplication{
+			ID:        entry.app.ID,
+			Name:      entry.app.Name,
+			Code:      entry.app.Code,
+			Category:  entry.app.Category,
+			Folder:    folder,
+			Documents: []manifestDocument{},
+		}
+		for i := range entry.docs {
+			if err := ctx.Err(); err != nil {
+				return written, err
+			}
+			doc := &entry.docs[i]
+			fileType := ""
+			if doc.FileType != nil {
+				fileType = doc.FileType.FileTypeName
+			}
+			name := uniquePath(used, path.Join(folder, archiveSegment(fileType), archiveSegment(doc.FileName)))
+			skipped, err := a.writeDocument(ctx, zw, doc, name)
+			if err != nil {
+				return written, err
+			}
+			if skipped != "" {
+				m.Skipped = append(m.Skipped, manifestSkipped{ID: doc.ID, FileName: doc.FileName, Reason: skipped})
+				delete(used, strings.ToLower(name))
+				continue
+			}
+			written++
+			m.Documents = append(m.Documents, manifestDocument{
+				ID:           doc.ID,
+				LineageID:    doc.LineageID,
+				Version:      doc.Version,
+				Path:         name,
+				FileType:     fileType,
+				FileName:     doc.FileName,
+				ContentType:  doc.ContentType,
+				Size:         doc.Size,
+				SHA256:       doc.Checksum,
+				UploadedBy:   doc.UploadedBy,
+				ReviewStatus: doc.ReviewStatus,
+				CreatedAt:    doc.CreatedAt,
+			})
+		}
+		manifest.Applications = append(manifest.Applications, m)
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: manifest.GeneratedAt})
+	if err != nil {
+		return written, err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return written, err
+	}
+	return written, zw.Close()
+}
+
+// writeDocument adds one document under name. It returns why the document
+// was skipped, if it was.
+func (a *Archive) writeDocument(ctx context.Context, zw *zip.Writer, doc *domain.ApplicationDocument, name string) (string, error) {
+	if err := checkScanned(doc); err != nil {
+		return err.Error(), nil
+	}
+	content, err := a.blobs.Get(ctx, doc.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "content is missing", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read document %d: %w", doc.ID, err)
+	}
+	defer content.Close()
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zipMethod(doc.ContentType), Modified: doc.CreatedAt})
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		return "", fmt.Errorf("failed to read document %d: %w", doc.ID, err)
+	}
+	return "", nil
+}
+
+// zipMethod stores content that is compressed already and deflates the
+// rest.
+func zipMethod(contentType string) uint16 {
+	switch {
+	case strings.HasPrefix(contentType, "image/") && contentType != "image/svg+xml" && contentType != "image/bmp" && contentType != "image/tiff",
+		strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "audio/"),
+		contentType == "application/zip",
+		contentType == "application/gzip",
+		contentType == "application/x-7z-compressed":
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// archiveSegment turns a name into a single, safe path segment.
+func archiveSegment(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// uniquePath numbers name like "scan (2).pdf" when an entry of the same
+// name, ignoring case, exists already, and reserves it.
+func uniquePath(used map[string]bool, name string) string {
+	candidate := name
+	ext := path.Ext(name)
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/signedurl"
+	"github.com/Naomejoy/app-service/internal/tenant"
+)
+
+var (
+	ErrInvalidLink = errors.New("invalid download link")
+	ErrLinkExpired = errors.New("download link expired")
+	ErrLinkUsed    = errors.New("download link was already used")
+)
+
+// Audit actions of download links.
+const (
+	AuditLinkCreated = "documents:link"
+	AuditDownload    = "documents:download"
+)
+
+// DownloadLinkConfig controls signed download links. Links point to
+// BaseURL + "/downloads/<document id>".
+type DownloadLinkConfig struct {
+	BaseURL    string
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// AccessInfo describes who fetched a document, for the audit trail.
+type AccessInfo struct {
+	IP        string
+	UserAgent string
+	Range     string
+}
+
+// DownloadLinkService issues signed links to documents and serves them.
+// The link itself is the credential, so every use is audited.
+type DownloadLinkService struct {
+	docs   *ApplicationDocumentService
+	audit  repository.AuditRepository
+	signer *signedurl.Signer
+	cfg    DownloadLinkConfig
+}
+
+func NewDownloadLinkService(docs *ApplicationDocumentService, audit repository.AuditRepository, signer *signedurl.Signer, cfg DownloadLinkConfig) *DownloadLinkService {
+	return &DownloadLinkService{docs: docs, audit: audit, signer: signer, cfg: cfg}
+}
+
+// CreateLink returns a signed link to a document the caller may read.
+func (s *DownloadLinkService) CreateLink(ctx context.Context, appID, docID uint64, req CreateDownloadLinkRequest) (*DownloadLinkResponse, error) {
+	if _, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, appID, policy.ActionApplicationRead, nil); err != nil {
+		return nil, err
+	}
+	doc, err := s.docs.getDocument(ctx, appID, docID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScanned(doc); err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(req.ExpiresIn) * time.Second
+	if ttl == 0 {
+		ttl = s.cfg.DefaultTTL
+	}
+	if s.cfg.MaxTTL > 0 && ttl > s.cfg.MaxTTL {
+		ttl = s.cfg.MaxTTL
+	}
+	link := signedurl.Link{
+		TenantID:      tenant.FromContext(ctx),
+		ApplicationID: appID,
+		DocumentID:    docID,
+		ExpiresAt:     time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if p := auth.FromContext(ctx); p != nil {
+		link.Subject = p.Subject
+	}
+	if req.SingleUse {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		link.Nonce = hex.EncodeToString(b)
+	}
+
+	query := url.Values{}
+	for k, v := range s.signer.Sign(link) {
+		query.Set(k, v)
+	}
+	err = s.audit.Record(ctx, &domain.AuditEvent{
+		Action:        AuditLinkCreated,
+		Actor:         link.Subject,
+		ApplicationID: &appID,
+		DocumentID:    &docID,
+		Details:       map[string]interface{}{"expiresAt": link.ExpiresAt, "singleUse": req.SingleUse},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DownloadLinkResponse{
+		URL:       s.cfg.BaseURL + "/downloads/" + strconv.FormatUint(docID, 10) + "?" + query.Encode(),
+		ExpiresAt: link.ExpiresAt,
+		SingleUse: req.SingleUse,
+	}, nil
+}
+
+// OpenLink verifies a link and returns the document and its content. The
+// caller closes the content. get reads the link's query parameters.
+func (s *DownloadLinkService) OpenLink(ctx context.Context, docID uint64, get func(string) string, access AccessInfo) (*domain.ApplicationDocument, io.ReadSeekCloser, error) {
+	link, err := s.signer.Verify(docID, get, time.Now())
+	if errors.Is(err, signedurl.ErrExpired) {
+		return nil, nil, ErrLinkExpired
+	}
+	if err != nil {
+		return nil, nil, ErrInvalidLink
+	}
+	ctx = tenant.WithID(ctx, link.TenantID)
+
+	doc, err := s.docs.getDocument(ctx, link.ApplicationID, docID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkScanned(doc); err != nil {
+		return nil, nil, err
+	}
+	if link.Nonce != "" {
+		fresh, err := s.audit.UseDownloadLink(ctx, link.Nonce, link.ExpiresAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !fresh {
+			return nil, nil, ErrLinkUsed
+		}
+	}
+
+	// Nothing is served that was not audited.
+	err = s.audit.Record(ctx, &domain.AuditEvent{
+		Action:        AuditDownload,
+		Actor:         "link:" + link.Subject,
+		ApplicationID: &link.ApplicationID,
+		DocumentID:    &docID,
+		IP:            access.IP,
+		UserAgent:     access.UserAgent,
+		Details:       map[string]interface{}{"range": access.Range, "singleUse": link.Nonce != ""},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to audit download: %w", err)
+	}
+	content, err := s.docs.openContent(ctx, doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, content, nil
+}
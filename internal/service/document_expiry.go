@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/Naomejoy/app-service/pkg/config"
+)
+
+// Audit actions recorded when the documents of an application become
+// expiring or expired.
+const (
+	AuditDocumentsExpiring = "documents:expiring"
+	AuditDocumentsExpired  = "documents:expired"
+)
+
+// systemActor is the audit actor of background checks.
+const systemActor = "system"
+
+// documentStateRank orders document states from good to bad.
+var documentStateRank = map[string]int{
+	"":                       0,
+	domain.DocumentsExpiring: 1,
+	domain.DocumentsExpired:  2,
+}
+
+// DocumentExpiryChecker flags applications whose current documents expired
+// or are about to, going by the documents' validUntil day.
+type DocumentExpiryChecker struct {
+	appRepo    repository.ApplicationRepository
+	statusRepo repository.ApplicationStatusRepository
+	audit      repository.AuditRepository
+	tenants    map[string]config.TenantConfig
+}
+
+func NewDocumentExpiryChecker(appRepo repository.ApplicationRepository, statusRepo repository.ApplicationStatusRepository, audit repository.AuditRepository, tenants map[string]config.TenantConfig) *DocumentExpiryChecker {
+	return &DocumentExpiryChecker{appRepo: appRepo, statusRepo: statusRepo, audit: audit, tenants: tenants}
+}
+
+// Check updates the document state of the applications of every tenant.
+// Applications whose documents became expiring or expired get an audit
+// event and, when the tenant configures one, a status.
+func (c *DocumentExpiryChecker) Check(ctx context.Context, now time.Time) error {
+	var errs []error
+	for id, cfg := range c.tenants {
+		if err := c.checkTenant(tenant.WithID(ctx, id), cfg, now); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %q: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *DocumentExpiryChecker) checkTenant(ctx context.Context, cfg config.TenantConfig, now time.Time) error {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	changes, err := c.appRepo.ListDocumentStateChanges(ctx, today, today.AddDate(0, 0, cfg.DocumentExpiry.Warning()))
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		// Only the instance that moves the application tells about it.
+		changed, err := c.appRepo.SetDocumentState(ctx, change.ApplicationID, change.Previous, change.State)
+		if err != nil {
+			return err
+		}
+		// Renewed documents clear the state quietly.
+		if !changed || documentStateRank[change.State] <= documentStateRank[change.Previous] {
+			continue
+		}
+		if err := c.notify(ctx, cfg, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify records that the application's documents became expiring or
+// expired, and appends the tenant's status for that if the workflow
+// allows it.
+func (c *DocumentExpiryChecker) notify(ctx context.Context, cfg config.TenantConfig, change repository.DocumentStateChange) error {
+	action, status := AuditDocumentsExpiring, cfg.DocumentExpiry.ExpiringStatus
+	if change.State == domain.DocumentsExpired {
+		action, status = AuditDocumentsExpired, cfg.DocumentExpiry.ExpiredStatus
+	}
+	appended, err := c.appendStatus(ctx, cfg.Workflow, change.ApplicationID, status)
+	if err != nil {
+		return err
+	}
+	details := map[string]interface{}{"previous": change.Previous}
+	if change.ValidUntil != nil {
+		details["validUntil"] = change.ValidUntil.Format("2006-01-02")
+	}
+	if appended {
+		details["status"] = status
+	}
+	return c.audit.Record(ctx, &domain.AuditEvent{
+		Action:        action,
+		Actor:         systemActor,
+		ApplicationID: &change.ApplicationID,
+		Details:       details,
+	})
+}
+
+// appendStatus adds status to the application unless it is empty, is the
+// current status already or the workflow does not allow it. It reports
+// whether the status was added.
+func (c *DocumentExpiryChecker) appendStatus(ctx context.Context, workflow config.Workflow, appID uint64, status string) (bool, error) {
+	if status == "" {
+		return false, nil
+	}
+	latest, err := c.statusRepo.Latest(ctx, appID)
+	if err != nil {
+		return false, err
+	}
+	from := ""
+	if latest != nil {
+		from = latest.Status
+	}
+	if from == status {
+		return false, nil
+	}
+	if !workflow.Allows(from, status) {
+		log.Printf("Not moving application %d (tenant %q) from %q to %q: not allowed by the workflow", appID, tenant.FromContext(ctx), from, status)
+		return false, nil
+	}
+	return true, c.statusRepo.Add(ctx, &domain.ApplicationStatus{ApplicationID: appID, Status: status})
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/pkg/config"
+)
+
+// documentStates holds the document state of applications the way the
+// applications table does, shared by the checkers of several instances.
+type documentStates struct {
+	repository.ApplicationRepository
+	states map[uint64]string
+	// changes are listed by every checker, as each lists before any moves.
+	changes []repository.DocumentStateChange
+}
+
+func (r *documentStates) ListDocumentStateChanges(context.Context, time.Time, time.Time) ([]repository.DocumentStateChange, error) {
+	return r.changes, nil
+}
+
+func (r *documentStates) SetDocumentState(_ context.Context, id uint64, previous, state string) (bool, error) {
+	if r.states[id] != previous {
+		return false, nil
+	}
+	r.states[id] = state
+	return true, nil
+}
+
+type recordedStatuses struct {
+	repository.ApplicationStatusRepository
+	added []domain.ApplicationStatus
+}
+
+func (r *recordedStatuses) Latest(context.Context, uint64) (*domain.ApplicationStatus, error) {
+	return &domain.ApplicationStatus{Status: "review"}, nil
+}
+
+func (r *recordedStatuses) Add(_ context.Context, status *domain.ApplicationStatus) error {
+	r.added = append(r.added, *status)
+	return nil
+}
+
+type recordedAudit struct {
+	repository.AuditRepository
+	events []domain.AuditEvent
+}
+
+func (r *recordedAudit) Record(_ context.Context, event *domain.AuditEvent) error {
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func TestDocumentExpiryCheckerNotifiesOnce(t *testing.T) {
+	apps := &documentStates{
+		states: map[uint64]string{1: "", 2: domain.DocumentsExpiring, 3: domain.DocumentsExpired},
+		changes: []repository.DocumentStateChange{
+			{ApplicationID: 1, Previous: "", State: domain.DocumentsExpiring},
+			{ApplicationID: 2, Previous: domain.DocumentsExpiring, State: domain.DocumentsExpired},
+			// Renewed documents.
+			{ApplicationID: 3, Previous: domain.DocumentsExpired, State: ""},
+		},
+	}
+	statuses := &recordedStatuses{}
+	audit := &recordedAudit{}
+	tenants := map[string]config.TenantConfig{config.DefaultTenantID: {
+		ID:             config.DefaultTenantID,
+		DocumentExpiry: config.DocumentExpiry{ExpiredStatus: "documents_expired"},
+	}}
+
+	// Two instances run the check at the same time.
+	for i := 0; i < 2; i++ {
+		checker := NewDocumentExpiryChecker(apps, statuses, audit, tenants)
+		if err := checker.Check(context.Background(), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[uint64]string{1: domain.DocumentsExpiring, 2: domain.DocumentsExpired, 3: ""}
+	for id, state := range want {
+		if apps.states[id] != state {
+			t.Errorf("application %d is %q, want %q", id, apps.states[id], state)
+		}
+	}
+	if len(audit.events) != 2 {
+		t.Fatalf("recorded %d audit events, want 2: %+v", len(audit.events), audit.events)
+	}
+	actions := map[uint64]string{}
+	for _, event := range audit.events {
+		actions[*event.ApplicationID] = event.Action
+	}
+	if actions[1] != AuditDocumentsExpiring || actions[2] != AuditDocumentsExpired {
+		t.Errorf("audit actions = %v", actions)
+	}
+	if len(statuses.added) != 1 || statuses.added[0].ApplicationID != 2 || statuses.added[0].Status != "documents_expired" {
+		t.Errorf("added statuses = %+v, want documents_expired on application 2 once", statuses.added)
+	}
+}
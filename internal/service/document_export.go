@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExportNotFound = errors.New("export not found")
+	ErrInvalidExport  = errors.New("invalid export")
+	// ErrExportNotReady is returned when downloading an export that is
+	// still running or failed.
+	ErrExportNotReady = errors.New("export is not ready")
+	ErrExportExpired  = errors.New("export expired")
+)
+
+// AuditExport is the audit action of archive downloads and export jobs.
+const AuditExport = "documents:export"
+
+// exportLease is how long a running job may take before another instance
+// assumes its worker died and runs it again.
+const exportLease = time.Hour
+
+// ExportConfig limits export jobs. A job covers at most MaxApplications
+// applications and its archive is kept for Expiry.
+type ExportConfig struct {
+	MaxApplications int
+	Expiry          time.Duration
+}
+
+// DocumentExportService packs documents into ZIP archives: streamed on the
+// fly for one application, or built by a background job for a filtered set
+// of applications.
+type DocumentExportService struct {
+	docs    *ApplicationDocumentService
+	jobRepo repository.ExportJobRepository
+	audit   repository.AuditRepository
+	cfg     ExportConfig
+	wake    chan struct{}
+}
+
+func NewDocumentExportService(docs *ApplicationDocumentService, jobRepo repository.ExportJobRepository, audit repository.AuditRepository, cfg ExportConfig) *DocumentExportService {
+	return &DocumentExportService{docs: docs, jobRepo: jobRepo, audit: audit, cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// ApplicationArchive prepares the archive of an application's current
+// documents. Nothing is read until the archive is written.
+func (s *DocumentExportService) ApplicationArchive(ctx context.Context, appID uint64) (*Archive, error) {
+	app, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, appID, policy.ActionApplicationRead, nil)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := s.docs.docRepo.ListByApplication(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.audit.Record(ctx, &domain.AuditEvent{
+		Action:        AuditExport,
+		Actor:         principalSubject(ctx),
+		ApplicationID: &appID,
+		Details:       map[string]interface{}{"documents": len(docs)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{blobs: s.docs.blobs, apps: []archiveApplication{{app: app, docs: docs}}}, nil
+}
+
+// CreateJob queues an export of the applications the request selects, of
+// those the caller may read. The selection is fixed now.
+func (s *DocumentExportService) CreateJob(ctx context.Context, req CreateExportRequest) (*domain.ExportJob, error) {
+	ids, err := s.selectApplications(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: no applications selected", ErrInvalidExport)
+	}
+	job := &domain.ExportJob{
+		RequestedBy:    principalSubject(ctx),
+		ApplicationIDs: ids,
+		Status:         domain.ExportPending,
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	err = s.audit.Record(ctx, &domain.AuditEvent{
+		Action:  AuditExport,
+		Actor:   job.RequestedBy,
+		Details: map[string]interface{}{"jobId": job.ID, "applicationIds": ids},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Notify()
+	return job, nil
+}
+
+// selectApplications returns the requested applications, which must all be
+// readable, or those matching the filter that are.
+func (s *DocumentExportService) selectApplications(ctx context.Context, req CreateExportRequest) ([]uint64, error) {
+	if len(req.ApplicationIDs) > 0 {
+		if len(req.ApplicationIDs) > s.cfg.MaxApplications {
+			return nil, fmt.Errorf("%w: at most %d applications per export", ErrInvalidExport, s.cfg.MaxApplications)
+		}
+		ids := make([]uint64, 0, len(req.ApplicationIDs))
+		seen := map[uint64]bool{}
+		for _, id := range req.ApplicationIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if _, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, id, policy.ActionApplicationRead, nil); err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+
+	params := repository.ApplicationListParams{
+		PageSize:   100,
+		Q:          req.Q,
+		AssigneeID: req.AssigneeID,
+		Category:   req.Category,
+		From:       req.From,
+		To:         req.To,
+		Order:      "asc",
+	}
+	owner, err := ownerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner != 0 {
+		params.UserID = owner
+	}
+	var ids []uint64
+	for params.Page = 1; ; params.Page++ {
+		apps, total, err := s.docs.appRepo.List(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if total > int64(s.cfg.MaxApplications) {
+			return nil, fmt.Errorf("%w: %d applications match, at most %d per export", ErrInvalidExport, total, s.cfg.MaxApplications)
+		}
+		for _, app := range apps {
+			_, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, app.ID, policy.ActionApplicationRead, nil)
+			if errors.Is(err, ErrForbidden) || errors.Is(err, ErrApplicationNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, app.ID)
+		}
+		if len(apps) < params.PageSize {
+			return ids, nil
+		}
+	}
+}
+
+// GetJob returns a job the caller requested.
+func (s *DocumentExportService) GetJob(ctx context.Context, id uint64) (*domain.ExportJob, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && job.RequestedBy != principalSubject(ctx)) {
+		return nil, fmt.Errorf("%w: %d", ErrExportNotFound, id)
+	}
+	return job, err
+}
+
+// OpenJob returns a finished job and its archive. The caller closes the
+// archive.
+func (s *DocumentExportService) OpenJob(ctx context.Context, id uint64) (*domain.ExportJob, io.ReadSeekCloser, error) {
+	job, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != domain.ExportDone {
+		return nil, nil, fmt.Errorf("%w: export %d is %s", ErrExportNotReady, id, job.Status)
+	}
+	if job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt) {
+		return nil, nil, fmt.Errorf("%w: %d", ErrExportExpired, id)
+	}
+	content, err := s.docs.blobs.Get(ctx, job.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.audit.Record(ctx, &domain.AuditEvent{
+		Action:  AuditExport,
+		Actor:   principalSubject(ctx),
+		Details: map[string]interface{}{"jobId": job.ID, "download": true},
+	})
+	if err != nil {
+		content.Close()
+		return nil, nil, err
+	}
+	return job, content, nil
+}
+
+// Notify makes the worker look for pending jobs now. It never blocks.
+func (s *DocumentExportService) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run builds pending export archives of every tenant until ctx is
+// cancelled.
+func (s *DocumentExportService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.runPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *DocumentExportService) runPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := s.jobRepo.Claim(ctx, time.Now().Add(-exportLease))
+		if err != nil {
+			log.Printf("Failed to claim export job: %v", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		s.runJob(ctx, job)
+	}
+}
+
+func (s *DocumentExportService) runJob(ctx context.Context, job *domain.ExportJob) {
+	ctx = tenant.WithID(ctx, job.TenantID)
+	if err := s.buildJob(ctx, job); err != nil {
+		log.Printf("Export job %d (tenant %q) failed: %v", job.ID, job.TenantID, err)
+		job.Status = domain.ExportFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = domain.ExportDone
+		expires := time.Now().Add(s.cfg.Expiry)
+		job.ExpiresAt = &expires
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+	if err := s.jobRepo.Finish(context.WithoutCancel(ctx), job); err != nil {
+		log.Printf("Failed to record export job %d: %v", job.ID, err)
+		if job.StorageKey != "" {
+			s.docs.removeBlobs(context.WithoutCancel(ctx), []string{job.StorageKey})
+		}
+	}
+}
+
+// buildJob writes the job's archive to a temporary file and stores it.
+// Applications deleted since the job was requested are left out.
+func (s *DocumentExportService) buildJob(ctx context.Context, job *domain.ExportJob) error {
+	archive := &Archive{blobs: s.docs.blobs}
+	for _, id := range job.ApplicationIDs {
+		app, err := s.docs.appRepo.FindByID(ctx, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		docs, err := s.docs.docRepo.ListByApplication(ctx, id)
+		if err != nil {
+			return err
+		}
+		archive.apps = append(archive.apps, archiveApplication{app: app, docs: docs})
+	}
+
+	spool, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	count, err := archive.Write(ctx, spool)
+	if err != nil {
+		return err
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := job.TenantID + "/exports/" + strconv.FormatUint(job.ID, 10) + ".zip"
+	if err := s.docs.blobs.Put(ctx, key, spool, size, "application/zip"); err != nil {
+		return fmt.Errorf("failed to store archive: %w", err)
+	}
+	job.StorageKey = key
+	job.DocumentCount = count
+	job.Size = size
+	return nil
+}
+
+// DeleteExpired removes expired export jobs of every tenant and their
+// archives.
+func (s *DocumentExportService) DeleteExpired(ctx context.Context, now time.Time) error {
+	for {
+		jobs, err := s.jobRepo.ListExpired(ctx, now, 100)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			if err := s.jobRepo.DeleteByID(ctx, job.ID); err != nil {
+				return err
+			}
+			if job.StorageKey != "" {
+				s.docs.removeBlobs(ctx, []string{job.StorageKey})
+			}
+		}
+		if len(jobs) < 100 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/storage"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPreviewSize = errors.New("invalid preview size")
+	// ErrPreviewNotReady is returned while the preview of a document is
+	// still being made.
+	ErrPreviewNotReady = errors.New("preview is not ready")
+	// ErrNoPreview is returned for documents that have no preview because
+	// their content is unsupported or could not be rendered.
+	ErrNoPreview = errors.New("document has no preview")
+)
+
+// DefaultPreviewSize is the size served when none is asked for.
+const DefaultPreviewSize = "small"
+
+const (
+	// previewBatchSize is how many pending documents one pass of the
+	// worker renders.
+	previewBatchSize = 20
+	// previewMaxPixels bounds the images the worker decodes, so a small
+	// file declaring a huge image cannot exhaust memory.
+	previewMaxPixels = 50_000_000
+	// previewMaxImageBytes bounds the decompressed size of a PDF image
+	// stream and of the image extracted from it.
+	previewMaxImageBytes = 4 * previewMaxPixels
+	// scanAspectTolerance is how far the aspect ratio of an image may be
+	// off the page's for the image to count as a scan of the page.
+	scanAspectTolerance = 0.03
+	previewQuality      = 80
+)
+
+func init() {
+	// pdfcpu would otherwise write its configuration to the user's home.
+	api.DisableConfigDir()
+}
+
+// errUnrenderable marks content the worker cannot make a preview of, so
+// retrying is pointless.
+var errUnrenderable = errors.New("content cannot be rendered")
+
+// errNoRenderer marks content the worker has no renderer for, like PDF
+// pages of text and vector graphics.
+var errNoRenderer = errors.New("content has no renderer")
+
+// previewStatusFor is the preview status a new document of the content type
+// starts with.
+func previewStatusFor(contentType string) string {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp", "application/pdf":
+		return domain.PreviewPending
+	}
+	return domain.PreviewUnsupported
+}
+
+// PreviewWorker renders JPEG previews of documents of every tenant once they
+// are scanned clean and stores them next to the original in the blob store.
+// Clean scans wake it up; otherwise it polls, which also retries attempts
+// that failed on the blob store. Instances may render the same document
+// concurrently, which only wastes work.
+type PreviewWorker struct {
+	docRepo repository.ApplicationDocumentRepository
+	blobs   storage.BlobStore
+	wake    chan struct{}
+}
+
+func NewPreviewWorker(docRepo repository.ApplicationDocumentRepository, blobs storage.BlobStore) *PreviewWorker {
+	return &PreviewWorker{docRepo: docRepo, blobs: blobs, wake: make(chan struct{}, 1)}
+}
+
+// Notify makes the worker look for pending documents now. It never blocks
+// and is a no-op on a nil worker.
+func (w *PreviewWorker) Notify() {
+	if w == nil {
+		return
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run renders pending previews until ctx is cancelled.
+func (w *PreviewWorker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.renderPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+func (w *PreviewWorker) renderPending(ctx context.Context) {
+	for {
+		docs, err := w.docRepo.ListPendingPreviews(ctx, previewBatchSize)
+		if err != nil {
+			log.Printf("Failed to list documents to preview: %v", err)
+			return
+		}
+		rendered := 0
+		for i := range docs {
+			if ctx.Err() != nil {
+				return
+			}
+			if w.renderDocument(ctx, &docs[i]) {
+				rendered++
+			}
+		}
+		// Stop when nothing is left or everything left keeps failing.
+		if len(docs) < previewBatchSize || rendered == 0 {
+			return
+		}
+	}
+}
+
+// renderDocument makes and records the previews of one document. It
+// reports whether the document is settled, with or without previews.
+func (w *PreviewWorker) renderDocument(ctx context.Context, doc *domain.ApplicationDocument) (settled bool) {
+	keys := map[string]string{}
+	// The decoders run on uploaded content and may panic on malformed
+	// files; such a document has no preview rather than a dead worker.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic previewing document %d: %v", doc.ID, r)
+			w.removePreviews(ctx, keys)
+			settled = w.recordPreview(ctx, doc.ID, domain.PreviewFailed, nil)
+		}
+	}()
+
+	err := w.previews(ctx, doc, keys)
+	switch {
+	case errors.Is(err, errNoRenderer):
+		log.Printf("No preview of document %d: %v", doc.ID, err)
+		return w.recordPreview(ctx, doc.ID, domain.PreviewUnsupported, nil)
+	case errors.Is(err, errUnrenderable):
+		log.Printf("No preview of document %d: %v", doc.ID, err)
+		return w.recordPreview(ctx, doc.ID, domain.PreviewFailed, nil)
+	case err != nil:
+		log.Printf("Failed to preview document %d: %v", doc.ID, err)
+		w.recordPreview(ctx, doc.ID, domain.PreviewPending, nil)
+		return false
+	}
+	if !w.recordPreview(ctx, doc.ID, domain.PreviewReady, keys) {
+		w.removePreviews(ctx, keys)
+		return false
+	}
+	return true
+}
+
+// recordPreview stores the preview status of a document and reports
+// whether that succeeded.
+func (w *PreviewWorker) recordPreview(ctx context.Context, docID uint64, status string, keys map[string]string) bool {
+	if err := w.docRepo.SetPreviewResult(ctx, docID, status, keys, time.Now()); err != nil {
+		log.Printf("Failed to record preview of document %d: %v", docID, err)
+		return false
+	}
+	return true
+}
+
+// previews renders the document in every size and stores the results,
+// adding their keys to keys. On error it deletes what it stored.
+func (w *PreviewWorker) previews(ctx context.Context, doc *domain.ApplicationDocument, keys map[string]string) error {
+	content, err := w.blobs.Get(ctx, doc.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	var img image.Image
+	if doc.ContentType == "application/pdf" {
+		img, err = firstPageImage(content)
+	} else {
+		img, err = decodeImage(content)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Render the largest size first and the smaller ones from it, which is
+	// much cheaper than scaling the original each time.
+	sizes := make([]string, 0, len(domain.PreviewSizes))
+	for size := range domain.PreviewSizes {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return domain.PreviewSizes[sizes[i]] > domain.PreviewSizes[sizes[j]] })
+	for _, size := range sizes {
+		img = scaleImage(img, domain.PreviewSizes[size])
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: previewQuality}); err != nil {
+			w.removePreviews(ctx, keys)
+			clear(keys)
+			return err
+		}
+		// Documents share content, but each has its own previews.
+		key := doc.StorageKey + "." + strconv.FormatUint(doc.ID, 10) + "-" + size + ".jpg"
+		if err := w.blobs.Put(ctx, key, &buf, int64(buf.Len()), "image/jpeg"); err != nil {
+			w.removePreviews(ctx, keys)
+			clear(keys)
+			return fmt.Errorf("failed to store preview: %w", err)
+		}
+		keys[size] = key
+	}
+	return nil
+}
+
+func (w *PreviewWorker) removePreviews(ctx context.Context, keys map[string]string) {
+	for _, key := range keys {
+		if err := w.blobs.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %q: %v", key, err)
+		}
+	}
+}
+
+// decodeImage decodes a JPEG, PNG, WebP or TIFF image of a bounded size.
+func decodeImage(r io.ReadSeeker) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnrenderable, err)
+	}
+	if cfg.Width*cfg.Height > previewMaxPixels {
+		return nil, fmt.Errorf("%w: image of %dx%d pixels is too large", errUnrenderable, cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnrenderable, err)
+	}
+	return img, nil
+}
+
+// firstPageImage returns a raster of the first page of a PDF. Scanned
+// documents have one: the image that fills the page. Other pages fall back
+// to the thumbnail their producer embedded, if any. Pages made of text and
+// vector graphics need a PDF renderer, for which there is no pure-Go
+// library, and have no preview.
+func firstPageImage(r io.ReadSeeker) (image.Image, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.EXTRACTIMAGES
+	conf.Limits.MaxDecodeBytes = previewMaxImageBytes
+	conf.Limits.MaxImageBytes = previewMaxImageBytes
+	conf.Limits.MaxImagePixels = previewMaxPixels
+	pdf, err := api.ReadValidateAndOptimize(r, conf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnrenderable, err)
+	}
+	dims, err := pdf.PageDims()
+	if err != nil || len(dims) == 0 {
+		return nil, fmt.Errorf("%w: no page size: %v", errUnrenderable, err)
+	}
+	// Stubs describe the images without decoding them.
+	stubs, err := pdfcpu.ExtractPageImages(pdf, 1, true)
+	if err != nil && len(stubs) == 0 {
+		return nil, fmt.Errorf("%w: %v", errUnrenderable, err)
+	}
+	stub, ok := pageRaster(stubs, dims[0])
+	if !ok {
+		return nil, fmt.Errorf("%w: first page is not a scan and has no thumbnail", errNoRenderer)
+	}
+
+	var sd *types.StreamDict
+	if stub.Thumb {
+		sd, _, err = pdf.DereferenceStreamDict(pdf.PageThumbs[1])
+	} else if obj := pdf.Optimize.ImageObjects[stub.ObjNr]; obj != nil {
+		sd = obj.ImageDict
+	}
+	if err != nil || sd == nil {
+		return nil, fmt.Errorf("%w: image object %d is missing: %v", errUnrenderable, stub.ObjNr, err)
+	}
+	// pdfcpu decodes images without the configured limit; a first pass
+	// with it keeps a stream inflating beyond any sane image out.
+	if err := sd.DecodeWithLimit(previewMaxImageBytes); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnrenderable, err)
+	}
+	extracted, err := pdfcpu.ExtractImage(pdf, sd, stub.Thumb, stub.Name, stub.ObjNr, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnrenderable, err)
+	}
+	b, err := io.ReadAll(io.LimitReader(extracted, previewMaxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > previewMaxImageBytes {
+		return nil, fmt.Errorf("%w: image of the first page is larger than %d bytes", errUnrenderable, previewMaxImageBytes)
+	}
+	return decodeImage(bytes.NewReader(b))
+}
+
+// pageRaster picks the image showing the whole page from the images of a
+// page: the largest one with the page's aspect ratio in either orientation,
+// which is what scanners produce, or else the page's thumbnail.
+func pageRaster(images map[int]model.Image, page types.Dim) (model.Image, bool) {
+	var scan, thumb model.Image
+	var found, hasThumb bool
+	for _, img := range images {
+		if img.Width <= 0 || img.Height <= 0 {
+			continue
+		}
+		if img.Thumb {
+			thumb, hasThumb = img, true
+			continue
+		}
+		if !fillsPage(img, page) {
+			continue
+		}
+		if !found || img.Width*img.Height > scan.Width*scan.Height ||
+			(img.Width*img.Height == scan.Width*scan.Height && img.ObjNr < scan.ObjNr) {
+			scan, found = img, true
+		}
+	}
+	if found {
+		return scan, true
+	}
+	return thumb, hasThumb
+}
+
+// fillsPage reports whether img has the aspect ratio of page, upright or
+// rotated by 90 degrees.
+func fillsPage(img model.Image, page types.Dim) bool {
+	if page.Width <= 0 || page.Height <= 0 {
+		return false
+	}
+	aspect := float64(img.Width) / float64(img.Height)
+	pageAspect := page.Width / page.Height
+	return math.Abs(aspect/pageAspect-1) <= scanAspectTolerance ||
+		math.Abs(aspect*pageAspect-1) <= scanAspectTolerance
+}
+
+// scaleImage fits img into a square of side pixels on a white background,
+// which transparent areas show through. Smaller images keep their size.
+func scaleImage(img image.Image, side int) image.Image {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width > side || height > side {
+		if width >= height {
+			width, height = side, max(1, height*side/width)
+		} else {
+			width, height = max(1, width*side/height), side
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+// OpenPreview returns a document and its preview in the given size. The
+// caller closes the preview.
+func (s *ApplicationDocumentService) OpenPreview(ctx context.Context, docID uint64, size string) (*domain.ApplicationDocument, io.ReadSeekCloser, error) {
+	if size == "" {
+		size = DefaultPreviewSize
+	}
+	if _, ok := domain.PreviewSizes[size]; !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidPreviewSize, size)
+	}
+	doc, err := s.docRepo.FindByID(ctx, docID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, docID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, doc.ApplicationID, policy.ActionApplicationRead, nil); err != nil {
+		return nil, nil, err
+	}
+	if err := checkScanned(doc); err != nil {
+		return nil, nil, err
+	}
+	switch doc.PreviewStatus {
+	case domain.PreviewReady:
+	case domain.PreviewPending:
+		return nil, nil, fmt.Errorf("%w: document %d", ErrPreviewNotReady, docID)
+	default:
+		return nil, nil, fmt.Errorf("%w: preview of document %d is %s", ErrNoPreview, docID, doc.PreviewStatus)
+	}
+	key, ok := doc.PreviewKeys[size]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: document %d has no %s preview", ErrNoPreview, docID, size)
+	}
+	content, err := s.blobs.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: preview of document %d is missing", ErrNoPreview, docID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, content, nil
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/storage"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withPNGSize rewrites the size a PNG declares in its header, leaving the
+// pixel data alone.
+func withPNGSize(data []byte, width, height uint32) []byte {
+	data = append([]byte{}, data...)
+	// Signature, chunk length and type come before IHDR's fields.
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+// imagePDF makes a one page PDF showing the image. A full page image sizes
+// the page; other positions place the image on an A4 page.
+func imagePDF(t *testing.T, img []byte, pos types.Anchor) []byte {
+	t.Helper()
+	imp := pdfcpu.DefaultImportConfig()
+	imp.Pos = pos
+	var buf bytes.Buffer
+	if err := api.ImportImages(nil, &buf, []io.Reader{bytes.NewReader(img)}, imp, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeImage(t *testing.T) {
+	valid := encodePNG(t, 40, 30)
+	tests := []struct {
+		name    string
+		content []byte
+		err     error
+	}{
+		{"png", valid, nil},
+		{"huge declared size", withPNGSize(valid, 10_000, 10_000), errUnrenderable},
+		{"truncated", valid[:len(valid)/2], errUnrenderable},
+		{"not an image", []byte("%PDF-1.7 definitely not a picture"), errUnrenderable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeImage(bytes.NewReader(tt.content))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("decodeImage() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && img.Bounds().Dx() != 40 {
+				t.Errorf("decoded image is %v", img.Bounds())
+			}
+		})
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		side          int
+		wantW, wantH  int
+	}{
+		{"landscape", 400, 200, 100, 100, 50},
+		{"portrait", 200, 400, 100, 50, 100},
+		{"square", 300, 300, 100, 100, 100},
+		{"smaller keeps its size", 60, 20, 100, 60, 20},
+		{"thin line", 1000, 1, 100, 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := scaleImage(image.NewRGBA(image.Rect(0, 0, tt.width, tt.height)), tt.side)
+			if img.Bounds().Dx() != tt.wantW || img.Bounds().Dy() != tt.wantH {
+				t.Errorf("scaleImage() = %v, want %dx%d", img.Bounds(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestPageRaster(t *testing.T) {
+	a4 := types.Dim{Width: 595, Height: 842}
+	scan := model.Image{ObjNr: 4, Width: 1240, Height: 1754}
+	smallScan := model.Image{ObjNr: 5, Width: 620, Height: 877}
+	rotated := model.Image{ObjNr: 6, Width: 1754, Height: 1240}
+	logo := model.Image{ObjNr: 7, Width: 300, Height: 60}
+	thumb := model.Image{ObjNr: 8, Width: 76, Height: 106, Thumb: true}
+	tests := []struct {
+		name   string
+		images []model.Image
+		want   int
+		found  bool
+	}{
+		{"scan", []model.Image{scan}, 4, true},
+		{"largest scan", []model.Image{smallScan, scan}, 4, true},
+		{"rotated scan", []model.Image{rotated}, 6, true},
+		{"scan before thumbnail", []model.Image{thumb, scan}, 4, true},
+		{"logo only", []model.Image{logo}, 0, false},
+		{"logo with thumbnail", []model.Image{logo, thumb}, 8, true},
+		{"no images", nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := map[int]model.Image{}
+			for _, img := range tt.images {
+				images[img.ObjNr] = img
+			}
+			got, found := pageRaster(images, a4)
+			if found != tt.found || (found && got.ObjNr != tt.want) {
+				t.Errorf("pageRaster() = obj %d, %v, want obj %d, %v", got.ObjNr, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestFirstPageImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       []byte
+		width, height int
+		err           error
+	}{
+		{"scanned page", imagePDF(t, encodePNG(t, 210, 297), types.Full), 210, 297, nil},
+		{"page with a logo", imagePDF(t, encodePNG(t, 300, 60), types.Center), 0, 0, errNoRenderer},
+		{"not a pdf", []byte("hello"), 0, 0, errUnrenderable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := firstPageImage(bytes.NewReader(tt.content))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("firstPageImage() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (img.Bounds().Dx() != tt.width || img.Bounds().Dy() != tt.height) {
+				t.Errorf("firstPageImage() = %v, want %dx%d", img.Bounds(), tt.width, tt.height)
+			}
+		})
+	}
+}
+
+// previewResults records the preview results the worker reports.
+type previewResults struct {
+	repository.ApplicationDocumentRepository
+	status string
+	keys   map[string]string
+}
+
+func (r *previewResults) SetPreviewResult(_ context.Context, _ uint64, status string, keys map[string]string, _ time.Time) error {
+	r.status, r.keys = status, keys
+	return nil
+}
+
+// panickingBlobs serves content whose reads panic, like a decoder tripping
+// over a malformed file.
+type panickingBlobs struct{ storage.BlobStore }
+
+func (panickingBlobs) Get(context.Context, string) (io.ReadSeekCloser, error) {
+	return panickingReader{}, nil
+}
+
+type panickingReader struct{}
+
+func (panickingReader) Read([]byte) (int, error)       { panic("malformed content") }
+func (panickingReader) Seek(int64, int) (int64, error) { return 0, nil }
+func (panickingReader) Close() error                   { return nil }
+
+func TestRenderDocumentPanic(t *testing.T) {
+	results := &previewResults{}
+	w := NewPreviewWorker(results, panickingBlobs{})
+	doc := &domain.ApplicationDocument{ID: 1, StorageKey: "blob", ContentType: "image/png"}
+
+	if !w.renderDocument(context.Background(), doc) {
+		t.Error("renderDocument() did not settle the document")
+	}
+	if results.status != domain.PreviewFailed {
+		t.Errorf("preview status = %q, want %q", results.status, domain.PreviewFailed)
+	}
+}
+
+func TestRenderDocument(t *testing.T) {
+	blobs, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := encodePNG(t, 64, 48)
+	if err := blobs.Put(context.Background(), "blob", bytes.NewReader(content), int64(len(content)), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	results := &previewResults{}
+	w := NewPreviewWorker(results, blobs)
+	doc := &domain.ApplicationDocument{ID: 7, StorageKey: "blob", ContentType: "image/png"}
+
+	if !w.renderDocument(context.Background(), doc) {
+		t.Fatal("renderDocument() did not settle the document")
+	}
+	if results.status != domain.PreviewReady || len(results.keys) != len(domain.PreviewSizes) {
+		t.Fatalf("preview result = %q, %v", results.status, results.keys)
+	}
+	for size, key := range results.keys {
+		if !strings.HasPrefix(key, "blob.7-") {
+			t.Errorf("%s preview stored under %q", size, key)
+		}
+		preview, err := blobs.Get(context.Background(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := decodeImage(preview)
+		preview.Close()
+		if err != nil {
+			t.Fatalf("%s preview: %v", size, err)
+		}
+		if side := domain.PreviewSizes[size]; img.Bounds().Dx() > side || img.Bounds().Dy() > side {
+			t.Errorf("%s preview is %v", size, img.Bounds())
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/scan"
+	"github.com/Naomejoy/app-service/internal/storage"
+)
+
+// ErrDocumentQuarantined is returned when downloading a document whose scan
+// is pending or found malware.
+var ErrDocumentQuarantined = errors.New("document is quarantined")
+
+// scanBatchSize is how many pending documents one pass of the worker scans.
+const scanBatchSize = 20
+
+// ScanWorker scans pending documents of every tenant in the background.
+// Uploads wake it up; otherwise it polls, which also retries failed scans.
+// Instances may scan the same document concurrently; the first verdict is
+// kept.
+type ScanWorker struct {
+	docRepo  repository.ApplicationDocumentRepository
+	blobs    storage.BlobStore
+	scanner  scan.Scanner
+	previews *PreviewWorker
+	wake     chan struct{}
+}
+
+// NewScanWorker creates the scan worker. Documents found clean are handed
+// to previews, which may be nil.
+func NewScanWorker(docRepo repository.ApplicationDocumentRepository, blobs storage.BlobStore, scanner scan.Scanner, previews *PreviewWorker) *ScanWorker {
+	return &ScanWorker{docRepo: docRepo, blobs: blobs, scanner: scanner, previews: previews, wake: make(chan struct{}, 1)}
+}
+
+// Notify makes the worker look for pending documents now. It never blocks
+// and is a no-op on a nil worker.
+func (w *ScanWorker) Notify() {
+	if w == nil {
+		return
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run scans pending documents until ctx is cancelled.
+func (w *ScanWorker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.scanPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+func (w *ScanWorker) scanPending(ctx context.Context) {
+	for {
+		docs, err := w.docRepo.ListPendingScans(ctx, scanBatchSize)
+		if err != nil {
+			log.Printf("Failed to list documents to scan: %v", err)
+			return
+		}
+		scanned := 0
+		for i := range docs {
+			if ctx.Err() != nil {
+				return
+			}
+			if w.scanDocument(ctx, &docs[i]) {
+				scanned++
+			}
+		}
+		// Stop when nothing is left or everything left keeps failing.
+		if len(docs) < scanBatchSize || scanned == 0 {
+			return
+		}
+	}
+}
+
+// scanDocument scans one document and records the outcome. It reports
+// whether a verdict was reached.
+func (w *ScanWorker) scanDocument(ctx context.Context, doc *domain.ApplicationDocument) bool {
+	status, signature, scanErr := w.verdict(ctx, doc)
+	if scanErr != nil {
+		log.Printf("Failed to scan document %d: %v", doc.ID, scanErr)
+		status, signature = domain.ScanPending, ""
+	}
+	updated, err := w.docRepo.SetScanResult(ctx, doc.ID, status, signature, time.Now())
+	if err != nil {
+		log.Printf("Failed to record scan of document %d: %v", doc.ID, err)
+		return false
+	}
+	// Otherwise another instance recorded a verdict first.
+	if updated && status == domain.ScanClean {
+		w.previews.Notify()
+	}
+	if updated && status == domain.ScanInfected {
+		log.Printf("Document %d of application %d (tenant %q) is infected: %s", doc.ID, doc.ApplicationID, doc.TenantID, signature)
+	}
+	return scanErr == nil
+}
+
+func (w *ScanWorker) verdict(ctx context.Context, doc *domain.ApplicationDocument) (string, string, error) {
+	content, err := w.blobs.Get(ctx, doc.StorageKey)
+	if err != nil {
+		return "", "", err
+	}
+	defer content.Close()
+	result, err := w.scanner.Scan(ctx, content)
+	if err != nil {
+		return "", "", err
+	}
+	if result.Infected {
+		return domain.ScanInfected, result.Signature, nil
+	}
+	return domain.ScanClean, "", nil
+}
+
+// Rescan quarantines the document again and queues it for scanning, e.g.
+// after the virus definitions were updated.
+func (s *ApplicationDocumentService) Rescan(ctx context.Context, appID, docID uint64) (*domain.ApplicationDocument, error) {
+	request := map[string]interface{}{"documentId": int64(docID)}
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionDocumentRescan, request); err != nil {
+		return nil, err
+	}
+	if _, err := s.getDocument(ctx, appID, docID); err != nil {
+		return nil, err
+	}
+	if err := s.docRepo.MarkForRescan(ctx, appID, docID); err != nil {
+		return nil, err
+	}
+	s.scans.Notify()
+	return s.getDocument(ctx, appID, docID)
+}
+
+// checkScanned lets only documents that were scanned clean out.
+func checkScanned(doc *domain.ApplicationDocument) error {
+	switch doc.ScanStatus {
+	case domain.ScanClean:
+		return nil
+	case domain.ScanInfected:
+		return fmt.Errorf("%w: malware found (%s)", ErrDocumentQuarantined, doc.ScanSignature)
+	default:
+		return fmt.Errorf("%w: scan is %s", ErrDocumentQuarantined, doc.ScanStatus)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/tenant"
+)
+
+// ErrQuotaExceeded is returned when an upload would take an application or
+// its owner over their storage quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// blobGCBatchSize is how many unreferenced blobs one pass deletes.
+const blobGCBatchSize = 100
+
+// StorageUsage reports the storage an application and its owner use,
+// counting every version of every document at its full size.
+type StorageUsage struct {
+	ApplicationID uint64     `json:"applicationId"`
+	Application   QuotaUsage `json:"application"`
+	UserID        uint64     `json:"userId"`
+	User          QuotaUsage `json:"user"`
+}
+
+// QuotaUsage is the storage used against one quota, in bytes. Limit is 0
+// when there is no quota.
+type QuotaUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// Usage reports the storage used by the application and by all
+// applications of its owner.
+func (s *ApplicationDocumentService) Usage(ctx context.Context, appID uint64) (*StorageUsage, error) {
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionApplicationRead, nil)
+	if err != nil {
+		return nil, err
+	}
+	appUsed, err := s.docRepo.StorageUsed(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	userUsed, err := s.docRepo.StorageUsedByOwner(ctx, app.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &StorageUsage{
+		ApplicationID: appID,
+		Application:   QuotaUsage{Used: appUsed, Limit: s.limits.ApplicationQuota},
+		UserID:        app.UserID,
+		User:          QuotaUsage{Used: userUsed, Limit: s.limits.UserQuota},
+	}, nil
+}
+
+// checkQuota rejects size more bytes for the application when they would
+// exceed its quota or its owner's. Concurrent uploads may overshoot a
+// quota by what they add together.
+func (s *ApplicationDocumentService) checkQuota(ctx context.Context, app *domain.Application, size int64) error {
+	if s.limits.ApplicationQuota > 0 {
+		used, err := s.docRepo.StorageUsed(ctx, app.ID)
+		if err != nil {
+			return err
+		}
+		if used+size > s.limits.ApplicationQuota {
+			return fmt.Errorf("%w: application %d uses %d of %d bytes", ErrQuotaExceeded, app.ID, used, s.limits.ApplicationQuota)
+		}
+	}
+	if s.limits.UserQuota > 0 {
+		used, err := s.docRepo.StorageUsedByOwner(ctx, app.UserID)
+		if err != nil {
+			return err
+		}
+		if used+size > s.limits.UserQuota {
+			return fmt.Errorf("%w: user %d uses %d of %d bytes", ErrQuotaExceeded, app.UserID, used, s.limits.UserQuota)
+		}
+	}
+	return nil
+}
+
+// storeContent stores content with the given checksum unless the tenant
+// has it already, and returns its blob key. The blob is kept from garbage
+// collection long enough to record a document for it.
+func (s *ApplicationDocumentService) storeContent(ctx context.Context, checksum string, content io.ReadSeeker, size int64, contentType string) (string, error) {
+	blob, err := s.blobRepo.Reserve(ctx, checksum, size, contentKey(ctx, checksum))
+	if err != nil {
+		return "", err
+	}
+	if blob.Stored {
+		return blob.StorageKey, nil
+	}
+	// Content being stored by a concurrent upload is stored again; the key
+	// is derived from the content, so both write the same bytes.
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := s.blobs.Put(ctx, blob.StorageKey, content, size, contentType); err != nil {
+		return "", fmt.Errorf("failed to store document: %w", err)
+	}
+	if err := s.blobRepo.MarkStored(ctx, blob.ID); err != nil {
+		return "", err
+	}
+	return blob.StorageKey, nil
+}
+
+// contentKey is the blob key of the tenant's content with the checksum.
+func contentKey(ctx context.Context, checksum string) string {
+	return tenant.FromContext(ctx) + "/sha256/" + checksum
+}
+
+// CollectGarbage deletes the blobs of every tenant that no document has
+// referenced since before grace ago.
+func (s *ApplicationDocumentService) CollectGarbage(ctx context.Context, now time.Time, grace time.Duration) error {
+	remove := func(key string) error {
+		err := s.blobs.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Failed to delete blob %q: %v", key, err)
+		}
+		return err
+	}
+	for {
+		collected, err := s.blobRepo.CollectGarbage(ctx, now.Add(-grace), blobGCBatchSize, remove)
+		if err != nil {
+			return err
+		}
+		if collected < blobGCBatchSize {
+			return nil
+		}
+	}
+}
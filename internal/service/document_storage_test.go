@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/tenant"
+)
+
+// memoryBlobRepo keeps document_blobs rows in memory. Documents are not
+// modelled; tests set reference counts directly.
+type memoryBlobRepo struct {
+	mu     sync.Mutex
+	rows   []*domain.DocumentBlob
+	passes int
+}
+
+func (r *memoryBlobRepo) Reserve(ctx context.Context, checksum string, size int64, key string) (*domain.DocumentBlob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenantID := tenant.FromContext(ctx)
+	now := time.Now()
+	for _, row := range r.rows {
+		if row.TenantID == tenantID && row.Checksum != nil && *row.Checksum == checksum {
+			if row.RefCount == 0 {
+				row.UnreferencedAt = &now
+			}
+			copied := *row
+			return &copied, nil
+		}
+	}
+	row := &domain.DocumentBlob{ID: uint64(len(r.rows) + 1), TenantID: tenantID, Checksum: &checksum, StorageKey: key, Size: size, UnreferencedAt: &now}
+	r.rows = append(r.rows, row)
+	copied := *row
+	return &copied, nil
+}
+
+func (r *memoryBlobRepo) MarkStored(_ context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, row := range r.rows {
+		if row.ID == id {
+			row.Stored = true
+		}
+	}
+	return nil
+}
+
+func (r *memoryBlobRepo) CollectGarbage(_ context.Context, before time.Time, limit int, remove func(key string) error) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.passes++
+	deleted := 0
+	kept := r.rows[:0]
+	for _, row := range r.rows {
+		if deleted < limit && row.RefCount == 0 && row.UnreferencedAt != nil && row.UnreferencedAt.Before(before) {
+			row.Stored = false
+			if remove(row.StorageKey) == nil {
+				deleted++
+				continue
+			}
+		}
+		kept = append(kept, row)
+	}
+	r.rows = kept
+	return deleted, nil
+}
+
+func TestStoreContentDeduplicates(t *testing.T) {
+	blobs := &memoryBlobs{blobs: map[string][]byte{}}
+	s := &ApplicationDocumentService{blobRepo: &memoryBlobRepo{}, blobs: blobs}
+	acme := tenant.WithID(context.Background(), "acme")
+	globex := tenant.WithID(context.Background(), "globex")
+	const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	first, err := s.storeContent(acme, checksum, strings.NewReader("test"), 4, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.storeContent(acme, checksum, strings.NewReader("test"), 4, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.storeContent(globex, checksum, strings.NewReader("test"), 4, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != "acme/sha256/"+checksum || second != first {
+		t.Errorf("keys = %q, %q, want both acme/sha256/%s", first, second, checksum)
+	}
+	if other != "globex/sha256/"+checksum {
+		t.Errorf("other tenant's key = %q, want a key of its own", other)
+	}
+	if blobs.puts != 2 {
+		t.Errorf("stored content %d times, want once per tenant", blobs.puts)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	now := time.Now()
+	old, recent := now.Add(-2*time.Hour), now.Add(-10*time.Minute)
+	blobs := &memoryBlobs{blobs: map[string][]byte{}, failDelete: map[string]bool{}}
+	repo := &memoryBlobRepo{}
+	add := func(key string, refs int, unreferencedAt *time.Time) {
+		blobs.blobs[key] = []byte(key)
+		repo.rows = append(repo.rows, &domain.DocumentBlob{ID: uint64(len(repo.rows) + 1), StorageKey: key, RefCount: refs, Stored: true, UnreferencedAt: unreferencedAt})
+	}
+	// More garbage than one pass deletes.
+	for i := 0; i < blobGCBatchSize*2+5; i++ {
+		add("garbage/"+strings.Repeat("x", i+1), 0, &old)
+	}
+	add("referenced", 2, nil)
+	add("within-grace", 0, &recent)
+	add("undeletable", 0, &old)
+	blobs.failDelete["undeletable"] = true
+
+	s := &ApplicationDocumentService{blobRepo: repo, blobs: blobs}
+	if err := s.CollectGarbage(context.Background(), now, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"referenced", "undeletable", "within-grace"}
+	if got := blobs.keys(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("blobs left = %v, want %v", got, want)
+	}
+	if len(repo.rows) != len(want) {
+		t.Errorf("%d blob rows left, want %d", len(repo.rows), len(want))
+	}
+	if repo.passes != 3 {
+		t.Errorf("collected in %d passes, want 3", repo.passes)
+	}
+}
+
+func TestContentKey(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), "acme")
+	if got := contentKey(ctx, "abc"); got != "acme/sha256/abc" {
+		t.Errorf("contentKey() = %q", got)
+	}
+	if got := contentKey(context.Background(), "abc"); got != tenant.DefaultID+"/sha256/abc" {
+		t.Errorf("contentKey() without a tenant = %q", got)
+	}
+}
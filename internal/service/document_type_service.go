@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDocumentTypeNotFound = errors.New("document type not found")
+	ErrDocumentTypeExists   = errors.New("document type already exists")
+	// ErrDocumentTypeInUse is returned when deleting a document type that
+	// applications still declare.
+	ErrDocumentTypeInUse   = errors.New("document type is in use")
+	ErrInvalidDocumentType = errors.New("invalid document type")
+)
+
+var documentTypeKey = regexp.MustCompile(`^[a-z0-9][a-z0-9_]{0,99}$`)
+
+// DocumentTypeService manages the tenant's catalog of document types.
+type DocumentTypeService struct {
+	typeRepo repository.DocumentTypeRepository
+}
+
+func NewDocumentTypeService(typeRepo repository.DocumentTypeRepository) *DocumentTypeService {
+	return &DocumentTypeService{typeRepo: typeRepo}
+}
+
+func (s *DocumentTypeService) Create(ctx context.Context, req CreateDocumentTypeRequest) (*domain.DocumentType, error) {
+	if !documentTypeKey.MatchString(req.Key) {
+		return nil, fmt.Errorf("%w: key must be lower-case letters, digits and underscores", ErrInvalidDocumentType)
+	}
+	_, err := s.typeRepo.GetByKey(ctx, req.Key)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %q", ErrDocumentTypeExists, req.Key)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	docType := &domain.DocumentType{Key: req.Key}
+	if err := applyDocumentType(docType, req.UpdateDocumentTypeRequest); err != nil {
+		return nil, err
+	}
+	// A concurrent request may have taken the key since it was checked.
+	err = s.typeRepo.Create(ctx, docType)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, fmt.Errorf("%w: %q", ErrDocumentTypeExists, req.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return docType, nil
+}
+
+func (s *DocumentTypeService) Get(ctx context.Context, id uint64) (*domain.DocumentType, error) {
+	docType, err := s.typeRepo.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrDocumentTypeNotFound, id)
+	}
+	return docType, err
+}
+
+func (s *DocumentTypeService) List(ctx context.Context) ([]domain.DocumentType, error) {
+	return s.typeRepo.List(ctx)
+}
+
+// Update replaces the document type's name, description and rules. The new
+// rules apply to uploads from now on.
+func (s *DocumentTypeService) Update(ctx context.Context, id uint64, req UpdateDocumentTypeRequest) (*domain.DocumentType, error) {
+	docType, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyDocumentType(docType, req); err != nil {
+		return nil, err
+	}
+	if err := s.typeRepo.Update(ctx, docType); err != nil {
+		return nil, err
+	}
+	return docType, nil
+}
+
+func (s *DocumentTypeService) Delete(ctx context.Context, id uint64) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	count, err := s.typeRepo.CountFileTypes(ctx, id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d application(s) declare it", ErrDocumentTypeInUse, count)
+	}
+	return s.typeRepo.Delete(ctx, id)
+}
+
+func applyDocumentType(docType *domain.DocumentType, req UpdateDocumentTypeRequest) error {
+	displayName := strings.TrimSpace(req.DisplayName)
+	if displayName == "" {
+		return fmt.Errorf("%w: displayName is required", ErrInvalidDocumentType)
+	}
+	mimeTypes, err := normalizeMimeTypes(req.AllowedMimeTypes)
+	if err != nil {
+		return err
+	}
+	docType.DisplayName = displayName
+	docType.Description = req.Description
+	docType.AllowedMimeTypes = mimeTypes
+	docType.AllowedExtensions = normalizeExtensions(req.AllowedExtensions)
+	docType.MaxSize = req.MaxSize
+	docType.RetentionDays = req.RetentionDays
+	return nil
+}
+
+// normalizeMimeTypes lower-cases the types and drops their parameters.
+func normalizeMimeTypes(types []string) ([]string, error) {
+	out := []string{}
+	for _, t := range types {
+		mediaType, _, err := mime.ParseMediaType(t)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			return nil, fmt.Errorf("%w: bad MIME type %q", ErrInvalidDocumentType, t)
+		}
+		out = append(out, mediaType)
+	}
+	return out, nil
+}
+
+// normalizeExtensions lower-cases the extensions and adds the leading dot.
+func normalizeExtensions(exts []string) []string {
+	out := []string{}
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		out = append(out, ext)
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"gorm.io/gorm"
+)
+
+// memoryDocumentTypes keeps a catalog in a map. taken makes Create fail as
+// when a concurrent request inserted the key first.
+type memoryDocumentTypes struct {
+	repository.DocumentTypeRepository
+	types     map[uint64]domain.DocumentType
+	fileTypes map[uint64]int64
+	taken     bool
+	deleted   []uint64
+}
+
+func (m *memoryDocumentTypes) Create(_ context.Context, docType *domain.DocumentType) error {
+	if m.taken {
+		return gorm.ErrDuplicatedKey
+	}
+	docType.ID = uint64(len(m.types) + 1)
+	m.types[docType.ID] = *docType
+	return nil
+}
+
+func (m *memoryDocumentTypes) GetByID(_ context.Context, id uint64) (*domain.DocumentType, error) {
+	docType, ok := m.types[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &docType, nil
+}
+
+func (m *memoryDocumentTypes) GetByKey(_ context.Context, key string) (*domain.DocumentType, error) {
+	for _, docType := range m.types {
+		if docType.Key == key {
+			return &docType, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryDocumentTypes) CountFileTypes(_ context.Context, id uint64) (int64, error) {
+	return m.fileTypes[id], nil
+}
+
+func (m *memoryDocumentTypes) Delete(_ context.Context, id uint64) error {
+	m.deleted = append(m.deleted, id)
+	delete(m.types, id)
+	return nil
+}
+
+func TestCreateDocumentTypeKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr error
+	}{
+		{"passport", nil},
+		{"proof_of_address_2", nil},
+		{"9to5_contract", nil},
+		{"Passport", ErrInvalidDocumentType},
+		{"_passport", ErrInvalidDocumentType},
+		{"passport-copy", ErrInvalidDocumentType},
+		{"passport copy", ErrInvalidDocumentType},
+		{"", ErrInvalidDocumentType},
+		{strings.Repeat("a", 100), nil},
+		{strings.Repeat("a", 101), ErrInvalidDocumentType},
+		{"payslip", ErrDocumentTypeExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			repo := &memoryDocumentTypes{types: map[uint64]domain.DocumentType{1: {ID: 1, Key: "payslip"}}}
+			s := NewDocumentTypeService(repo)
+			_, err := s.Create(context.Background(), CreateDocumentTypeRequest{
+				Key:                       tt.key,
+				UpdateDocumentTypeRequest: UpdateDocumentTypeRequest{DisplayName: "Some document"},
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create(%q) error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A key inserted concurrently after the lookup is reported as taken too.
+func TestCreateDocumentTypeDuplicateInsert(t *testing.T) {
+	repo := &memoryDocumentTypes{types: map[uint64]domain.DocumentType{}, taken: true}
+	_, err := NewDocumentTypeService(repo).Create(context.Background(), CreateDocumentTypeRequest{
+		Key:                       "passport",
+		UpdateDocumentTypeRequest: UpdateDocumentTypeRequest{DisplayName: "Passport"},
+	})
+	if !errors.Is(err, ErrDocumentTypeExists) {
+		t.Fatalf("Create() error = %v, want ErrDocumentTypeExists", err)
+	}
+}
+
+func TestCreateDocumentTypeNormalizesRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            UpdateDocumentTypeRequest
+		wantMimeTypes  []string
+		wantExtensions []string
+		wantErr        error
+	}{
+		{"normalized", UpdateDocumentTypeRequest{
+			DisplayName:       "  Passport ",
+			AllowedMimeTypes:  []string{"Application/PDF", "image/jpeg; charset=binary"},
+			AllowedExtensions: []string{"PDF", " .Jpg ", ""},
+		}, []string{"application/pdf", "image/jpeg"}, []string{".pdf", ".jpg"}, nil},
+		{"no rules", UpdateDocumentTypeRequest{DisplayName: "Passport"}, []string{}, []string{}, nil},
+		{"bad MIME type", UpdateDocumentTypeRequest{DisplayName: "Passport", AllowedMimeTypes: []string{"pdf"}}, nil, nil, ErrInvalidDocumentType},
+		{"unparsable MIME type", UpdateDocumentTypeRequest{DisplayName: "Passport", AllowedMimeTypes: []string{"image/;"}}, nil, nil, ErrInvalidDocumentType},
+		{"blank display name", UpdateDocumentTypeRequest{DisplayName: "   "}, nil, nil, ErrInvalidDocumentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryDocumentTypes{types: map[uint64]domain.DocumentType{}}
+			docType, err := NewDocumentTypeService(repo).Create(context.Background(), CreateDocumentTypeRequest{Key: "passport", UpdateDocumentTypeRequest: tt.req})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(repo.types) != 0 {
+					t.Error("created an invalid document type")
+				}
+				return
+			}
+			if docType.DisplayName != "Passport" {
+				t.Errorf("display name = %q", docType.DisplayName)
+			}
+			if !reflect.DeepEqual(docType.AllowedMimeTypes, tt.wantMimeTypes) || !reflect.DeepEqual(docType.AllowedExtensions, tt.wantExtensions) {
+				t.Errorf("rules = %q, %q, want %q, %q", docType.AllowedMimeTypes, docType.AllowedExtensions, tt.wantMimeTypes, tt.wantExtensions)
+			}
+		})
+	}
+}
+
+func TestDeleteDocumentType(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        uint64
+		fileTypes int64
+		wantErr   error
+	}{
+		{"unused", 1, 0, nil},
+		{"in use", 1, 2, ErrDocumentTypeInUse},
+		{"missing", 2, 0, ErrDocumentTypeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryDocumentTypes{
+				types:     map[uint64]domain.DocumentType{1: {ID: 1, Key: "passport"}},
+				fileTypes: map[uint64]int64{1: tt.fileTypes},
+			}
+			err := NewDocumentTypeService(repo).Delete(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if (err == nil) != (len(repo.deleted) == 1) {
+				t.Errorf("deleted %v with error %v", repo.deleted, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/storage"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrUploadExpired  = errors.New("upload expired")
+	// ErrUploadOffsetMismatch is returned when a chunk does not continue
+	// the upload where it stands, e.g. after a concurrent chunk landed.
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	ErrInvalidUpload        = errors.New("invalid upload")
+	ErrUnsupportedChecksum  = errors.New("unsupported checksum algorithm")
+	// ErrUploadInProgress is returned while another request turns the
+	// upload into a document.
+	ErrUploadInProgress = errors.New("upload is being completed")
+)
+
+// finalizeLease is how long a request may take to turn an upload into a
+// document before another one may try. It outlasts the chunk timeout, so a
+// claim only lapses when its holder died.
+const finalizeLease = 30 * time.Minute
+
+// uploadChecksums are the algorithms accepted for chunk checksums.
+var uploadChecksums = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// UploadChecksumAlgorithms lists the algorithms accepted for chunk checksums.
+var UploadChecksumAlgorithms = []string{"sha1", "sha256", "sha512"}
+
+// CreateUploadInput describes a resumable upload about to start.
+type CreateUploadInput struct {
+	FileTypeID  uint64
+	FileName    string
+	ContentType string
+	Length      int64
+	// Checksum is the hex SHA-256 of the whole file, verified once the
+	// upload is complete. Optional.
+	Checksum string
+	// UserID lets staff upload on behalf of another user.
+	UserID uint64
+	// ReplacesID makes the document a new version of that document.
+	ReplacesID uint64
+	// ValidUntil is the last day the document is valid. Optional.
+	ValidUntil *time.Time
+}
+
+// ChunkChecksum is the checksum a client sent for one chunk.
+type ChunkChecksum struct {
+	Algorithm string
+	Sum       []byte
+}
+
+// DocumentUploadService receives documents in chunks, so an upload
+// interrupted by a bad connection can resume where it stopped. Chunks are
+// stored as blobs and joined into a document by the last one.
+type DocumentUploadService struct {
+	uploadRepo repository.DocumentUploadRepository
+	docs       *ApplicationDocumentService
+	expiry     time.Duration
+}
+
+func NewDocumentUploadService(uploadRepo repository.DocumentUploadRepository, docs *ApplicationDocumentService, expiry time.Duration) *DocumentUploadService {
+	return &DocumentUploadService{uploadRepo: uploadRepo, docs: docs, expiry: expiry}
+}
+
+// MaxSize is the largest upload the service accepts for any file type.
+func (s *DocumentUploadService) MaxSize() int64 {
+	return s.docs.limits.MaxSize
+}
+
+// Create starts an upload. The document type's name and size rules and the
+// storage quotas are checked now; the content and the quotas again when the
+// upload completes.
+func (s *DocumentUploadService) Create(ctx context.Context, appID uint64, in CreateUploadInput) (*domain.DocumentUpload, error) {
+	request := map[string]interface{}{
+		"fileTypeId":  int64(in.FileTypeID),
+		"fileName":    in.FileName,
+		"contentType": in.ContentType,
+		"size":        in.Length,
+		"replacesId":  int64(in.ReplacesID),
+	}
+	app, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, appID, policy.ActionDocumentAdd, request)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := actingUserID(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if in.Length <= 0 {
+		return nil, fmt.Errorf("%w: length must be positive", ErrInvalidUpload)
+	}
+	if in.Checksum != "" {
+		if sum, err := hex.DecodeString(in.Checksum); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%w: checksum must be a hex SHA-256", ErrInvalidUpload)
+		}
+	}
+	previous, fileTypeID, err := s.docs.replacement(ctx, appID, in.ReplacesID, in.FileTypeID)
+	if err != nil {
+		return nil, err
+	}
+	fileType, err := s.docs.fileRepo.GetByID(ctx, appID, fileTypeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrFileTypeNotFound, fileTypeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if maxSize := s.docs.limits.maxSizeFor(fileType.DocumentType); maxSize > 0 && in.Length > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d allowed", ErrDocumentTooLarge, in.Length, maxSize)
+	}
+	if err := checkExtension(fileType.DocumentType, in.FileName); err != nil {
+		return nil, err
+	}
+	if err := s.docs.checkQuota(ctx, app, in.Length); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	upload := &domain.DocumentUpload{
+		ID:            hex.EncodeToString(b),
+		ApplicationID: appID,
+		FileTypeID:    fileType.ID,
+		FileName:      in.FileName,
+		ContentType:   in.ContentType,
+		Length:        in.Length,
+		Checksum:      in.Checksum,
+		PartKeys:      []string{},
+		UploadedBy:    userID,
+		ValidUntil:    in.ValidUntil,
+		ExpiresAt:     time.Now().Add(s.expiry),
+	}
+	if previous != nil {
+		upload.ReplacesID = &previous.ID
+	}
+	if err := s.uploadRepo.Create(ctx, upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Get returns the upload, e.g. to tell a client where to resume.
+func (s *DocumentUploadService) Get(ctx context.Context, appID uint64, id string) (*domain.DocumentUpload, error) {
+	if _, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, appID, policy.ActionDocumentAdd, nil); err != nil {
+		return nil, err
+	}
+	return s.getUpload(ctx, appID, id, false)
+}
+
+// Append stores the chunk read from body at offset, which has to be where
+// the upload stands. A chunk cut short by the connection is kept as far as
+// it was received, unless it came with a checksum. The chunk that completes
+// the upload turns it into a document; sending an empty chunk at the end
+// retries that step if it failed.
+func (s *DocumentUploadService) Append(ctx context.Context, appID uint64, id string, offset int64, body io.Reader, checksum *ChunkChecksum) (*domain.DocumentUpload, error) {
+	if _, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, appID, policy.ActionDocumentAdd, nil); err != nil {
+		return nil, err
+	}
+	upload, err := s.getUpload(ctx, appID, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset || upload.DocumentID != nil {
+		return nil, fmt.Errorf("%w: upload is at offset %d", ErrUploadOffsetMismatch, upload.Offset)
+	}
+	var chunkHash hash.Hash
+	if checksum != nil {
+		newHash, ok := uploadChecksums[checksum.Algorithm]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedChecksum, checksum.Algorithm)
+		}
+		chunkHash = newHash()
+	}
+
+	spool, err := os.CreateTemp("", "chunk-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	remaining := upload.Length - upload.Offset
+	var dst io.Writer = spool
+	if chunkHash != nil {
+		dst = io.MultiWriter(spool, chunkHash)
+	}
+	n, readErr := io.Copy(dst, io.LimitReader(body, remaining+1))
+	if n > remaining {
+		return nil, fmt.Errorf("%w: chunk runs past the upload length of %d bytes", ErrDocumentTooLarge, upload.Length)
+	}
+	if readErr != nil && (chunkHash != nil || n == 0) {
+		return nil, readErr
+	}
+	if chunkHash != nil && !bytes.Equal(chunkHash.Sum(nil), checksum.Sum) {
+		return nil, fmt.Errorf("%w: chunk %s differs", ErrChecksumMismatch, checksum.Algorithm)
+	}
+
+	// What was received is kept even when the client has gone away.
+	if n > 0 {
+		if err := s.storePart(context.WithoutCancel(ctx), upload, spool, n); err != nil {
+			return nil, err
+		}
+	}
+	if readErr != nil {
+		log.Printf("Upload %s: kept %d bytes of an interrupted chunk: %v", upload.ID, n, readErr)
+		return upload, nil
+	}
+	if upload.Offset == upload.Length {
+		return s.finalize(ctx, upload)
+	}
+	return upload, nil
+}
+
+// Terminate abandons the upload and removes what was received. An upload
+// another request is turning into a document cannot be terminated, since
+// the document is read from its parts.
+func (s *DocumentUploadService) Terminate(ctx context.Context, appID uint64, id string) error {
+	if _, err := s.docs.authz.requireAuthorized(ctx, s.docs.appRepo, appID, policy.ActionDocumentAdd, nil); err != nil {
+		return err
+	}
+	upload, err := s.getUpload(ctx, appID, id, true)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.uploadRepo.DeleteUnclaimed(ctx, appID, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		// Either it was claimed or it is gone since it was read.
+		if _, err := s.getUpload(ctx, appID, id, true); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", ErrUploadInProgress, id)
+	}
+	s.docs.removeBlobs(ctx, upload.PartKeys)
+	return nil
+}
+
+// DeleteExpired removes expired uploads of every tenant and their parts.
+func (s *DocumentUploadService) DeleteExpired(ctx context.Context, now time.Time) error {
+	for {
+		uploads, err := s.uploadRepo.ListExpired(ctx, now, 100)
+		if err != nil {
+			return err
+		}
+		for _, upload := range uploads {
+			if err := s.uploadRepo.DeleteByID(ctx, upload.ID); err != nil {
+				return err
+			}
+			s.docs.removeBlobs(ctx, upload.PartKeys)
+		}
+		if len(uploads) < 100 {
+			return nil
+		}
+	}
+}
+
+func (s *DocumentUploadService) getUpload(ctx context.Context, appID uint64, id string, allowExpired bool) (*domain.DocumentUpload, error) {
+	upload, err := s.uploadRepo.GetByID(ctx, appID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !allowExpired && time.Now().After(upload.ExpiresAt) {
+		return nil, fmt.Errorf("%w: %s", ErrUploadExpired, id)
+	}
+	return upload, nil
+}
+
+// storePart saves the spooled chunk as a blob and advances the upload.
+func (s *DocumentUploadService) storePart(ctx context.Context, upload *domain.DocumentUpload, spool *os.File, size int64) error {
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key, err := newStorageKey(ctx, upload.ApplicationID)
+	if err != nil {
+		return err
+	}
+	if err := s.docs.blobs.Put(ctx, key, spool, size, "application/octet-stream"); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	ok, err := s.uploadRepo.AppendPart(ctx, upload, upload.Offset, key, size, time.Now().Add(s.expiry))
+	if err != nil || !ok {
+		s.docs.removeBlobs(ctx, []string{key})
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: another chunk was stored first", ErrUploadOffsetMismatch)
+	}
+	return nil
+}
+
+// finalize joins the parts into a document. Failures other than rejected
+// content leave the upload to be retried. The upload is claimed first, so
+// only one request creates the document; a request that finds it completed
+// meanwhile gets it as it is now.
+func (s *DocumentUploadService) finalize(ctx context.Context, upload *domain.DocumentUpload) (*domain.DocumentUpload, error) {
+	now := time.Now()
+	claimed, err := s.uploadRepo.Claim(ctx, upload.ID, now, now.Add(finalizeLease))
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		current, err := s.getUpload(ctx, upload.ApplicationID, upload.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		if current.DocumentID == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUploadInProgress, upload.ID)
+		}
+		return current, nil
+	}
+
+	doc, err := s.createDocument(ctx, upload)
+	if err != nil {
+		if unclaimErr := s.uploadRepo.Unclaim(context.WithoutCancel(ctx), upload.ID); unclaimErr != nil {
+			log.Printf("Upload %s: failed to release claim: %v", upload.ID, unclaimErr)
+		}
+		return nil, err
+	}
+	// A failure here keeps the claim: the document exists, and completing
+	// the upload again before the claim lapses would create another.
+	if err := s.uploadRepo.SetDocument(ctx, upload.ID, doc.ID); err != nil {
+		return nil, err
+	}
+	s.docs.removeBlobs(ctx, upload.PartKeys)
+	upload.DocumentID = &doc.ID
+	upload.PartKeys = []string{}
+	return upload, nil
+}
+
+// createDocument uploads the joined parts as a document. Content the
+// document store rejects ends the upload.
+func (s *DocumentUploadService) createDocument(ctx context.Context, upload *domain.DocumentUpload) (*domain.ApplicationDocument, error) {
+	content := &partsReader{ctx: ctx, blobs: s.docs.blobs, keys: upload.PartKeys}
+	defer content.Close()
+	var replacesID uint64
+	if upload.ReplacesID != nil {
+		replacesID = *upload.ReplacesID
+	}
+	doc, err := s.docs.Upload(ctx, upload.ApplicationID, UploadDocumentInput{
+		FileTypeID:  upload.FileTypeID,
+		FileName:    upload.FileName,
+		ContentType: upload.ContentType,
+		Size:        upload.Length,
+		Content:     content,
+		UserID:      upload.UploadedBy,
+		Checksum:    upload.Checksum,
+		ReplacesID:  replacesID,
+		ValidUntil:  upload.ValidUntil,
+	})
+	if err != nil {
+		if isRejectedContent(err) {
+			if delErr := s.uploadRepo.Delete(ctx, upload.ApplicationID, upload.ID); delErr == nil {
+				s.docs.removeBlobs(ctx, upload.PartKeys)
+			}
+		}
+		return nil, err
+	}
+	return doc, nil
+}
+
+// isRejectedContent reports whether the document store refused the content
+// itself, so retrying cannot succeed.
+func isRejectedContent(err error) bool {
+	for _, target := range []error{ErrDocumentTooLarge, ErrDocumentTypeNotAllowed, ErrDocumentMismatch, ErrUnsafeArchive, ErrChecksumMismatch, ErrQuotaExceeded, ErrFileTypeNotFound, ErrDocumentNotFound, ErrVersionConflict} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// partsReader reads the parts of an upload one after another, opening each
+// only when it is reached.
+type partsReader struct {
+	ctx     context.Context
+	blobs   storage.BlobStore
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			part, err := r.blobs.Get(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("open upload part: %w", err)
+			}
+			r.current, r.keys = part, r.keys[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
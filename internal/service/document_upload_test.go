@@ -0,0 +1,452 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/storage"
+	"gorm.io/gorm"
+)
+
+// memoryBlobs is a BlobStore over a map.
+type memoryBlobs struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	puts  int
+	// failDelete makes deleting these keys fail.
+	failDelete map[string]bool
+}
+
+func (m *memoryBlobs) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = data
+	m.puts++
+	return nil
+}
+
+func (m *memoryBlobs) Get(_ context.Context, key string) (io.ReadSeekCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return readSeekNopCloser{bytes.NewReader(data)}, nil
+}
+
+func (m *memoryBlobs) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failDelete[key] {
+		return errors.New("blob store unavailable")
+	}
+	delete(m.blobs, key)
+	return nil
+}
+
+func (m *memoryBlobs) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.blobs)
+}
+
+func (m *memoryBlobs) keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.blobs))
+	for key := range m.blobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type readSeekNopCloser struct{ *bytes.Reader }
+
+func (readSeekNopCloser) Close() error { return nil }
+
+// memoryUploads is a DocumentUploadRepository over a map.
+type memoryUploads struct {
+	mu      sync.Mutex
+	uploads map[string]domain.DocumentUpload
+}
+
+func (m *memoryUploads) Create(_ context.Context, upload *domain.DocumentUpload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads[upload.ID] = *upload
+	return nil
+}
+
+func (m *memoryUploads) GetByID(_ context.Context, appID uint64, id string) (*domain.DocumentUpload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[id]
+	if !ok || upload.ApplicationID != appID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	upload.PartKeys = append([]string{}, upload.PartKeys...)
+	return &upload, nil
+}
+
+func (m *memoryUploads) AppendPart(_ context.Context, upload *domain.DocumentUpload, offset int64, partKey string, size int64, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.uploads[upload.ID]
+	if !ok || stored.Offset != offset {
+		return false, nil
+	}
+	stored.Offset = offset + size
+	stored.PartKeys = append(append([]string{}, stored.PartKeys...), partKey)
+	stored.ExpiresAt = expiresAt
+	m.uploads[upload.ID] = stored
+	upload.Offset, upload.PartKeys, upload.ExpiresAt = stored.Offset, append([]string{}, stored.PartKeys...), expiresAt
+	return true, nil
+}
+
+func (m *memoryUploads) Claim(_ context.Context, id string, now, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[id]
+	if !ok || upload.DocumentID != nil || (upload.FinalizingUntil != nil && !upload.FinalizingUntil.Before(now)) {
+		return false, nil
+	}
+	upload.FinalizingUntil = &until
+	m.uploads[id] = upload
+	return true, nil
+}
+
+func (m *memoryUploads) Unclaim(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload := m.uploads[id]
+	upload.FinalizingUntil = nil
+	m.uploads[id] = upload
+	return nil
+}
+
+func (m *memoryUploads) SetDocument(_ context.Context, id string, documentID uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload := m.uploads[id]
+	upload.DocumentID = &documentID
+	upload.PartKeys = []string{}
+	upload.FinalizingUntil = nil
+	m.uploads[id] = upload
+	return nil
+}
+
+func (m *memoryUploads) Delete(_ context.Context, _ uint64, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.uploads, id)
+	return nil
+}
+
+func (m *memoryUploads) DeleteUnclaimed(_ context.Context, appID uint64, id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[id]
+	if !ok || upload.ApplicationID != appID || (upload.FinalizingUntil != nil && !upload.FinalizingUntil.Before(now)) {
+		return false, nil
+	}
+	delete(m.uploads, id)
+	return true, nil
+}
+
+func (m *memoryUploads) ListExpired(context.Context, time.Time, int) ([]domain.DocumentUpload, error) {
+	return nil, nil
+}
+
+func (m *memoryUploads) DeleteByID(ctx context.Context, id string) error {
+	return m.Delete(ctx, 0, id)
+}
+
+func (m *memoryUploads) set(id string, update func(*domain.DocumentUpload)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload := m.uploads[id]
+	update(&upload)
+	m.uploads[id] = upload
+}
+
+// uploadApplications serves one application owned by user 7.
+type uploadApplications struct {
+	repository.ApplicationRepository
+}
+
+func (uploadApplications) FindByID(_ context.Context, id uint64) (*domain.Application, error) {
+	if id != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &domain.Application{ID: 1, UserID: 7}, nil
+}
+
+// uploadFileTypes serves a file type of a document type without rules for
+// any ID.
+type uploadFileTypes struct {
+	repository.ApplicationFileTypeRepository
+}
+
+func (uploadFileTypes) GetByID(_ context.Context, _ uint64, id uint64) (*domain.ApplicationUploadedFileType, error) {
+	return &domain.ApplicationUploadedFileType{
+		ID:             id,
+		DocumentTypeID: 1,
+		FileTypeName:   "notes",
+		DocumentType:   &domain.DocumentType{ID: 1, Key: "notes", DisplayName: "Notes"},
+	}, nil
+}
+
+// uploadDocuments records created documents. Create fails with failNext
+// once, and waits for release when it is set.
+type uploadDocuments struct {
+	repository.ApplicationDocumentRepository
+	mu       sync.Mutex
+	created  []domain.ApplicationDocument
+	failNext error
+	entered  chan struct{}
+	release  chan struct{}
+}
+
+func (d *uploadDocuments) Create(_ context.Context, doc *domain.ApplicationDocument) error {
+	if d.release != nil {
+		d.entered <- struct{}{}
+		<-d.release
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.failNext; err != nil {
+		d.failNext = nil
+		return err
+	}
+	doc.ID = uint64(len(d.created) + 1)
+	d.created = append(d.created, *doc)
+	return nil
+}
+
+func (d *uploadDocuments) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.created)
+}
+
+type uploadFixture struct {
+	service *DocumentUploadService
+	uploads *memoryUploads
+	docs    *uploadDocuments
+	blobs   *memoryBlobs
+	ctx     context.Context
+}
+
+func newUploadFixture(t *testing.T) *uploadFixture {
+	t.Helper()
+	f := &uploadFixture{
+		uploads: &memoryUploads{uploads: map[string]domain.DocumentUpload{}},
+		docs:    &uploadDocuments{},
+		blobs:   &memoryBlobs{blobs: map[string][]byte{}},
+		ctx:     auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user:7", UserID: 7, Roles: []string{auth.RoleApplicant}}),
+	}
+	docService := NewApplicationDocumentService(f.docs, uploadFileTypes{}, uploadApplications{}, &memoryBlobRepo{}, f.blobs, UploadLimits{}, nil, nil, nil)
+	f.service = NewDocumentUploadService(f.uploads, docService, time.Hour)
+	return f
+}
+
+// start creates an upload of content.
+func (f *uploadFixture) start(t *testing.T, content string) *domain.DocumentUpload {
+	t.Helper()
+	upload, err := f.service.Create(f.ctx, 1, CreateUploadInput{
+		FileTypeID:  3,
+		FileName:    "notes.txt",
+		ContentType: "text/plain",
+		Length:      int64(len(content)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return upload
+}
+
+// cutOffReader returns its data, then fails as a dropped connection would.
+type cutOffReader struct{ data *bytes.Reader }
+
+func (r *cutOffReader) Read(p []byte) (int, error) {
+	if r.data.Len() == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return r.data.Read(p)
+}
+
+const uploadContent = "hello, resumable world\n"
+
+func TestUploadAppendCompletes(t *testing.T) {
+	f := newUploadFixture(t)
+	upload := f.start(t, uploadContent)
+
+	if _, err := f.service.Append(f.ctx, 1, upload.ID, 0, bytes.NewReader([]byte(uploadContent[:6])), nil); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(uploadContent[6:]))
+	done, err := f.service.Append(f.ctx, 1, upload.ID, 6, bytes.NewReader([]byte(uploadContent[6:])), &ChunkChecksum{Algorithm: "sha256", Sum: sum[:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.DocumentID == nil || f.docs.count() != 1 {
+		t.Fatalf("upload = %+v, %d documents, want one document", done, f.docs.count())
+	}
+	// Only the document's content is left; the parts were removed.
+	if f.blobs.len() != 1 {
+		t.Errorf("%d blobs stored, want 1", f.blobs.len())
+	}
+}
+
+func TestUploadAppendRejects(t *testing.T) {
+	wrong := sha256.Sum256([]byte("something else"))
+	tests := []struct {
+		name     string
+		offset   int64
+		chunk    string
+		checksum *ChunkChecksum
+		wantErr  error
+	}{
+		// 409 in the API.
+		{"offset mismatch", 4, "hello", nil, ErrUploadOffsetMismatch},
+		// 460 in the API.
+		{"bad checksum", 0, "hello", &ChunkChecksum{Algorithm: "sha256", Sum: wrong[:]}, ErrChecksumMismatch},
+		{"unsupported checksum", 0, "hello", &ChunkChecksum{Algorithm: "md5", Sum: []byte{1}}, ErrUnsupportedChecksum},
+		// 413 in the API.
+		{"past the declared length", 0, uploadContent + "more", nil, ErrDocumentTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUploadFixture(t)
+			upload := f.start(t, uploadContent)
+
+			_, err := f.service.Append(f.ctx, 1, upload.ID, tt.offset, bytes.NewReader([]byte(tt.chunk)), tt.checksum)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Append() error = %v, want %v", err, tt.wantErr)
+			}
+			got, _ := f.uploads.GetByID(f.ctx, 1, upload.ID)
+			if got.Offset != 0 || f.blobs.len() != 0 {
+				t.Errorf("offset %d and %d blobs after a rejected chunk", got.Offset, f.blobs.len())
+			}
+		})
+	}
+}
+
+func TestUploadAppendKeepsCutOffChunk(t *testing.T) {
+	f := newUploadFixture(t)
+	upload := f.start(t, uploadContent)
+
+	got, err := f.service.Append(f.ctx, 1, upload.ID, 0, &cutOffReader{data: bytes.NewReader([]byte(uploadContent[:5]))}, nil)
+	if err != nil {
+		t.Fatalf("Append() error = %v, want the received bytes kept", err)
+	}
+	if got.Offset != 5 || f.blobs.len() != 1 {
+		t.Fatalf("offset %d with %d blobs, want 5 with 1", got.Offset, f.blobs.len())
+	}
+
+	// A chunk with a checksum cannot be verified when cut off, so nothing
+	// of it is kept.
+	sum := sha256.Sum256([]byte(uploadContent[5:]))
+	_, err = f.service.Append(f.ctx, 1, upload.ID, 5, &cutOffReader{data: bytes.NewReader([]byte(uploadContent[5:10]))}, &ChunkChecksum{Algorithm: "sha256", Sum: sum[:]})
+	if err == nil {
+		t.Fatal("Append() kept a cut-off chunk with a checksum")
+	}
+	got, _ = f.uploads.GetByID(f.ctx, 1, upload.ID)
+	if got.Offset != 5 {
+		t.Errorf("offset %d, want 5", got.Offset)
+	}
+}
+
+// An empty chunk at the end retries turning the upload into a document
+// after a failure.
+func TestUploadRetryFinalize(t *testing.T) {
+	f := newUploadFixture(t)
+	upload := f.start(t, uploadContent)
+	f.docs.failNext = errors.New("database unavailable")
+
+	if _, err := f.service.Append(f.ctx, 1, upload.ID, 0, bytes.NewReader([]byte(uploadContent)), nil); err == nil {
+		t.Fatal("Append() succeeded although the document could not be recorded")
+	}
+	got, _ := f.uploads.GetByID(f.ctx, 1, upload.ID)
+	if got.Offset != got.Length || got.FinalizingUntil != nil || len(got.PartKeys) != 1 {
+		t.Fatalf("upload after a failed finalize = %+v, want complete, unclaimed, with its part", got)
+	}
+
+	done, err := f.service.Append(f.ctx, 1, upload.ID, got.Length, bytes.NewReader(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.DocumentID == nil || f.docs.count() != 1 {
+		t.Fatalf("upload = %+v, %d documents, want one document", done, f.docs.count())
+	}
+}
+
+// Only one of two requests completing an upload creates a document; the
+// upload cannot be terminated in the meantime.
+func TestUploadFinalizeClaim(t *testing.T) {
+	f := newUploadFixture(t)
+	upload := f.start(t, uploadContent)
+	f.docs.entered = make(chan struct{})
+	f.docs.release = make(chan struct{})
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := f.service.Append(f.ctx, 1, upload.ID, 0, bytes.NewReader([]byte(uploadContent)), nil)
+		first <- err
+	}()
+	<-f.docs.entered
+
+	length := int64(len(uploadContent))
+	if _, err := f.service.Append(f.ctx, 1, upload.ID, length, bytes.NewReader(nil), nil); !errors.Is(err, ErrUploadInProgress) {
+		t.Errorf("second finalize error = %v, want ErrUploadInProgress", err)
+	}
+	if err := f.service.Terminate(f.ctx, 1, upload.ID); !errors.Is(err, ErrUploadInProgress) {
+		t.Errorf("Terminate() error = %v, want ErrUploadInProgress", err)
+	}
+
+	close(f.docs.release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if f.docs.count() != 1 {
+		t.Fatalf("%d documents created, want 1", f.docs.count())
+	}
+}
+
+// A claim whose holder died lapses, and the upload can be completed or
+// terminated again.
+func TestUploadExpiredClaim(t *testing.T) {
+	f := newUploadFixture(t)
+	upload := f.start(t, uploadContent)
+	if _, err := f.service.Append(f.ctx, 1, upload.ID, 0, bytes.NewReader([]byte(uploadContent[:5])), nil); err != nil {
+		t.Fatal(err)
+	}
+	lapsed := time.Now().Add(-time.Minute)
+	f.uploads.set(upload.ID, func(u *domain.DocumentUpload) { u.FinalizingUntil = &lapsed })
+
+	if err := f.service.Terminate(f.ctx, 1, upload.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.uploads.GetByID(f.ctx, 1, upload.ID); err == nil || f.blobs.len() != 0 {
+		t.Errorf("upload or its parts left after Terminate()")
+	}
+	if err := f.service.Terminate(f.ctx, 1, upload.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("second Terminate() error = %v, want ErrUploadNotFound", err)
+	}
+}
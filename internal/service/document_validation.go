@@ -0,0 +1,222 @@
+package service
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var (
+	ErrDocumentTooLarge       = errors.New("document is too large")
+	ErrDocumentTypeNotAllowed = errors.New("document type not allowed")
+	// ErrDocumentMismatch is returned when the content does not match the
+	// declared content type or the file name extension.
+	ErrDocumentMismatch = errors.New("document content does not match its name or type")
+	ErrUnsafeArchive    = errors.New("archive rejected")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// sniffLen is how much of the content is inspected to detect its type.
+const sniffLen = 3072
+
+// UploadLimits apply to every upload. Archives are unpacked to check they
+// stay within the archive limits.
+type UploadLimits struct {
+	MaxSize           int64
+	ArchiveMaxEntries int
+	ArchiveMaxSize    int64
+	ArchiveMaxRatio   int
+	// ApplicationQuota and UserQuota cap the total size of the documents of
+	// an application and of all applications of a user. 0 means no quota.
+	ApplicationQuota int64
+	UserQuota        int64
+}
+
+// maxSizeFor returns the limit for uploads of the document type.
+func (l UploadLimits) maxSizeFor(docType *domain.DocumentType) int64 {
+	if docType.MaxSize > 0 && (l.MaxSize <= 0 || docType.MaxSize < l.MaxSize) {
+		return docType.MaxSize
+	}
+	return l.MaxSize
+}
+
+// checkExtension enforces the document type's extension list.
+func checkExtension(docType *domain.DocumentType, fileName string) error {
+	if len(docType.AllowedExtensions) == 0 {
+		return nil
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, allowed := range docType.AllowedExtensions {
+		if ext == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s accepts %s files, got %q", ErrDocumentTypeNotAllowed,
+		docType.DisplayName, strings.Join(docType.AllowedExtensions, ", "), fileName)
+}
+
+// checkContentType compares the sniffed type with the document type's allowed
+// types, the type the client declared and the file name extension. The
+// allowed types must match exactly: allowing text/plain does not allow HTML
+// even though HTML is text.
+func checkContentType(docType *domain.DocumentType, detected *mimetype.MIME, declared, fileName string) error {
+	if len(docType.AllowedMimeTypes) > 0 && !isAnyOf(detected, docType.AllowedMimeTypes) {
+		return fmt.Errorf("%w: %s accepts %s, content is %s", ErrDocumentTypeNotAllowed,
+			docType.DisplayName, strings.Join(docType.AllowedMimeTypes, ", "), detected.String())
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(declared); err == nil && mediaType != "application/octet-stream" && !related(detected, mediaType) {
+		return fmt.Errorf("%w: declared as %s, content is %s", ErrDocumentMismatch, mediaType, detected.String())
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == "" || ext == detected.Extension() {
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil && !related(detected, mediaType) {
+		return fmt.Errorf("%w: %q has the extension of %s, content is %s", ErrDocumentMismatch, fileName, mediaType, detected.String())
+	}
+	return nil
+}
+
+func isAnyOf(m *mimetype.MIME, types []string) bool {
+	for _, t := range types {
+		if m.Is(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// related reports whether the content could be of the claimed type: either
+// it is that type or a more specific kind of it (a DOCX is a ZIP), or the
+// claim is more specific than what sniffing can tell. Content that could not
+// be identified at all matches no claim.
+func related(detected *mimetype.MIME, mediaType string) bool {
+	if isOrDescends(detected, mediaType) {
+		return true
+	}
+	if detected.Is("application/octet-stream") {
+		return false
+	}
+	claimed := mimetype.Lookup(mediaType)
+	return claimed != nil && isOrDescends(claimed, detected.String())
+}
+
+// isOrDescends reports whether m is the given type or descends from it.
+func isOrDescends(m *mimetype.MIME, mediaType string) bool {
+	for ; m != nil; m = m.Parent() {
+		if m.Is(mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isArchive reports whether the content is unpacked before it is accepted.
+func isArchive(m *mimetype.MIME) bool {
+	return isOrDescends(m, "application/zip") || m.Is("application/gzip")
+}
+
+// limitedReader fails with ErrDocumentTooLarge once more than n bytes are
+// read, so oversized uploads are cut off while streaming.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrDocumentTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrDocumentTooLarge
+	}
+	return n, err
+}
+
+// checkArchive unpacks the archive and rejects it when it holds too many
+// entries or expands too far. The sizes in the archive headers are not
+// trusted; the entries are actually decompressed.
+func (l UploadLimits) checkArchive(m *mimetype.MIME, r io.ReaderAt, size int64) error {
+	var expanded int64
+	var err error
+	if m.Is("application/gzip") {
+		expanded, err = l.gzipSize(io.NewSectionReader(r, 0, size))
+	} else {
+		expanded, err = l.zipSize(r, size)
+	}
+	if err != nil {
+		return err
+	}
+	if l.ArchiveMaxRatio > 0 && size > 0 && expanded > 1<<20 && expanded/size > int64(l.ArchiveMaxRatio) {
+		return fmt.Errorf("%w: expands %d times, at most %d allowed", ErrUnsafeArchive, expanded/size, l.ArchiveMaxRatio)
+	}
+	return nil
+}
+
+func (l UploadLimits) zipSize(r io.ReaderAt, size int64) (int64, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsafeArchive, err)
+	}
+	if l.ArchiveMaxEntries > 0 && len(zr.File) > l.ArchiveMaxEntries {
+		return 0, fmt.Errorf("%w: %d entries, at most %d allowed", ErrUnsafeArchive, len(zr.File), l.ArchiveMaxEntries)
+	}
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s: %v", ErrUnsafeArchive, f.Name, err)
+		}
+		n, err := l.expand(rc, total)
+		rc.Close()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (l UploadLimits) gzipSize(r io.Reader) (int64, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsafeArchive, err)
+	}
+	defer gr.Close()
+	return l.expand(gr, 0)
+}
+
+// expand reads r to the end, failing once the total would pass
+// ArchiveMaxSize.
+func (l UploadLimits) expand(r io.Reader, sofar int64) (int64, error) {
+	src := r
+	if l.ArchiveMaxSize > 0 {
+		src = io.LimitReader(r, l.ArchiveMaxSize-sofar+1)
+	}
+	n, err := io.Copy(io.Discard, src)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsafeArchive, err)
+	}
+	if l.ArchiveMaxSize > 0 && sofar+n > l.ArchiveMaxSize {
+		return 0, fmt.Errorf("%w: expands beyond %d bytes", ErrUnsafeArchive, l.ArchiveMaxSize)
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// zipArchive returns a zip archive holding an entry of each size, filled
+// with zeros so it compresses well.
+func zipArchive(t *testing.T, sizes ...int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, size := range sizes {
+		w, err := zw.Create(strings.Repeat("f", i+1) + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipArchive(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckArchive(t *testing.T) {
+	limits := UploadLimits{ArchiveMaxEntries: 3, ArchiveMaxSize: 8 << 20, ArchiveMaxRatio: 100}
+	tests := []struct {
+		name    string
+		limits  UploadLimits
+		content []byte
+		wantErr bool
+	}{
+		{"small zip", limits, zipArchive(t, 10, 20), false},
+		{"too many entries", limits, zipArchive(t, 1, 1, 1, 1), true},
+		{"entries within limit", limits, zipArchive(t, 1, 1, 1), false},
+		{"expands too far", limits, zipArchive(t, 5<<20, 5<<20), true},
+		{"expands too many times", limits, zipArchive(t, 4<<20), true},
+		{"no limits", UploadLimits{}, zipArchive(t, 4<<20, 4<<20, 1, 1), false},
+		// A high ratio is fine while the content stays small.
+		{"small but compressible", limits, zipArchive(t, 512<<10), false},
+		{"gzip within limits", limits, gzipArchive(t, 1000), false},
+		{"gzip expands too far", UploadLimits{ArchiveMaxSize: 1 << 20}, gzipArchive(t, 2<<20), true},
+		{"gzip expands too many times", limits, gzipArchive(t, 4<<20), true},
+		{"corrupt zip", limits, append([]byte("PK\x03\x04"), make([]byte, 100)...), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mimetype.Detect(tt.content)
+			if !isArchive(m) {
+				t.Fatalf("content detected as %s, not an archive", m)
+			}
+			err := tt.limits.checkArchive(m, bytes.NewReader(tt.content), int64(len(tt.content)))
+			if tt.wantErr && !errors.Is(err, ErrUnsafeArchive) {
+				t.Errorf("checkArchive() error = %v, want ErrUnsafeArchive", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("checkArchive() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	tests := []struct {
+		size    int
+		limit   int64
+		wantErr error
+	}{
+		{0, 0, nil},
+		{10, 10, nil},
+		{10, 11, nil},
+		{11, 10, ErrDocumentTooLarge},
+		{100 << 10, 64 << 10, ErrDocumentTooLarge},
+	}
+	for _, tt := range tests {
+		n, err := io.Copy(io.Discard, &limitedReader{r: bytes.NewReader(make([]byte, tt.size)), n: tt.limit})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("reading %d bytes limited to %d: error = %v, want %v", tt.size, tt.limit, err, tt.wantErr)
+		}
+		if err == nil && n != int64(tt.size) {
+			t.Errorf("read %d of %d bytes", n, tt.size)
+		}
+		if n > tt.limit+1 {
+			t.Errorf("read %d bytes past a limit of %d", n, tt.limit)
+		}
+	}
+}
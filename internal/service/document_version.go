@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/policy"
+)
+
+var (
+	// ErrVersionConflict is returned when replacing a document version that
+	// already has a newer one.
+	ErrVersionConflict = errors.New("document already has a newer version")
+	// ErrFileTypeMismatch is returned when a new version is uploaded under
+	// another file type than the document it replaces.
+	ErrFileTypeMismatch = errors.New("file type does not match the replaced document")
+	ErrInvalidReview    = errors.New("invalid review")
+)
+
+// replacement returns the current version a new upload replaces, and the
+// file type the upload belongs to. A zero fileTypeID takes the replaced
+// document's.
+func (s *ApplicationDocumentService) replacement(ctx context.Context, appID, replacesID, fileTypeID uint64) (*domain.ApplicationDocument, uint64, error) {
+	if replacesID == 0 {
+		return nil, fileTypeID, nil
+	}
+	previous, err := s.getDocument(ctx, appID, replacesID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !previous.IsCurrent {
+		return nil, 0, fmt.Errorf("%w: document %d was replaced", ErrVersionConflict, replacesID)
+	}
+	if fileTypeID != 0 && fileTypeID != previous.FileTypeID {
+		return nil, 0, fmt.Errorf("%w: document %d has file type %d", ErrFileTypeMismatch, replacesID, previous.FileTypeID)
+	}
+	return previous, previous.FileTypeID, nil
+}
+
+// Versions returns every version of the document docID belongs to, newest
+// first.
+func (s *ApplicationDocumentService) Versions(ctx context.Context, appID, docID uint64) ([]domain.ApplicationDocument, error) {
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionApplicationRead, nil); err != nil {
+		return nil, err
+	}
+	doc, err := s.getDocument(ctx, appID, docID)
+	if err != nil {
+		return nil, err
+	}
+	return s.docRepo.ListVersions(ctx, appID, doc.LineageID)
+}
+
+// Review accepts or rejects one document version. Only versions scanned
+// clean can be accepted; a rejection needs a reason code, which the
+// applicant sees along with the note.
+func (s *ApplicationDocumentService) Review(ctx context.Context, appID, docID uint64, in ReviewDocumentRequest) (*domain.ApplicationDocument, error) {
+	request := map[string]interface{}{
+		"documentId": int64(docID),
+		"decision":   in.Decision,
+		"reason":     in.Reason,
+	}
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionDocumentReview, request); err != nil {
+		return nil, err
+	}
+	// Reviews through API keys without a user are recorded without one.
+	var reviewer *uint64
+	if p := auth.FromContext(ctx); p != nil && p.UserID != 0 {
+		reviewer = &p.UserID
+	}
+	note := strings.TrimSpace(in.Note)
+	switch in.Decision {
+	case domain.ReviewAccepted:
+		if in.Reason != "" {
+			return nil, fmt.Errorf("%w: an acceptance takes no reason", ErrInvalidReview)
+		}
+	case domain.ReviewRejected:
+		if _, ok := domain.ReviewReasons[in.Reason]; !ok {
+			return nil, fmt.Errorf("%w: unknown reason %q", ErrInvalidReview, in.Reason)
+		}
+		if in.Reason == "other" && note == "" {
+			return nil, fmt.Errorf("%w: reason %q needs a note", ErrInvalidReview, in.Reason)
+		}
+	default:
+		return nil, fmt.Errorf("%w: decision must be %q or %q", ErrInvalidReview, domain.ReviewAccepted, domain.ReviewRejected)
+	}
+
+	doc, err := s.getDocument(ctx, appID, docID)
+	if err != nil {
+		return nil, err
+	}
+	if in.Decision == domain.ReviewAccepted {
+		if err := checkScanned(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.docRepo.SetReview(ctx, appID, docID, in.Decision, in.Reason, note, reviewer, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.getDocument(ctx, appID, docID)
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/pkg/config"
+	"gorm.io/gorm"
+)
+
+// versionedDocuments keeps document versions in a map the way the
+// repository chains them. stale makes CreateVersion find the replaced
+// version no longer current, as when another version landed first.
+type versionedDocuments struct {
+	repository.ApplicationDocumentRepository
+	docs   map[uint64]*domain.ApplicationDocument
+	nextID uint64
+	stale  bool
+}
+
+func (d *versionedDocuments) add(doc domain.ApplicationDocument) *domain.ApplicationDocument {
+	d.nextID++
+	doc.ID = d.nextID
+	d.docs[doc.ID] = &doc
+	return &doc
+}
+
+func (d *versionedDocuments) Create(_ context.Context, doc *domain.ApplicationDocument) error {
+	d.nextID++
+	doc.ID, doc.LineageID, doc.Version, doc.IsCurrent = d.nextID, d.nextID, 1, true
+	stored := *doc
+	d.docs[doc.ID] = &stored
+	return nil
+}
+
+func (d *versionedDocuments) CreateVersion(_ context.Context, doc, previous *domain.ApplicationDocument) error {
+	current := d.docs[previous.ID]
+	if d.stale || current == nil || !current.IsCurrent {
+		return repository.ErrNotCurrentVersion
+	}
+	current.IsCurrent = false
+	d.nextID++
+	doc.ID, doc.LineageID, doc.Version, doc.IsCurrent = d.nextID, previous.LineageID, previous.Version+1, true
+	stored := *doc
+	d.docs[doc.ID] = &stored
+	return nil
+}
+
+func (d *versionedDocuments) GetByID(_ context.Context, appID, id uint64) (*domain.ApplicationDocument, error) {
+	doc, ok := d.docs[id]
+	if !ok || doc.ApplicationID != appID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *doc
+	return &copied, nil
+}
+
+func (d *versionedDocuments) SetReview(_ context.Context, _, id uint64, status, reason, note string, by *uint64, at time.Time) error {
+	doc := d.docs[id]
+	doc.ReviewStatus, doc.ReviewReason, doc.ReviewNote, doc.ReviewedBy, doc.ReviewedAt = status, reason, note, by, &at
+	return nil
+}
+
+func newVersionService(docs *versionedDocuments, blobs *memoryBlobs) *ApplicationDocumentService {
+	return NewApplicationDocumentService(docs, uploadFileTypes{}, uploadApplications{}, &memoryBlobRepo{}, blobs, UploadLimits{}, nil, nil, nil)
+}
+
+func uploadVersion(ctx context.Context, s *ApplicationDocumentService, fileTypeID, replacesID uint64) (*domain.ApplicationDocument, error) {
+	return s.Upload(ctx, 1, UploadDocumentInput{
+		FileTypeID:  fileTypeID,
+		FileName:    "notes.txt",
+		ContentType: "text/plain",
+		Size:        int64(len(uploadContent)),
+		Content:     strings.NewReader(uploadContent),
+		ReplacesID:  replacesID,
+	})
+}
+
+func TestUploadVersionChain(t *testing.T) {
+	docs := &versionedDocuments{docs: map[uint64]*domain.ApplicationDocument{}}
+	s := newVersionService(docs, &memoryBlobs{blobs: map[string][]byte{}})
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user:7", UserID: 7, Roles: []string{auth.RoleApplicant}})
+
+	first, err := uploadVersion(ctx, s, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file type is taken from the replaced document.
+	second, err := uploadVersion(ctx, s, 0, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := uploadVersion(ctx, s, 3, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, doc := range []*domain.ApplicationDocument{first, second, third} {
+		stored := docs.docs[doc.ID]
+		if stored.LineageID != first.ID || stored.Version != i+1 || stored.FileTypeID != 3 {
+			t.Errorf("version %d = %+v", i+1, stored)
+		}
+		if stored.IsCurrent != (doc == third) {
+			t.Errorf("version %d current = %v", i+1, stored.IsCurrent)
+		}
+		if stored.ReviewStatus != domain.ReviewPending {
+			t.Errorf("version %d review status = %q", i+1, stored.ReviewStatus)
+		}
+	}
+}
+
+func TestUploadVersionRejects(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user:7", UserID: 7, Roles: []string{auth.RoleApplicant}})
+	tests := []struct {
+		name       string
+		replaces   func(first, second uint64) uint64
+		fileTypeID uint64
+		stale      bool
+		wantErr    error
+	}{
+		{"non-current version", func(first, _ uint64) uint64 { return first }, 0, false, ErrVersionConflict},
+		// Another version landed between the check and the insert.
+		{"replaced concurrently", func(_, second uint64) uint64 { return second }, 0, true, ErrVersionConflict},
+		{"other file type", func(_, second uint64) uint64 { return second }, 4, false, ErrFileTypeMismatch},
+		{"missing document", func(uint64, uint64) uint64 { return 99 }, 0, false, ErrDocumentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := &versionedDocuments{docs: map[uint64]*domain.ApplicationDocument{}}
+			blobs := &memoryBlobs{blobs: map[string][]byte{}}
+			s := newVersionService(docs, blobs)
+			first, err := uploadVersion(ctx, s, 3, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := uploadVersion(ctx, s, 3, first.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs.stale = tt.stale
+
+			_, err = uploadVersion(ctx, s, tt.fileTypeID, tt.replaces(first.ID, second.ID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Upload() error = %v, want %v", err, tt.wantErr)
+			}
+			// The versions have the same content, so they share one blob.
+			if len(docs.docs) != 2 || blobs.len() != 1 {
+				t.Errorf("%d versions and %d blobs after a rejected version, want 2 and 1", len(docs.docs), blobs.len())
+			}
+		})
+	}
+}
+
+func TestReviewDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		scan    string
+		req     ReviewDocumentRequest
+		wantErr error
+	}{
+		{"accept", domain.ScanClean, ReviewDocumentRequest{Decision: domain.ReviewAccepted}, nil},
+		{"accept with a reason", domain.ScanClean, ReviewDocumentRequest{Decision: domain.ReviewAccepted, Reason: "illegible"}, ErrInvalidReview},
+		{"accept unscanned", domain.ScanPending, ReviewDocumentRequest{Decision: domain.ReviewAccepted}, ErrDocumentQuarantined},
+		{"accept infected", domain.ScanInfected, ReviewDocumentRequest{Decision: domain.ReviewAccepted}, ErrDocumentQuarantined},
+		{"reject", domain.ScanClean, ReviewDocumentRequest{Decision: domain.ReviewRejected, Reason: "expired"}, nil},
+		// Content that was never scanned can still be rejected.
+		{"reject unscanned", domain.ScanPending, ReviewDocumentRequest{Decision: domain.ReviewRejected, Reason: "illegible"}, nil},
+		{"reject without a reason", domain.ScanClean, ReviewDocumentRequest{Decision: domain.ReviewRejected}, ErrInvalidReview},
+		{"reject with an unknown reason", domain.ScanClean, ReviewDocumentRequest{Decision: domain.ReviewRejected, Reason: "ugly"}, ErrInvalidReview},
+		{"other without a note", domain.ScanClean, ReviewDocumentRequest{Decision: domain.ReviewRejected, Reason: "other", Note: "  "}, ErrInvalidReview},
+		{"other with a note", domain.ScanClean, ReviewDocumentRequest{Decision: domain.ReviewRejected, Reason: "other", Note: "Wrong applicant"}, nil},
+		{"unknown decision", domain.ScanClean, ReviewDocumentRequest{Decision: "maybe"}, ErrInvalidReview},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := &versionedDocuments{docs: map[uint64]*domain.ApplicationDocument{}}
+			doc := docs.add(domain.ApplicationDocument{ApplicationID: 1, ScanStatus: tt.scan, ReviewStatus: domain.ReviewPending})
+			s := newVersionService(docs, &memoryBlobs{blobs: map[string][]byte{}})
+			ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user:9", UserID: 9, Roles: []string{auth.RoleReviewer}})
+
+			got, err := s.Review(ctx, 1, doc.ID, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Review() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if docs.docs[doc.ID].ReviewStatus != domain.ReviewPending {
+					t.Errorf("review recorded after an error")
+				}
+				return
+			}
+			if got.ReviewStatus != tt.req.Decision || got.ReviewedBy == nil || *got.ReviewedBy != 9 {
+				t.Errorf("reviewed document = %+v", got)
+			}
+		})
+	}
+}
+
+// The checklist is satisfied by accepted documents only; submission also
+// accepts documents awaiting review, but never rejected or infected ones.
+func TestBuildChecklistCountsReviews(t *testing.T) {
+	passport := &domain.ApplicationUploadedFileType{FileTypeName: "passport"}
+	payslip := &domain.ApplicationUploadedFileType{FileTypeName: "payslip"}
+	category := config.Category{Documents: []config.DocumentRequirement{
+		{FileType: "passport", Required: true},
+		{FileType: "payslip", Min: 2},
+	}}
+	doc := func(fileType *domain.ApplicationUploadedFileType, review, scan string) domain.ApplicationDocument {
+		return domain.ApplicationDocument{FileType: fileType, ReviewStatus: review, ScanStatus: scan}
+	}
+	tests := []struct {
+		name            string
+		docs            []domain.ApplicationDocument
+		wantCounts      [][3]int
+		wantComplete    bool
+		wantSubmittable bool
+	}{
+		{"all accepted", []domain.ApplicationDocument{
+			doc(passport, domain.ReviewAccepted, domain.ScanClean),
+			doc(payslip, domain.ReviewAccepted, domain.ScanClean),
+			doc(payslip, domain.ReviewAccepted, domain.ScanClean),
+		}, [][3]int{{1, 0, 0}, {2, 0, 0}}, true, true},
+		{"awaiting review", []domain.ApplicationDocument{
+			doc(passport, domain.ReviewPending, domain.ScanPending),
+			doc(payslip, domain.ReviewAccepted, domain.ScanClean),
+			doc(payslip, domain.ReviewPending, domain.ScanClean),
+		}, [][3]int{{0, 1, 0}, {1, 1, 0}}, false, true},
+		{"rejected", []domain.ApplicationDocument{
+			doc(passport, domain.ReviewRejected, domain.ScanClean),
+			doc(payslip, domain.ReviewAccepted, domain.ScanClean),
+			doc(payslip, domain.ReviewAccepted, domain.ScanClean),
+		}, [][3]int{{0, 0, 1}, {2, 0, 0}}, false, false},
+		{"infected", []domain.ApplicationDocument{
+			doc(passport, domain.ReviewPending, domain.ScanInfected),
+			doc(payslip, domain.ReviewAccepted, domain.ScanClean),
+			doc(payslip, domain.ReviewAccepted, domain.ScanClean),
+		}, [][3]int{{0, 0, 1}, {2, 0, 0}}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checklist := buildChecklist(&domain.Application{ID: 1}, category, tt.docs)
+			if checklist.Complete != tt.wantComplete || checklist.Submittable != tt.wantSubmittable {
+				t.Errorf("complete = %v, submittable = %v, want %v, %v", checklist.Complete, checklist.Submittable, tt.wantComplete, tt.wantSubmittable)
+			}
+			for i, item := range checklist.Items {
+				got := [3]int{item.Count, item.Pending, item.Rejected}
+				if got != tt.wantCounts[i] {
+					t.Errorf("%s: accepted, pending, rejected = %v, want %v", item.FileType, got, tt.wantCounts[i])
+				}
+				if item.Satisfied != (item.Count >= item.Min) {
+					t.Errorf("%s: satisfied = %v with %d accepted", item.FileType, item.Satisfied, item.Count)
+				}
+			}
+		})
+	}
+}
@@ -1,5 +1,12 @@
 package service
 
+import (
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+)
+
 type PaginationMeta struct {
 	Page       int   `json:"page"`
 	PageSize   int   `json:"pageSize"`
@@ -13,21 +20,136 @@ type ListResponse struct {
 }
 
 type CreateApplicationRequest struct {
-	Name   string `json:"name" binding:"required"`
-	UserID uint64 `json:"userId" binding:"required"`
+	Name string `json:"name" binding:"required"`
+	// Category selects the tenant's required-document checklist.
+	Category string `json:"category"`
+	// UserID lets staff create an application for another user. It is
+	// ignored for applicants, who always act as themselves.
+	UserID uint64 `json:"userId"`
 }
 
 type UpdateApplicationRequest struct {
 	Name        string `json:"name"`
 	Code        string `json:"code"`
 	Description string `json:"description"`
+	Category    string `json:"category"`
+	// AssigneeID assigns the application to a staff member; 0 clears the
+	// assignment. Applicants cannot set it.
+	AssigneeID *uint64 `json:"assigneeId"`
 }
 
 type AddStatusRequest struct {
 	Status string `json:"status" binding:"required"`
-	UserID uint64 `json:"userId" binding:"required"`
+	// UserID lets staff record a status for another user. It is ignored
+	// for applicants, who always act as themselves.
+	UserID uint64 `json:"userId"`
 }
 
 type AddFileTypeRequest struct {
-	FileTypeName string `json:"fileTypeName" binding:"required"`
+	// DocumentTypeID is the catalog entry the application takes documents of.
+	DocumentTypeID uint64 `json:"documentTypeId" binding:"required"`
+}
+
+// CreateDocumentTypeRequest adds an entry to the document type catalog.
+type CreateDocumentTypeRequest struct {
+	// Key identifies the type, e.g. "passport": lower-case letters, digits
+	// and underscores. It cannot be changed later.
+	Key string `json:"key" binding:"required"`
+	UpdateDocumentTypeRequest
+}
+
+// UpdateDocumentTypeRequest replaces everything about a document type but
+// its key.
+type UpdateDocumentTypeRequest struct {
+	DisplayName string `json:"displayName" binding:"required"`
+	Description string `json:"description"`
+	// AllowedMimeTypes lists the accepted content types, e.g. "application/pdf".
+	// Uploads are matched on their sniffed content, not on what the client declares.
+	AllowedMimeTypes []string `json:"allowedMimeTypes"`
+	// AllowedExtensions lists the accepted file name extensions, e.g. ".pdf".
+	AllowedExtensions []string `json:"allowedExtensions"`
+	// MaxSize is the largest accepted upload in bytes; 0 uses the server limit.
+	MaxSize int64 `json:"maxSize" binding:"min=0"`
+	// RetentionDays is how long documents of the type are kept once their
+	// application closed; 0 keeps them indefinitely.
+	RetentionDays int `json:"retentionDays" binding:"min=0"`
+}
+
+// ReviewDocumentRequest is a decision on one document version.
+type ReviewDocumentRequest struct {
+	Decision string `json:"decision" binding:"required" enums:"accepted,rejected"`
+	// Reason is the code of a rejection: illegible, expired,
+	// wrong_document, incomplete, mismatch or other.
+	Reason string `json:"reason"`
+	// Note is shown to the applicant; required for reason "other".
+	Note string `json:"note"`
+}
+
+// LegalHoldRequest places an application on legal hold.
+type LegalHoldRequest struct {
+	// Reason records why, e.g. the case the application is evidence in.
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CreateExportRequest selects the applications of an export job: those
+// listed in ApplicationIDs, or else those matching the filters.
+type CreateExportRequest struct {
+	ApplicationIDs []uint64   `json:"applicationIds"`
+	Q              string     `json:"q"`
+	AssigneeID     uint64     `json:"assigneeId"`
+	Category       string     `json:"category"`
+	From           *time.Time `json:"from"`
+	To             *time.Time `json:"to"`
+}
+
+type MergeApplicationsRequest struct {
+	SourceIDs []uint64 `json:"sourceIds" binding:"required,min=1"`
+	// Strategy resolves conflicting scalar fields: target, newest, oldest or longest.
+	Strategy string `json:"strategy"`
+	// Fields overrides Strategy per field (name, description).
+	Fields map[string]string `json:"fields"`
+}
+
+// ExplainPolicyRequest asks how the policies decide an action on an
+// application. Principal defaults to the caller.
+type ExplainPolicyRequest struct {
+	Action        string                 `json:"action" binding:"required"`
+	ApplicationID uint64                 `json:"applicationId" binding:"required"`
+	Request       map[string]interface{} `json:"request"`
+	Principal     *auth.Principal        `json:"principal"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresIn is a Go duration such as "720h". Empty means no expiry.
+	ExpiresIn string `json:"expiresIn"`
+}
+
+type RotateAPIKeyRequest struct {
+	// Overlap is how long the old key keeps working, as a Go duration.
+	// Defaults to 24h; "0s" revokes it immediately.
+	Overlap string `json:"overlap"`
+}
+
+// APIKeySecretResponse is returned when a key is created or rotated. The
+// secret is not stored and cannot be retrieved again.
+type APIKeySecretResponse struct {
+	APIKey *domain.APIKey `json:"apiKey"`
+	Secret string         `json:"secret"`
+}
+
+// CreateDownloadLinkRequest asks for a signed link to a document.
+type CreateDownloadLinkRequest struct {
+	// ExpiresIn is the lifetime of the link in seconds; 0 uses the default.
+	ExpiresIn int `json:"expiresIn" binding:"min=0"`
+	// SingleUse links work for one request only, so they cannot serve
+	// range requests after the first.
+	SingleUse bool `json:"singleUse"`
+}
+
+type DownloadLinkResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	SingleUse bool      `json:"singleUse"`
 }
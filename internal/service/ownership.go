@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserRequired = errors.New("userId is required")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// actingUserID returns the user an action is recorded for. Applicants always
+// act as themselves; staff may name another user and otherwise act as
+// themselves. requested is the user ID sent in the request body, if any.
+func actingUserID(ctx context.Context, requested uint64) (uint64, error) {
+	p := auth.FromContext(ctx)
+	userID := requested
+	if p.IsOwnerRestricted() || (userID == 0 && p != nil) {
+		userID = p.UserID
+	}
+	if userID == 0 {
+		return 0, ErrUserRequired
+	}
+	return userID, nil
+}
+
+// canAccess reports whether the caller may see the application. Applicants
+// only see their own.
+func canAccess(ctx context.Context, app *domain.Application) bool {
+	p := auth.FromContext(ctx)
+	return !p.IsOwnerRestricted() || p.Owns(app.UserID)
+}
+
+// ownerFilter returns the user listings of the caller are limited to, or 0
+// for staff. An applicant without a user ID owns nothing and is refused, so
+// it never gets an unfiltered listing.
+func ownerFilter(ctx context.Context) (uint64, error) {
+	p := auth.FromContext(ctx)
+	if !p.IsOwnerRestricted() {
+		return 0, nil
+	}
+	if p.UserID == 0 {
+		return 0, fmt.Errorf("%w: the credential does not name a user", ErrForbidden)
+	}
+	return p.UserID, nil
+}
+
+// requireApplication loads the application a nested resource belongs to. A
+// missing application and one the caller may not see both map to
+// ErrApplicationNotFound, so applicants cannot probe for other users' IDs.
+func requireApplication(ctx context.Context, appRepo repository.ApplicationRepository, id uint64) (*domain.Application, error) {
+	app, err := appRepo.FindByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !canAccess(ctx, app)) {
+		return nil, fmt.Errorf("%w: %d", ErrApplicationNotFound, id)
+	}
+	return app, err
+}
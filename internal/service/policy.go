@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+)
+
+// Authorizer asks the policy engine whether the caller may perform an action
+// on an application. Role checks in the router still run first; policies can
+// only narrow what the roles allow.
+type Authorizer struct {
+	engine     *policy.Engine
+	statusRepo repository.ApplicationStatusRepository
+}
+
+// NewAuthorizer returns an Authorizer for the engine. A nil engine allows
+// everything.
+func NewAuthorizer(engine *policy.Engine, statusRepo repository.ApplicationStatusRepository) *Authorizer {
+	return &Authorizer{engine: engine, statusRepo: statusRepo}
+}
+
+// Decide evaluates the policies for the principal performing action on app.
+// request holds the attributes the caller asked to change.
+func (a *Authorizer) Decide(ctx context.Context, principal *auth.Principal, action string, app *domain.Application, request map[string]interface{}) (policy.Decision, error) {
+	if a == nil || a.engine == nil {
+		return policy.Decision{Allowed: true, Reason: "no policies loaded", Rules: []policy.RuleResult{}}, nil
+	}
+	status := ""
+	latest, err := a.statusRepo.Latest(ctx, app.ID)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	if latest != nil {
+		status = latest.Status
+	}
+	return a.engine.Decide(policy.Input{
+		Principal: principal,
+		Action:    action,
+		Resource:  policy.ApplicationResource(app, status),
+		Request:   request,
+	}), nil
+}
+
+// authorize returns ErrForbidden unless the policies allow the caller to
+// perform action on app.
+func (a *Authorizer) authorize(ctx context.Context, action string, app *domain.Application, request map[string]interface{}) error {
+	decision, err := a.Decide(ctx, auth.FromContext(ctx), action, app, request)
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		return fmt.Errorf("%w: %s on application %d %s", ErrForbidden, action, app.ID, decision.Reason)
+	}
+	return nil
+}
+
+// requireAuthorized loads an application like requireApplication and checks
+// the policies for action on it.
+func (a *Authorizer) requireAuthorized(ctx context.Context, appRepo repository.ApplicationRepository, id uint64, action string, request map[string]interface{}) (*domain.Application, error) {
+	app, err := requireApplication(ctx, appRepo, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.authorize(ctx, action, app, request); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Explain evaluates the policies for an action on an application of the
+// caller's tenant and reports how each rule contributed. The principal
+// defaults to the caller.
+func (s *ApplicationService) Explain(ctx context.Context, req ExplainPolicyRequest) (policy.Decision, error) {
+	app, err := requireApplication(ctx, s.appRepo, req.ApplicationID)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	principal := req.Principal
+	if principal == nil {
+		principal = auth.FromContext(ctx)
+	}
+	return s.authz.Decide(ctx, principal, req.Action, app, req.Request)
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/auth"
+	"github.com/Naomejoy/app-service/internal/policy"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/Naomejoy/app-service/pkg/config"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrLegalHold is returned when deleting an application on legal hold or
+	// one of its documents.
+	ErrLegalHold = errors.New("application is on legal hold")
+	// ErrLegalHoldReason is returned when a legal hold is placed without a
+	// reason.
+	ErrLegalHoldReason = errors.New("legal hold needs a reason")
+)
+
+// Audit actions of retention. A purge leaves a tombstone describing what was
+// deleted, without its content or personal data.
+const (
+	AuditRetentionPurge    = "retention:purge"
+	AuditLegalHoldPlaced   = "retention:hold"
+	AuditLegalHoldReleased = "retention:release"
+)
+
+const (
+	// retentionBatchSize is how many applications or documents are looked
+	// at per query.
+	retentionBatchSize = 100
+	// retentionReportLimit bounds the applications and documents a report
+	// lists.
+	retentionReportLimit = 1000
+)
+
+// RetentionReport lists what retention purges at a point in time. Entries
+// on legal hold are listed but kept.
+type RetentionReport struct {
+	At           time.Time          `json:"at"`
+	Applications []ApplicationPurge `json:"applications"`
+	// Documents are purged ahead of their application under the retention
+	// of their document type. Documents of listed applications are left
+	// out.
+	Documents []DocumentPurge `json:"documents"`
+	// Truncated is set when more is due than the report lists.
+	Truncated bool `json:"truncated"`
+}
+
+// ApplicationPurge is an application past the retention of the terminal
+// status it closed with.
+type ApplicationPurge struct {
+	ApplicationID uint64    `json:"applicationId"`
+	Code          string    `json:"code"`
+	Category      string    `json:"category,omitempty"`
+	Status        string    `json:"status"`
+	ClosedAt      time.Time `json:"closedAt"`
+	RetentionDays int       `json:"retentionDays"`
+	// Documents and Size count every version of its documents.
+	Documents int64 `json:"documents"`
+	Size      int64 `json:"size"`
+	LegalHold bool  `json:"legalHold"`
+}
+
+// DocumentPurge is a document version past the retention of its document
+// type.
+type DocumentPurge struct {
+	DocumentID    uint64    `json:"documentId"`
+	ApplicationID uint64    `json:"applicationId"`
+	LineageID     uint64    `json:"lineageId"`
+	Version       int       `json:"version"`
+	DocumentType  string    `json:"documentType"`
+	RetentionDays int       `json:"retentionDays"`
+	Status        string    `json:"status"`
+	ClosedAt      time.Time `json:"closedAt"`
+	Size          int64     `json:"size"`
+	UploadedAt    time.Time `json:"uploadedAt"`
+	LegalHold     bool      `json:"legalHold"`
+}
+
+// RetentionService purges closed applications and their documents once
+// their retention has passed, and manages the legal holds that keep them.
+// Applications are kept for the retention of the terminal status they
+// closed with, documents for that of their document type, whichever ends
+// first.
+type RetentionService struct {
+	appRepo       repository.ApplicationRepository
+	docRepo       repository.ApplicationDocumentRepository
+	retentionRepo repository.RetentionRepository
+	audit         repository.AuditRepository
+	docs          *ApplicationDocumentService
+	tenants       map[string]config.TenantConfig
+	authz         *Authorizer
+}
+
+func NewRetentionService(appRepo repository.ApplicationRepository, docRepo repository.ApplicationDocumentRepository, retentionRepo repository.RetentionRepository, audit repository.AuditRepository, docs *ApplicationDocumentService, tenants map[string]config.TenantConfig, authz *Authorizer) *RetentionService {
+	return &RetentionService{appRepo: appRepo, docRepo: docRepo, retentionRepo: retentionRepo, audit: audit, docs: docs, tenants: tenants, authz: authz}
+}
+
+// Report lists what a purge at the given time would delete in the caller's
+// tenant, without deleting anything.
+func (s *RetentionService) Report(ctx context.Context, at time.Time) (*RetentionReport, error) {
+	cfg := tenantConfig(s.tenants, ctx)
+	report := &RetentionReport{At: at, Applications: []ApplicationPurge{}, Documents: []DocumentPurge{}}
+	purged := map[uint64]bool{}
+	err := s.expiredApplications(ctx, cfg, at, func(batch []ApplicationPurge) (bool, error) {
+		for _, app := range batch {
+			if len(report.Applications) == retentionReportLimit {
+				report.Truncated = true
+				return false, nil
+			}
+			report.Applications = append(report.Applications, app)
+			purged[app.ApplicationID] = true
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.expiredDocuments(ctx, cfg, at, func(batch []DocumentPurge) (bool, error) {
+		for _, doc := range batch {
+			if purged[doc.ApplicationID] {
+				continue
+			}
+			if len(report.Documents) == retentionReportLimit {
+				report.Truncated = true
+				return false, nil
+			}
+			report.Documents = append(report.Documents, doc)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Purge deletes the applications and documents of every tenant that are
+// past their retention at now and not on legal hold, leaving a tombstone
+// in the audit log for each.
+func (s *RetentionService) Purge(ctx context.Context, now time.Time) error {
+	var errs []error
+	for id, cfg := range s.tenants {
+		if err := s.purgeTenant(tenant.WithID(ctx, id), cfg, now); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %q: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *RetentionService) purgeTenant(ctx context.Context, cfg config.TenantConfig, now time.Time) error {
+	err := s.expiredApplications(ctx, cfg, now, func(batch []ApplicationPurge) (bool, error) {
+		for _, app := range batch {
+			if app.LegalHold {
+				continue
+			}
+			if err := s.purgeApplication(ctx, app); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.expiredDocuments(ctx, cfg, now, func(batch []DocumentPurge) (bool, error) {
+		return true, s.purgeDocuments(ctx, batch)
+	})
+}
+
+// purgeApplication deletes the application like ApplicationService.Delete
+// and records its tombstone.
+func (s *RetentionService) purgeApplication(ctx context.Context, app ApplicationPurge) error {
+	keys, err := s.docRepo.StorageKeysByApplication(ctx, app.ApplicationID)
+	if err != nil {
+		return err
+	}
+	err = s.appRepo.Delete(ctx, app.ApplicationID)
+	// Held or deleted since it was listed.
+	if errors.Is(err, repository.ErrLegalHold) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.docs.removeBlobs(ctx, keys)
+	return s.audit.Record(ctx, &domain.AuditEvent{
+		Action:        AuditRetentionPurge,
+		Actor:         systemActor,
+		ApplicationID: &app.ApplicationID,
+		Details: map[string]interface{}{
+			"resource":      "application",
+			"code":          app.Code,
+			"category":      app.Category,
+			"status":        app.Status,
+			"closedAt":      app.ClosedAt,
+			"retentionDays": app.RetentionDays,
+			"documents":     app.Documents,
+			"size":          app.Size,
+		},
+	})
+}
+
+// purgeDocuments deletes the document versions not on legal hold and
+// records a tombstone for each.
+func (s *RetentionService) purgeDocuments(ctx context.Context, batch []DocumentPurge) error {
+	byID := map[uint64]DocumentPurge{}
+	var ids []uint64
+	for _, doc := range batch {
+		if !doc.LegalHold {
+			byID[doc.DocumentID] = doc
+			ids = append(ids, doc.DocumentID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	deleted, err := s.docRepo.DeleteVersions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, version := range deleted {
+		for _, key := range version.PreviewKeys {
+			keys = append(keys, key)
+		}
+	}
+	s.docs.removeBlobs(ctx, keys)
+	for _, version := range deleted {
+		doc := byID[version.ID]
+		err := s.audit.Record(ctx, &domain.AuditEvent{
+			Action:        AuditRetentionPurge,
+			Actor:         systemActor,
+			ApplicationID: &doc.ApplicationID,
+			DocumentID:    &doc.DocumentID,
+			Details: map[string]interface{}{
+				"resource":      "document",
+				"lineageId":     doc.LineageID,
+				"version":       doc.Version,
+				"documentType":  doc.DocumentType,
+				"status":        doc.Status,
+				"closedAt":      doc.ClosedAt,
+				"retentionDays": doc.RetentionDays,
+				"size":          doc.Size,
+				"checksum":      version.Checksum,
+				"uploadedAt":    doc.UploadedAt,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredApplications passes the applications past their retention at the
+// given time to fn in batches, until fn returns false.
+func (s *RetentionService) expiredApplications(ctx context.Context, cfg config.TenantConfig, at time.Time, fn func([]ApplicationPurge) (bool, error)) error {
+	statuses := make([]string, 0, len(cfg.Retention.Statuses))
+	for status := range cfg.Retention.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		days := cfg.Retention.Days(status)
+		if days == 0 {
+			continue
+		}
+		var after uint64
+		for {
+			apps, err := s.retentionRepo.ListExpiredApplications(ctx, status, at.AddDate(0, 0, -days), after, retentionBatchSize)
+			if err != nil {
+				return err
+			}
+			batch := make([]ApplicationPurge, len(apps))
+			for i, app := range apps {
+				after = app.ApplicationID
+				batch[i] = ApplicationPurge{
+					ApplicationID: app.ApplicationID,
+					Code:          app.Code,
+					Category:      app.Category,
+					Status:        app.Status,
+					ClosedAt:      app.ClosedAt,
+					RetentionDays: days,
+					Documents:     app.Documents,
+					Size:          app.Size,
+					LegalHold:     app.LegalHold,
+				}
+			}
+			more, err := fn(batch)
+			if err != nil || !more {
+				return err
+			}
+			if len(apps) < retentionBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// expiredDocuments passes the document versions past the retention of their
+// document type at the given time to fn in batches, until fn returns false.
+func (s *RetentionService) expiredDocuments(ctx context.Context, cfg config.TenantConfig, at time.Time, fn func([]DocumentPurge) (bool, error)) error {
+	var after uint64
+	for {
+		docs, err := s.retentionRepo.ListExpiredDocuments(ctx, cfg.Workflow.TerminalStatuses(), at, after, retentionBatchSize)
+		if err != nil {
+			return err
+		}
+		batch := make([]DocumentPurge, len(docs))
+		for i, doc := range docs {
+			after = doc.ID
+			batch[i] = DocumentPurge{
+				DocumentID:    doc.ID,
+				ApplicationID: doc.ApplicationID,
+				LineageID:     doc.LineageID,
+				Version:       doc.Version,
+				DocumentType:  doc.DocumentType,
+				RetentionDays: doc.RetentionDays,
+				Status:        doc.Status,
+				ClosedAt:      doc.ClosedAt,
+				Size:          doc.Size,
+				UploadedAt:    doc.UploadedAt,
+				LegalHold:     doc.LegalHold,
+			}
+		}
+		more, err := fn(batch)
+		if err != nil || !more {
+			return err
+		}
+		if len(docs) < retentionBatchSize {
+			return nil
+		}
+	}
+}
+
+// PlaceLegalHold keeps the application and its documents from being purged
+// or deleted until the hold is released.
+func (s *RetentionService) PlaceLegalHold(ctx context.Context, appID uint64, reason string) (*domain.Application, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrLegalHoldReason
+	}
+	request := map[string]interface{}{"legalHold": true, "reason": reason}
+	if _, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionLegalHold, request); err != nil {
+		return nil, err
+	}
+	var by *uint64
+	if p := auth.FromContext(ctx); p != nil && p.UserID != 0 {
+		by = &p.UserID
+	}
+	now := time.Now()
+	if err := s.appRepo.SetLegalHold(ctx, appID, true, reason, by, &now); err != nil {
+		return nil, err
+	}
+	if err := s.recordHold(ctx, AuditLegalHoldPlaced, appID, reason); err != nil {
+		return nil, err
+	}
+	return s.appRepo.GetByID(ctx, appID)
+}
+
+// ReleaseLegalHold lets retention purge the application again.
+func (s *RetentionService) ReleaseLegalHold(ctx context.Context, appID uint64) (*domain.Application, error) {
+	request := map[string]interface{}{"legalHold": false}
+	app, err := s.authz.requireAuthorized(ctx, s.appRepo, appID, policy.ActionLegalHold, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.appRepo.SetLegalHold(ctx, appID, false, "", nil, nil); err != nil {
+		return nil, err
+	}
+	if err := s.recordHold(ctx, AuditLegalHoldReleased, appID, app.LegalHoldReason); err != nil {
+		return nil, err
+	}
+	return s.appRepo.GetByID(ctx, appID)
+}
+
+func (s *RetentionService) recordHold(ctx context.Context, action string, appID uint64, reason string) error {
+	return s.audit.Record(ctx, &domain.AuditEvent{
+		Action:        action,
+		Actor:         principalSubject(ctx),
+		ApplicationID: &appID,
+		Details:       map[string]interface{}{"reason": reason},
+	})
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Naomejoy/app-service/domain"
+	"github.com/Naomejoy/app-service/internal/repository"
+	"github.com/Naomejoy/app-service/pkg/config"
+)
+
+// closedApplications answers retention queries from a list, the way the
+// retention queries select from the applications table.
+type closedApplications struct {
+	apps []repository.ExpiredApplication
+	docs []repository.ExpiredDocument
+	// closed records the statuses documents were selected by.
+	closed []string
+}
+
+func (r *closedApplications) ListExpiredApplications(_ context.Context, status string, closedBefore time.Time, after uint64, limit int) ([]repository.ExpiredApplication, error) {
+	var apps []repository.ExpiredApplication
+	for _, app := range r.apps {
+		if app.Status == status && app.ClosedAt.Before(closedBefore) && app.ApplicationID > after && len(apps) < limit {
+			apps = append(apps, app)
+		}
+	}
+	return apps, nil
+}
+
+func (r *closedApplications) ListExpiredDocuments(_ context.Context, closed []string, now time.Time, after uint64, limit int) ([]repository.ExpiredDocument, error) {
+	r.closed = closed
+	var docs []repository.ExpiredDocument
+	for _, doc := range r.docs {
+		if doc.ClosedAt.AddDate(0, 0, doc.RetentionDays).Before(now) && doc.ID > after && len(docs) < limit {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// purgedApplications records what retention deletes.
+type purgedApplications struct {
+	repository.ApplicationRepository
+	deleted []uint64
+}
+
+func (r *purgedApplications) Delete(_ context.Context, id uint64) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+type purgedDocuments struct {
+	repository.ApplicationDocumentRepository
+	deleted []uint64
+}
+
+func (r *purgedDocuments) StorageKeysByApplication(context.Context, uint64) ([]string, error) {
+	return nil, nil
+}
+
+func (r *purgedDocuments) DeleteVersions(_ context.Context, ids []uint64) ([]domain.ApplicationDocument, error) {
+	var deleted []domain.ApplicationDocument
+	for _, id := range ids {
+		r.deleted = append(r.deleted, id)
+		deleted = append(deleted, domain.ApplicationDocument{ID: id})
+	}
+	return deleted, nil
+}
+
+func newTestRetention(repo *closedApplications) (*RetentionService, *purgedApplications, *purgedDocuments, *recordedAudit) {
+	apps, docs, audit := &purgedApplications{}, &purgedDocuments{}, &recordedAudit{}
+	tenants := map[string]config.TenantConfig{config.DefaultTenantID: {
+		ID:        config.DefaultTenantID,
+		Retention: config.Retention{Statuses: map[string]int{"rejected": 30, "approved": 365, "withdrawn": 0}},
+	}}
+	documents := &ApplicationDocumentService{blobs: &memoryBlobs{blobs: map[string][]byte{}}}
+	return NewRetentionService(apps, docs, repo, audit, documents, tenants, nil), apps, docs, audit
+}
+
+func TestRetentionReport(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	repo := &closedApplications{
+		apps: []repository.ExpiredApplication{
+			{ApplicationID: 1, Status: "rejected", ClosedAt: now.AddDate(0, 0, -31)},
+			// Within the retention of its status.
+			{ApplicationID: 2, Status: "rejected", ClosedAt: now.AddDate(0, 0, -29)},
+			{ApplicationID: 3, Status: "approved", ClosedAt: now.AddDate(0, 0, -29)},
+			{ApplicationID: 4, Status: "approved", ClosedAt: now.AddDate(-2, 0, 0), LegalHold: true},
+			// Withdrawn applications are kept indefinitely.
+			{ApplicationID: 5, Status: "withdrawn", ClosedAt: now.AddDate(-10, 0, 0)},
+		},
+		docs: []repository.ExpiredDocument{
+			{ID: 10, ApplicationID: 1, RetentionDays: 7, ClosedAt: now.AddDate(0, 0, -31)},
+			{ID: 11, ApplicationID: 3, RetentionDays: 7, ClosedAt: now.AddDate(0, 0, -29)},
+			{ID: 12, ApplicationID: 3, RetentionDays: 60, ClosedAt: now.AddDate(0, 0, -29)},
+		},
+	}
+	s, _, _, _ := newTestRetention(repo)
+
+	report, err := s.Report(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apps []uint64
+	for _, app := range report.Applications {
+		apps = append(apps, app.ApplicationID)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i] < apps[j] })
+	if len(apps) != 2 || apps[0] != 1 || apps[1] != 4 {
+		t.Errorf("applications due = %v, want [1 4]", apps)
+	}
+	for _, app := range report.Applications {
+		if app.ApplicationID == 4 && (!app.LegalHold || app.RetentionDays != 365) {
+			t.Errorf("held application reported as %+v", app)
+		}
+	}
+	// Documents of applications due as a whole are not listed again.
+	if len(report.Documents) != 1 || report.Documents[0].DocumentID != 11 {
+		t.Errorf("documents due = %+v, want only document 11", report.Documents)
+	}
+	if report.Truncated {
+		t.Error("report is truncated")
+	}
+	if strings.Join(repo.closed, ",") != "approved,rejected,withdrawn" {
+		t.Errorf("documents selected by statuses %v, want the default terminal statuses", repo.closed)
+	}
+}
+
+func TestRetentionReportTruncated(t *testing.T) {
+	now := time.Now()
+	repo := &closedApplications{}
+	for i := 1; i <= retentionReportLimit+1; i++ {
+		repo.apps = append(repo.apps, repository.ExpiredApplication{ApplicationID: uint64(i), Status: "rejected", ClosedAt: now.AddDate(-1, 0, 0)})
+	}
+	s, _, _, _ := newTestRetention(repo)
+
+	report, err := s.Report(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Applications) != retentionReportLimit || !report.Truncated {
+		t.Errorf("report lists %d applications, truncated %v", len(report.Applications), report.Truncated)
+	}
+}
+
+func TestRetentionPurgeKeepsLegalHolds(t *testing.T) {
+	now := time.Now()
+	repo := &closedApplications{
+		apps: []repository.ExpiredApplication{
+			{ApplicationID: 1, Status: "rejected", ClosedAt: now.AddDate(-1, 0, 0)},
+			{ApplicationID: 2, Status: "rejected", ClosedAt: now.AddDate(-1, 0, 0), LegalHold: true},
+		},
+		docs: []repository.ExpiredDocument{
+			{ID: 10, ApplicationID: 3, RetentionDays: 7, ClosedAt: now.AddDate(0, 0, -8)},
+			{ID: 11, ApplicationID: 4, RetentionDays: 7, ClosedAt: now.AddDate(0, 0, -8), LegalHold: true},
+		},
+	}
+	s, apps, docs, audit := newTestRetention(repo)
+
+	if err := s.Purge(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if len(apps.deleted) != 1 || apps.deleted[0] != 1 {
+		t.Errorf("deleted applications %v, want [1]", apps.deleted)
+	}
+	if len(docs.deleted) != 1 || docs.deleted[0] != 10 {
+		t.Errorf("deleted documents %v, want [10]", docs.deleted)
+	}
+	if len(audit.events) != 2 {
+		t.Fatalf("recorded %d tombstones, want 2", len(audit.events))
+	}
+	for _, event := range audit.events {
+		if event.Action != AuditRetentionPurge || event.Actor != systemActor {
+			t.Errorf("tombstone %+v", event)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+
+	"github.com/Naomejoy/app-service/internal/tenant"
+	"github.com/Naomejoy/app-service/pkg/config"
+)
+
+// tenantConfig returns the configuration of the tenant carried by ctx. The
+// tenant middleware rejects unknown tenants, so the zero value is only seen
+// outside a request.
+func tenantConfig(tenants map[string]config.TenantConfig, ctx context.Context) config.TenantConfig {
+	return tenants[tenant.FromContext(ctx)]
+}
+
+// generateCode returns a new application code with the tenant's prefix.
+func generateCode(prefix string) (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + "-" + base32.StdEncoding.EncodeToString(buf), nil
+}
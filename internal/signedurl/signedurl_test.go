@@ -0,0 +1,100 @@
+package signedurl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSigner(t *testing.T) {
+	if _, err := NewSigner([]byte("short")); err == nil {
+		t.Error("NewSigner() accepted a short key")
+	}
+	if _, err := NewSigner([]byte(strings.Repeat("k", 32))); err != nil {
+		t.Errorf("NewSigner() error = %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signer, err := NewSigner([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSigner([]byte(strings.Repeat("o", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_800_000_000, 0)
+	link := Link{
+		TenantID:      "acme",
+		ApplicationID: 12,
+		DocumentID:    34,
+		Subject:       "user:7",
+		ExpiresAt:     now.Add(time.Minute),
+		Nonce:         "n1",
+	}
+
+	tests := []struct {
+		name       string
+		signer     *Signer
+		documentID uint64
+		change     func(params map[string]string)
+		now        time.Time
+		wantErr    error
+	}{
+		{"valid", signer, 34, nil, now, nil},
+		{"at expiry", signer, 34, nil, link.ExpiresAt, nil},
+		{"expired", signer, 34, nil, link.ExpiresAt.Add(time.Second), ErrExpired},
+		{"other document", signer, 35, nil, now, ErrInvalidSignature},
+		{"other key", other, 34, nil, now, ErrInvalidSignature},
+		{"tenant changed", signer, 34, func(p map[string]string) { p[paramTenant] = "globex" }, now, ErrInvalidSignature},
+		{"application changed", signer, 34, func(p map[string]string) { p[paramApp] = "13" }, now, ErrInvalidSignature},
+		{"subject changed", signer, 34, func(p map[string]string) { p[paramSubject] = "user:8" }, now, ErrInvalidSignature},
+		{"expiry extended", signer, 34, func(p map[string]string) { p[paramExpires] = "1900000000" }, now, ErrInvalidSignature},
+		{"nonce removed", signer, 34, func(p map[string]string) { delete(p, paramNonce) }, now, ErrInvalidSignature},
+		{"signature removed", signer, 34, func(p map[string]string) { delete(p, paramSig) }, now, ErrInvalidSignature},
+		{"malformed application", signer, 34, func(p map[string]string) { p[paramApp] = "x" }, now, ErrInvalidSignature},
+		{"malformed expiry", signer, 34, func(p map[string]string) { p[paramExpires] = "" }, now, ErrInvalidSignature},
+		// Field boundaries are part of the signature.
+		{"fields shifted", signer, 34, func(p map[string]string) {
+			p[paramTenant], p[paramSubject] = "acmeu", "ser:7"
+		}, now, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := signer.Sign(link)
+			if tt.change != nil {
+				tt.change(params)
+			}
+			got, err := tt.signer.Verify(tt.documentID, func(name string) string { return params[name] }, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != link {
+				t.Errorf("Verify() = %+v, want %+v", got, link)
+			}
+		})
+	}
+}
+
+func TestSignWithoutNonce(t *testing.T) {
+	signer, err := NewSigner([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_800_000_000, 0)
+	link := Link{TenantID: "acme", ApplicationID: 1, DocumentID: 2, ExpiresAt: now.Add(time.Hour)}
+
+	params := signer.Sign(link)
+	if _, ok := params[paramNonce]; ok {
+		t.Errorf("link without a nonce carries %s=%q", paramNonce, params[paramNonce])
+	}
+	if _, err := signer.Verify(2, func(name string) string { return params[name] }, now); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	params[paramNonce] = "n1"
+	if _, err := signer.Verify(2, func(name string) string { return params[name] }, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() with an added nonce error = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Naomejoy/app-service/domain"
+)
+
+// ErrDecrypt is returned when an encrypted blob or its data key cannot be
+// decrypted: the master key is missing, or the blob was tampered with.
+var ErrDecrypt = errors.New("blob cannot be decrypted")
+
+const (
+	// encryptedMagic starts every encrypted blob, followed by the ID of its
+	// data key.
+	encryptedMagic = "ENC1"
+	dataKeyIDSize  = 16
+	headerSize     = len(encryptedMagic) + dataKeyIDSize
+	// Blobs are encrypted in segments, each sealed on its own, so a range
+	// can be read without decrypting everything before it.
+	segmentSize = 64 << 10
+	tagSize     = 16
+)
+
+// DataKeyStore keeps the wrapped data keys of encrypted blobs.
+type DataKeyStore interface {
+	Create(ctx context.Context, key *domain.BlobDataKey) error
+	GetByID(ctx context.Context, id string) (*domain.BlobDataKey, error)
+	Delete(ctx context.Context, id string) error
+	DeleteByStorageKey(ctx context.Context, storageKey string) error
+}
+
+// EncryptedStore encrypts blobs before they reach the underlying store.
+// Every blob gets its own AES-256-GCM data key, which is kept wrapped by a
+// master key of the keyring in a DataKeyStore. Blobs stored before
+// encryption was enabled are read as they are.
+type EncryptedStore struct {
+	store BlobStore
+	keys  DataKeyStore
+	ring  *Keyring
+}
+
+func NewEncryptedStore(store BlobStore, keys DataKeyStore, ring *Keyring) *EncryptedStore {
+	return &EncryptedStore{store: store, keys: keys, ring: ring}
+}
+
+func (s *EncryptedStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	id := make([]byte, dataKeyIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	dataKey := make([]byte, masterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+	masterKeyID, wrapped, err := s.ring.Wrap(dataKey, id)
+	if err != nil {
+		return err
+	}
+	// The data key is recorded first, so no blob is ever stored that
+	// cannot be decrypted.
+	record := &domain.BlobDataKey{
+		ID:          hex.EncodeToString(id),
+		StorageKey:  key,
+		MasterKeyID: masterKeyID,
+		WrappedKey:  wrapped,
+	}
+	if err := s.keys.Create(ctx, record); err != nil {
+		return err
+	}
+	header := append([]byte(encryptedMagic), id...)
+	body := io.MultiReader(bytes.NewReader(header), &encryptReader{src: bufio.NewReader(r), aead: aead})
+	if size >= 0 {
+		size = encryptedSize(size)
+	}
+	if err := s.store.Put(ctx, key, body, size, contentType); err != nil {
+		if delErr := s.keys.Delete(context.WithoutCancel(ctx), record.ID); delErr != nil {
+			return errors.Join(err, delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *EncryptedStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	src, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(src, header); err != nil || string(header[:len(encryptedMagic)]) != encryptedMagic {
+		// Stored before encryption was enabled.
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			src.Close()
+			return nil, err
+		}
+		return src, nil
+	}
+	content, err := s.open(ctx, key, src, header[len(encryptedMagic):])
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return content, nil
+}
+
+func (s *EncryptedStore) open(ctx context.Context, key string, src io.ReadSeekCloser, id []byte) (io.ReadSeekCloser, error) {
+	record, err := s.keys.GetByID(ctx, hex.EncodeToString(id))
+	if err != nil {
+		return nil, fmt.Errorf("data key of blob %q: %w", key, err)
+	}
+	// The header only names a data key; without this check the content of
+	// another blob copied under key would decrypt as if it belonged there.
+	if record.StorageKey != key {
+		return nil, fmt.Errorf("%w: blob %q carries the data key of blob %q", ErrDecrypt, key, record.StorageKey)
+	}
+	dataKey, err := s.ring.Unwrap(record.MasterKeyID, record.WrappedKey, id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := end - int64(headerSize)
+	segments := (ciphertext + segmentSize + tagSize - 1) / (segmentSize + tagSize)
+	if ciphertext < tagSize || ciphertext-(segments-1)*(segmentSize+tagSize) < tagSize {
+		return nil, fmt.Errorf("%w: blob %q is truncated", ErrDecrypt, key)
+	}
+	r := &decryptReader{
+		src:        src,
+		aead:       aead,
+		ciphertext: ciphertext,
+		segments:   segments,
+		size:       ciphertext - segments*tagSize,
+		loaded:     -1,
+	}
+	// An empty blob is never read, so check it is intact now.
+	if r.size == 0 {
+		if err := r.load(0); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Delete removes the blob and then its data keys.
+func (s *EncryptedStore) Delete(ctx context.Context, key string) error {
+	if err := s.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	return s.keys.DeleteByStorageKey(ctx, key)
+}
+
+// encryptedSize returns the size of the encrypted blob of size bytes.
+func encryptedSize(size int64) int64 {
+	segments := max(1, (size+segmentSize-1)/segmentSize)
+	return int64(headerSize) + size + segments*tagSize
+}
+
+// segmentNonce is the nonce of a segment. The last segment is marked, so a
+// blob cut off at a segment boundary does not decrypt. Each data key
+// encrypts one blob, so nonces never repeat.
+func segmentNonce(aead cipher.AEAD, segment int64, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce, uint64(segment))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptReader encrypts what it reads from src segment by segment.
+type encryptReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	segment int64
+	plain   []byte
+	out     []byte
+	done    bool
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// seal encrypts the next segment, looking one byte ahead to tell whether it
+// is the last.
+func (r *encryptReader) seal() error {
+	if r.plain == nil {
+		r.plain = make([]byte, segmentSize)
+	}
+	n, err := io.ReadFull(r.src, r.plain)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		r.done = true
+	case err != nil:
+		return err
+	default:
+		_, err := r.src.Peek(1)
+		if err == io.EOF {
+			r.done = true
+		} else if err != nil {
+			return err
+		}
+	}
+	r.out = r.aead.Seal(r.out[:0], segmentNonce(r.aead, r.segment, r.done), r.plain[:n], nil)
+	r.segment++
+	return nil
+}
+
+// decryptReader decrypts an encrypted blob, one segment at a time.
+type decryptReader struct {
+	src        io.ReadSeekCloser
+	aead       cipher.AEAD
+	ciphertext int64
+	segments   int64
+	size       int64
+	pos        int64
+	loaded     int64
+	sealed     []byte
+	plain      []byte
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	segment := r.pos / segmentSize
+	if segment != r.loaded {
+		if err := r.load(segment); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain[r.pos-segment*segmentSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *decryptReader) load(segment int64) error {
+	offset := segment * (segmentSize + tagSize)
+	length := min(segmentSize+tagSize, r.ciphertext-offset)
+	if _, err := r.src.Seek(int64(headerSize)+offset, io.SeekStart); err != nil {
+		return err
+	}
+	if r.sealed == nil {
+		r.sealed = make([]byte, segmentSize+tagSize)
+	}
+	if _, err := io.ReadFull(r.src, r.sealed[:length]); err != nil {
+		return err
+	}
+	plain, err := r.aead.Open(r.plain[:0], segmentNonce(r.aead, segment, segment == r.segments-1), r.sealed[:length], nil)
+	if err != nil {
+		r.loaded = -1
+		return fmt.Errorf("%w: segment %d does not authenticate", ErrDecrypt, segment)
+	}
+	r.plain = plain
+	r.loaded = segment
+	return nil
+}
+
+func (r *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.src.Close()
+}
@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/Naomejoy/app-service/domain"
+)
+
+// memoryStore is a BlobStore in memory.
+type memoryStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (m *memoryStore) Put(_ context.Context, key string, r io.Reader, size int64, _ string) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && int64(len(b)) != size {
+		return fmt.Errorf("read %d bytes, announced %d", len(b), size)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = b
+	return nil
+}
+
+func (m *memoryStore) Get(_ context.Context, key string) (io.ReadSeekCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return nopCloser{bytes.NewReader(b)}, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+type nopCloser struct{ *bytes.Reader }
+
+func (nopCloser) Close() error { return nil }
+
+// memoryDataKeys is a DataKeyStore in memory.
+type memoryDataKeys struct {
+	mu   sync.Mutex
+	keys map[string]domain.BlobDataKey
+}
+
+func (m *memoryDataKeys) Create(_ context.Context, key *domain.BlobDataKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key.ID] = *key
+	return nil
+}
+
+func (m *memoryDataKeys) GetByID(_ context.Context, id string) (*domain.BlobDataKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &key, nil
+}
+
+func (m *memoryDataKeys) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, id)
+	return nil
+}
+
+func (m *memoryDataKeys) DeleteByStorageKey(_ context.Context, storageKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, key := range m.keys {
+		if key.StorageKey == storageKey {
+			delete(m.keys, id)
+		}
+	}
+	return nil
+}
+
+func masterKeySpec(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+func newTestEncryptedStore(t *testing.T) (*EncryptedStore, *memoryStore, *memoryDataKeys) {
+	t.Helper()
+	ring, err := LoadKeyring(masterKeySpec(t, "k1"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryStore{blobs: map[string][]byte{}}
+	keys := &memoryDataKeys{keys: map[string]domain.BlobDataKey{}}
+	return NewEncryptedStore(store, keys, ring), store, keys
+}
+
+func randomContent(t *testing.T, size int) []byte {
+	t.Helper()
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func readBlob(s BlobStore, key string) ([]byte, error) {
+	r, err := s.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, segmentSize - 1, segmentSize, segmentSize + 1, 3*segmentSize + 5} {
+		for _, known := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%d bytes, size known %v", size, known), func(t *testing.T) {
+				s, store, _ := newTestEncryptedStore(t)
+				content := randomContent(t, size)
+				announced := int64(size)
+				if !known {
+					announced = -1
+				}
+				if err := s.Put(context.Background(), "blob", bytes.NewReader(content), announced, "application/octet-stream"); err != nil {
+					t.Fatal(err)
+				}
+				if got := int64(len(store.blobs["blob"])); got != encryptedSize(int64(size)) {
+					t.Errorf("stored %d bytes, encryptedSize() = %d", got, encryptedSize(int64(size)))
+				}
+				// A few random bytes may turn up in the ciphertext by chance.
+				if size >= 16 && bytes.Contains(store.blobs["blob"], content) {
+					t.Error("content is stored in the clear")
+				}
+				got, err := readBlob(s, "blob")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("read %d bytes that differ from the %d stored", len(got), size)
+				}
+			})
+		}
+	}
+}
+
+func TestEncryptedStoreRange(t *testing.T) {
+	s, _, _ := newTestEncryptedStore(t)
+	content := randomContent(t, 2*segmentSize+100)
+	if err := s.Put(context.Background(), "blob", bytes.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Get(context.Background(), "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		pos    int64
+		length int
+	}{
+		{"start", 0, io.SeekStart, 0, 10},
+		{"across a segment boundary", segmentSize - 10, io.SeekStart, segmentSize - 10, 20},
+		{"second segment", segmentSize, io.SeekStart, segmentSize, 64},
+		{"from the end", -50, io.SeekEnd, int64(len(content)) - 50, 50},
+		{"back into the first segment", 5, io.SeekStart, 5, segmentSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := r.Seek(tt.offset, tt.whence)
+			if err != nil || pos != tt.pos {
+				t.Fatalf("Seek() = %d, %v, want %d", pos, err, tt.pos)
+			}
+			got := make([]byte, tt.length)
+			if _, err := io.ReadFull(r, got); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content[tt.pos:tt.pos+int64(tt.length)]) {
+				t.Error("read content differs")
+			}
+		})
+	}
+
+	if _, err := r.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("Read() at the end = %d, %v, want io.EOF", n, err)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek() to a negative position succeeded")
+	}
+}
+
+func TestEncryptedStoreDetectsTampering(t *testing.T) {
+	content := randomContent(t, 2*segmentSize)
+	tests := []struct {
+		name   string
+		mutate func(stored []byte) []byte
+	}{
+		{"cut at a segment boundary", func(b []byte) []byte { return b[:headerSize+segmentSize+tagSize] }},
+		{"cut inside the last tag", func(b []byte) []byte { return b[:len(b)-3] }},
+		{"cut into the second segment", func(b []byte) []byte { return b[:headerSize+segmentSize+tagSize+100] }},
+		{"flipped bit", func(b []byte) []byte { b[headerSize+segmentSize+50] ^= 1; return b }},
+		{"segments swapped", func(b []byte) []byte {
+			first := append([]byte{}, b[headerSize:headerSize+segmentSize+tagSize]...)
+			copy(b[headerSize:], b[headerSize+segmentSize+tagSize:])
+			copy(b[headerSize+segmentSize+tagSize:], first)
+			return b
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store, _ := newTestEncryptedStore(t)
+			if err := s.Put(context.Background(), "blob", bytes.NewReader(content), int64(len(content)), ""); err != nil {
+				t.Fatal(err)
+			}
+			store.blobs["blob"] = tt.mutate(store.blobs["blob"])
+			if _, err := readBlob(s, "blob"); !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("reading a tampered blob: error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedStoreEmptyBlobTruncated(t *testing.T) {
+	s, store, _ := newTestEncryptedStore(t)
+	if err := s.Put(context.Background(), "blob", bytes.NewReader(nil), 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	stored := store.blobs["blob"]
+	stored[len(stored)-1] ^= 1
+	if _, err := s.Get(context.Background(), "blob"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Get() error = %v, want ErrDecrypt", err)
+	}
+}
+
+// Content copied from another blob decrypts with that blob's data key, so
+// it must be refused under a key it was not stored under.
+func TestEncryptedStoreRejectsMovedBlob(t *testing.T) {
+	s, store, _ := newTestEncryptedStore(t)
+	secret := []byte("the other tenant's passport")
+	if err := s.Put(context.Background(), "globex/sha256/a", bytes.NewReader(secret), int64(len(secret)), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "acme/sha256/b", bytes.NewReader([]byte("mine")), 4, ""); err != nil {
+		t.Fatal(err)
+	}
+	store.blobs["acme/sha256/b"] = store.blobs["globex/sha256/a"]
+
+	if _, err := readBlob(s, "acme/sha256/b"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("reading a moved blob: error = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestEncryptedStorePlaintextBlob(t *testing.T) {
+	s, store, _ := newTestEncryptedStore(t)
+	for _, content := range []string{"", "EN", "stored before encryption was enabled"} {
+		store.blobs["old"] = []byte(content)
+		got, err := readBlob(s, "old")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("read %q, want %q", got, content)
+		}
+	}
+}
+
+func TestEncryptedStoreDelete(t *testing.T) {
+	s, store, keys := newTestEncryptedStore(t)
+	if err := s.Put(context.Background(), "blob", bytes.NewReader([]byte("x")), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "other", bytes.NewReader([]byte("y")), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(context.Background(), "blob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.blobs["blob"]; ok {
+		t.Error("blob was not deleted")
+	}
+	if len(keys.keys) != 1 {
+		t.Errorf("%d data keys left, want only the other blob's", len(keys.keys))
+	}
+}
+
+// failingStore fails every Put after reading some of the blob.
+type failingStore struct{ memoryStore }
+
+func (f *failingStore) Put(_ context.Context, _ string, r io.Reader, _ int64, _ string) error {
+	io.CopyN(io.Discard, r, 10)
+	return errors.New("storage unavailable")
+}
+
+func TestEncryptedStorePutFailureRemovesDataKey(t *testing.T) {
+	ring, err := LoadKeyring(masterKeySpec(t, "k1"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &memoryDataKeys{keys: map[string]domain.BlobDataKey{}}
+	s := NewEncryptedStore(&failingStore{}, keys, ring)
+	if err := s.Put(context.Background(), "blob", bytes.NewReader([]byte("content")), 7, ""); err == nil {
+		t.Fatal("Put() succeeded on a failing store")
+	}
+	if len(keys.keys) != 0 {
+		t.Errorf("%d data keys left behind", len(keys.keys))
+	}
+}
+
+// After a rotation, blobs written with the old master key are still read,
+// both before and after their data keys are rewrapped.
+func TestEncryptedStoreRotation(t *testing.T) {
+	oldSpec, newSpec := masterKeySpec(t, "2024"), masterKeySpec(t, "2025")
+	oldRing, err := LoadKeyring(oldSpec, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryStore{blobs: map[string][]byte{}}
+	keys := &memoryDataKeys{keys: map[string]domain.BlobDataKey{}}
+	content := randomContent(t, segmentSize+1)
+	if err := NewEncryptedStore(store, keys, oldRing).Put(context.Background(), "blob", bytes.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := LoadKeyring(oldSpec+","+newSpec, "", "2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewEncryptedStore(store, keys, ring)
+	if got, err := readBlob(s, "blob"); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("reading before the rewrap: %v", err)
+	}
+
+	for id, key := range keys.keys {
+		aad, err := hex.DecodeString(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		masterKeyID, wrapped, err := ring.Rewrap(key.MasterKeyID, key.WrappedKey, aad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if masterKeyID != "2025" {
+			t.Errorf("rewrapped with %q, want the current key", masterKeyID)
+		}
+		key.MasterKeyID, key.WrappedKey = masterKeyID, wrapped
+		keys.keys[id] = key
+	}
+
+	// The old master key can be retired once everything is rewrapped.
+	newRing, err := LoadKeyring(newSpec, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readBlob(NewEncryptedStore(store, keys, newRing), "blob"); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("reading after the rewrap: %v", err)
+	}
+	if _, err := readBlob(NewEncryptedStore(store, keys, oldRing), "blob"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("reading with only the retired key: error = %v, want ErrDecrypt", err)
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// masterKeySize is the size of master and data keys; both are AES-256 keys.
+const masterKeySize = 32
+
+// Keyring holds the master keys data keys are wrapped with. New data keys are
+// wrapped with the current one; the others are only kept to unwrap data keys
+// wrapped before a rotation.
+type Keyring struct {
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+// LoadKeyring builds a keyring from master keys given inline and in a key
+// file, each as ID:base64-key entries separated by commas or newlines; lines
+// of the file starting with # are comments. currentID names the master key
+// new data keys are wrapped with and may be left out when there is only one.
+// Without any keys it returns nil, which disables encryption.
+func LoadKeyring(keys, keyFile, currentID string) (*Keyring, error) {
+	spec := keys
+	if keyFile != "" {
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				spec += "," + line
+			}
+		}
+	}
+	ring := &Keyring{keys: map[string]cipher.AEAD{}, current: currentID}
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || len(id) > 64 {
+			return nil, fmt.Errorf("invalid master key entry: expected ID:base64-key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != masterKeySize {
+			return nil, fmt.Errorf("master key %q must be %d base64-encoded bytes", id, masterKeySize)
+		}
+		if _, ok := ring.keys[id]; ok {
+			return nil, fmt.Errorf("master key %q is given twice", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[id] = aead
+		if currentID == "" {
+			ring.current = id
+		}
+	}
+	if len(ring.keys) == 0 {
+		if currentID != "" {
+			return nil, fmt.Errorf("master key %q is not configured", currentID)
+		}
+		return nil, nil
+	}
+	if currentID == "" && len(ring.keys) > 1 {
+		return nil, fmt.Errorf("the current master key must be named when there are several")
+	}
+	if _, ok := ring.keys[ring.current]; !ok {
+		return nil, fmt.Errorf("master key %q is not configured", ring.current)
+	}
+	return ring, nil
+}
+
+// Current returns the ID of the master key new data keys are wrapped with.
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// Wrap encrypts a data key with the current master key, binding it to aad.
+func (k *Keyring) Wrap(dataKey, aad []byte) (masterKeyID string, wrapped []byte, err error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(nonce, nonce, dataKey, aad), nil
+}
+
+// Unwrap decrypts a data key wrapped with the master key masterKeyID.
+func (k *Keyring) Unwrap(masterKeyID string, wrapped, aad []byte) ([]byte, error) {
+	aead, ok := k.keys[masterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: master key %q is not configured", ErrDecrypt, masterKeyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: wrapped data key is truncated", ErrDecrypt)
+	}
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key does not match master key %q", ErrDecrypt, masterKeyID)
+	}
+	return dataKey, nil
+}
+
+// Rewrap unwraps a data key wrapped with the master key masterKeyID and
+// wraps it again with the current one.
+func (k *Keyring) Rewrap(masterKeyID string, wrapped, aad []byte) (string, []byte, error) {
+	dataKey, err := k.Unwrap(masterKeyID, wrapped, aad)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.Wrap(dataKey, aad)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeyring(t *testing.T) {
+	a, b := masterKeySpec(t, "a"), masterKeySpec(t, "b")
+	file := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(file, []byte("# rotated 2025\n"+b+"\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		keys     string
+		keyFile  string
+		current  string
+		want     string
+		disabled bool
+		err      string
+	}{
+		{"no keys", "", "", "", "", true, ""},
+		{"one key", a, "", "", "a", false, ""},
+		{"named current", a + "," + b, "", "b", "b", false, ""},
+		{"inline and file", a, file, "b", "b", false, ""},
+		{"several without current", a + "," + b, "", "", "", false, "must be named"},
+		{"unknown current", a, "", "c", "", false, "not configured"},
+		{"current without keys", "", "", "a", "", false, "not configured"},
+		{"given twice", a + "," + a, "", "a", "", false, "twice"},
+		{"no id", ":" + strings.TrimPrefix(a, "a:"), "", "", "", false, "ID:base64-key"},
+		{"short key", "a:c2hvcnQ=", "", "", "", false, "must be 32"},
+		{"missing file", "", filepath.Join(t.TempDir(), "missing"), "", "", false, "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := LoadKeyring(tt.keys, tt.keyFile, tt.current)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadKeyring() error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (ring == nil) != tt.disabled {
+				t.Fatalf("LoadKeyring() = %v, want disabled %v", ring, tt.disabled)
+			}
+			if ring != nil && ring.Current() != tt.want {
+				t.Errorf("Current() = %q, want %q", ring.Current(), tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringWrap(t *testing.T) {
+	ring, err := LoadKeyring(masterKeySpec(t, "a"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	masterKeyID, wrapped, err := ring.Wrap(dataKey, []byte("blob-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ring.Unwrap(masterKeyID, wrapped, []byte("blob-1"))
+	if err != nil || string(got) != string(dataKey) {
+		t.Fatalf("Unwrap() = %q, %v", got, err)
+	}
+
+	tests := []struct {
+		name        string
+		masterKeyID string
+		wrapped     []byte
+		aad         string
+	}{
+		{"other blob", masterKeyID, wrapped, "blob-2"},
+		{"unknown master key", "b", wrapped, "blob-1"},
+		{"truncated", masterKeyID, wrapped[:5], "blob-1"},
+		{"tampered", masterKeyID, append(append([]byte{}, wrapped[:len(wrapped)-1]...), wrapped[len(wrapped)-1]^1), "blob-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ring.Unwrap(tt.masterKeyID, tt.wrapped, []byte(tt.aad)); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Unwrap() error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package tenant
+
+import "context"
+
+// DefaultID is the tenant used when neither the credential nor the request
+// names one.
+const DefaultID = "default"
+
+type ctxKey struct{}
+
+// WithID returns a copy of ctx carrying the tenant ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant ID stored in ctx, or DefaultID.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(ctxKey{}).(string); ok && id != "" {
+		return id
+	}
+	return DefaultID
+}
@@ -1,6 +1,13 @@
 package config
 
-import "os"
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	DBHost     string
@@ -8,8 +15,138 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
-	APIKey     string
-	Port       string
+	// APIKey is the optional shared key; managed keys live in the database.
+	APIKey string
+	Port   string
+
+	// Duplicate detection on application creation: "off", "reject" or "flag".
+	DuplicatePolicy     string
+	DuplicateSimilarity float64
+	DuplicateWindow     time.Duration
+
+	IdempotencyTTL time.Duration
+
+	// Tenants is keyed by tenant ID. It always contains DefaultTenantID.
+	Tenants map[string]TenantConfig
+
+	// Bearer token authentication is enabled when a JWKS URL or file is set;
+	// JWT_ISSUER and JWT_AUDIENCE are then required.
+	JWTIssuer      string
+	JWTAudience    string
+	JWTAlgorithms  []string
+	JWTLeeway      time.Duration
+	JWKSURL        string
+	JWKSFile       string
+	JWKSRefresh    time.Duration
+	JWTUserIDClaim string
+	JWTRolesClaim  string
+	JWTTenantClaim string
+
+	// Signed service-to-service requests are accepted from the clients in
+	// HMAC_CLIENTS_FILE.
+	HMACClients   []HMACClientConfig
+	HMACClockSkew time.Duration
+
+	// Token-bucket rate limiting per client. RateLimitRPM 0 disables the
+	// default limit; RateLimitStore is "memory" or "postgres".
+	RateLimitRPM    int
+	RateLimitBurst  int
+	RateLimitStore  string
+	RateLimitRoutes []RateLimitRoute
+	// Every request is also limited per remote IP before it is
+	// authenticated, so failed authentication cannot be flooded.
+	RateLimitIPRPM   int
+	RateLimitIPBurst int
+	// TrustedProxies may set X-Forwarded-For; the remote IP of requests
+	// from other addresses is the peer address.
+	TrustedProxies []string
+
+	// Document content goes to StorageBackend: "local" (below
+	// StorageLocalDir) or "s3".
+	StorageBackend  string
+	StorageLocalDir string
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3UseSSL        bool
+	// Blobs are encrypted with per-blob data keys wrapped by a master key
+	// when EncryptionKeys or EncryptionKeyFile holds any, as ID:base64-key
+	// entries. New data keys are wrapped with EncryptionKeyID, which may be
+	// left out when there is one key; the others are kept for data keys not
+	// rotated yet.
+	EncryptionKeys    string
+	EncryptionKeyFile string
+	EncryptionKeyID   string
+
+	// UploadMaxSize caps every upload; file types may set a lower limit.
+	// Archives are unpacked to check for zip bombs: they may hold at most
+	// ArchiveMaxEntries entries, ArchiveMaxSize uncompressed bytes, and
+	// expand at most ArchiveMaxRatio times their own size.
+	UploadMaxSize     int64
+	ArchiveMaxEntries int
+	ArchiveMaxSize    int64
+	ArchiveMaxRatio   int
+	// Documents of an application may take up at most
+	// ApplicationQuotaBytes, those of all applications of a user
+	// UserQuotaBytes, counting every version; 0 means no quota. Content no
+	// document uses any more is deleted once it has been unused for
+	// BlobGCGrace, by a collection running every BlobGCInterval.
+	ApplicationQuotaBytes int64
+	UserQuotaBytes        int64
+	BlobGCGrace           time.Duration
+	BlobGCInterval        time.Duration
+
+	// Resumable uploads expire UploadExpiry after their last chunk. A chunk,
+	// or a document uploaded in a single request, may take up to
+	// UploadChunkTimeout instead of the server timeouts.
+	UploadExpiry       time.Duration
+	UploadChunkTimeout time.Duration
+
+	// Signed download links are built on PublicBaseURL (relative links when
+	// empty) and signed with DownloadLinkSecret; without a secret a random
+	// one is used, which only works with a single instance.
+	PublicBaseURL      string
+	DownloadLinkSecret string
+	DownloadLinkTTL    time.Duration
+	DownloadLinkMaxTTL time.Duration
+
+	// Scanner is "clamd", or "none" to release documents unscanned; it has
+	// no default, so quarantine cannot be turned off by omission. Pending
+	// documents are scanned every ScanInterval and on upload; a scan fails
+	// after ScanTimeout.
+	Scanner      string
+	ClamdAddress string
+	ScanTimeout  time.Duration
+	ScanInterval time.Duration
+	// Previews of documents scanned clean are made when the scan finishes
+	// and retried every PreviewInterval.
+	PreviewInterval time.Duration
+	// Applications are checked for expired and expiring documents every
+	// DocumentExpiryInterval.
+	DocumentExpiryInterval time.Duration
+	// Documents and applications past their retention are purged every
+	// RetentionInterval.
+	RetentionInterval time.Duration
+
+	// Export jobs cover at most ExportMaxApplications applications; pending
+	// jobs are picked up every ExportInterval and their archives kept for
+	// ExportExpiry. Each write of a streamed archive must finish within
+	// ExportWriteTimeout instead of the server timeouts.
+	ExportMaxApplications int
+	ExportInterval        time.Duration
+	ExportExpiry          time.Duration
+	ExportWriteTimeout    time.Duration
+
+	// Each write of a document download must finish within
+	// DownloadWriteTimeout instead of the server's write timeout.
+	DownloadWriteTimeout time.Duration
+
+	// PolicyDir holds the authorization policy files; empty disables
+	// policies. Changed files are picked up every PolicyReloadInterval.
+	PolicyDir            string
+	PolicyReloadInterval time.Duration
 }
 
 func LoadConfig() Config {
@@ -19,8 +156,88 @@ func LoadConfig() Config {
 		DBUser:     getEnv("DB_USER", "postgres"),
 		DBPassword: getEnv("DB_PASSWORD", "postgres"),
 		DBName:     getEnv("DB_NAME", "application_service"),
-		APIKey:     getEnv("API_KEY", "supersecretkey"),
+		APIKey:     getEnv("API_KEY", ""),
 		Port:       getEnv("PORT", "8083"),
+
+		DuplicatePolicy:     getEnv("DUPLICATE_POLICY", "flag"),
+		DuplicateSimilarity: getEnvFloat("DUPLICATE_SIMILARITY", 0.6),
+		DuplicateWindow:     getEnvDuration("DUPLICATE_WINDOW", 24*time.Hour),
+
+		IdempotencyTTL: getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		Tenants: loadTenants(getEnv("TENANTS_FILE", "")),
+
+		JWTIssuer:      getEnv("JWT_ISSUER", ""),
+		JWTAudience:    getEnv("JWT_AUDIENCE", ""),
+		JWTAlgorithms:  getEnvList("JWT_ALGORITHMS", []string{"RS256", "ES256", "EdDSA"}),
+		JWTLeeway:      getEnvDuration("JWT_LEEWAY", 30*time.Second),
+		JWKSURL:        getEnv("JWKS_URL", ""),
+		JWKSFile:       getEnv("JWKS_FILE", ""),
+		JWKSRefresh:    getEnvDuration("JWKS_REFRESH", time.Hour),
+		JWTUserIDClaim: getEnv("JWT_USER_ID_CLAIM", "sub"),
+		JWTRolesClaim:  getEnv("JWT_ROLES_CLAIM", "roles"),
+		JWTTenantClaim: getEnv("JWT_TENANT_CLAIM", "tenant_id"),
+
+		HMACClients:   loadHMACClients(getEnv("HMAC_CLIENTS_FILE", "")),
+		HMACClockSkew: getEnvDuration("HMAC_CLOCK_SKEW", 5*time.Minute),
+
+		RateLimitRPM:     getEnvInt("RATE_LIMIT_RPM", 600),
+		RateLimitBurst:   getEnvInt("RATE_LIMIT_BURST", 100),
+		RateLimitStore:   getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimitRoutes:  loadRateLimitRoutes(getEnv("RATE_LIMIT_ROUTES_FILE", "")),
+		RateLimitIPRPM:   getEnvInt("RATE_LIMIT_IP_RPM", 1200),
+		RateLimitIPBurst: getEnvInt("RATE_LIMIT_IP_BURST", 200),
+		TrustedProxies:   getEnvList("TRUSTED_PROXIES", nil),
+
+		StorageBackend:  getEnv("STORAGE_BACKEND", "local"),
+		StorageLocalDir: getEnv("STORAGE_LOCAL_DIR", "data/blobs"),
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+		S3Region:        getEnv("S3_REGION", ""),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:        getEnvBool("S3_USE_SSL", true),
+
+		EncryptionKeys:    getEnv("ENCRYPTION_KEYS", ""),
+		EncryptionKeyFile: getEnv("ENCRYPTION_KEY_FILE", ""),
+		EncryptionKeyID:   getEnv("ENCRYPTION_KEY_ID", ""),
+
+		UploadMaxSize:     int64(getEnvInt("UPLOAD_MAX_SIZE", 25<<20)),
+		ArchiveMaxEntries: getEnvInt("ARCHIVE_MAX_ENTRIES", 1000),
+		ArchiveMaxSize:    int64(getEnvInt("ARCHIVE_MAX_SIZE", 200<<20)),
+		ArchiveMaxRatio:   getEnvInt("ARCHIVE_MAX_RATIO", 100),
+
+		ApplicationQuotaBytes: int64(getEnvInt("QUOTA_APPLICATION_BYTES", 0)),
+		UserQuotaBytes:        int64(getEnvInt("QUOTA_USER_BYTES", 0)),
+		BlobGCGrace:           getEnvDuration("BLOB_GC_GRACE", time.Hour),
+		BlobGCInterval:        getEnvInterval("BLOB_GC_INTERVAL", time.Hour),
+
+		UploadExpiry:       getEnvDuration("UPLOAD_EXPIRY", 24*time.Hour),
+		UploadChunkTimeout: getEnvDuration("UPLOAD_CHUNK_TIMEOUT", 10*time.Minute),
+
+		PublicBaseURL:      strings.TrimSuffix(getEnv("PUBLIC_BASE_URL", ""), "/"),
+		DownloadLinkSecret: getEnv("DOWNLOAD_LINK_SECRET", ""),
+		DownloadLinkTTL:    getEnvDuration("DOWNLOAD_LINK_TTL", 5*time.Minute),
+		DownloadLinkMaxTTL: getEnvDuration("DOWNLOAD_LINK_MAX_TTL", 24*time.Hour),
+
+		Scanner:      getEnv("SCANNER", ""),
+		ClamdAddress: getEnv("CLAMD_ADDRESS", "tcp://localhost:3310"),
+		ScanTimeout:  getEnvDuration("SCAN_TIMEOUT", 2*time.Minute),
+		ScanInterval: getEnvInterval("SCAN_INTERVAL", 10*time.Second),
+
+		PreviewInterval:        getEnvInterval("PREVIEW_INTERVAL", time.Minute),
+		DocumentExpiryInterval: getEnvInterval("DOCUMENT_EXPIRY_INTERVAL", time.Hour),
+		RetentionInterval:      getEnvInterval("RETENTION_INTERVAL", time.Hour),
+
+		ExportMaxApplications: getEnvInt("EXPORT_MAX_APPLICATIONS", 500),
+		ExportInterval:        getEnvInterval("EXPORT_INTERVAL", 30*time.Second),
+		ExportExpiry:          getEnvDuration("EXPORT_EXPIRY", 24*time.Hour),
+		ExportWriteTimeout:    getEnvDuration("EXPORT_WRITE_TIMEOUT", time.Minute),
+
+		DownloadWriteTimeout: getEnvDuration("DOWNLOAD_WRITE_TIMEOUT", time.Minute),
+
+		PolicyDir:            getEnv("POLICY_DIR", ""),
+		PolicyReloadInterval: getEnvInterval("POLICY_RELOAD_INTERVAL", 10*time.Second),
 	}
 }
 
@@ -30,3 +247,150 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvList(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvInterval reads the period of a background loop. Tickers panic on a
+// period that is not positive, so such a value stops the service at
+// startup instead.
+func getEnvInterval(key string, defaultValue time.Duration) time.Duration {
+	d := getEnvDuration(key, defaultValue)
+	if d <= 0 {
+		log.Fatalf("%s must be a positive duration, got %s", key, d)
+	}
+	return d
+}
+
+// loadTenants reads the per-tenant configuration from a JSON file holding an
+// array of TenantConfig. Without a file only the default tenant exists.
+func loadTenants(path string) map[string]TenantConfig {
+	tenants := map[string]TenantConfig{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read tenants file: %v", err)
+		}
+		var list []TenantConfig
+		if err := json.Unmarshal(data, &list); err != nil {
+			log.Fatalf("Failed to parse tenants file: %v", err)
+		}
+		for _, t := range list {
+			if t.ID == "" {
+				log.Fatalf("Tenants file contains a tenant without an id")
+			}
+			if err := t.normalizeDocumentKeys(log.Printf); err != nil {
+				log.Fatalf("Invalid tenants file: %v", err)
+			}
+			for status := range t.Retention.Statuses {
+				if !t.Workflow.IsTerminal(status) {
+					log.Fatalf("Tenant %q keeps applications in status %q, which is not terminal", t.ID, status)
+				}
+			}
+			tenants[t.ID] = t
+		}
+	}
+	if _, ok := tenants[DefaultTenantID]; !ok {
+		tenants[DefaultTenantID] = TenantConfig{ID: DefaultTenantID, Name: "Default"}
+	}
+	return tenants
+}
+
+// HMACClientConfig is one entry of the HMAC clients file.
+type HMACClientConfig struct {
+	ID       string   `json:"id"`
+	Secret   string   `json:"secret"`
+	Roles    []string `json:"roles"`
+	TenantID string   `json:"tenantId"`
+	// CrossTenant clients have no tenant and name one per request in
+	// X-Tenant-ID; clients with neither are pinned to the default tenant.
+	CrossTenant bool `json:"crossTenant"`
+}
+
+// loadHMACClients reads the JSON array of HMAC clients. Without a file no
+// client can sign requests.
+func loadHMACClients(path string) []HMACClientConfig {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read HMAC clients file: %v", err)
+	}
+	var clients []HMACClientConfig
+	if err := json.Unmarshal(data, &clients); err != nil {
+		log.Fatalf("Failed to parse HMAC clients file: %v", err)
+	}
+	return clients
+}
+
+// RateLimitRoute overrides the rate limit of one route, named like
+// "GET /api/v1/applications".
+type RateLimitRoute struct {
+	Route             string `json:"route"`
+	RequestsPerMinute int    `json:"requestsPerMinute"`
+	Burst             int    `json:"burst"`
+}
+
+// loadRateLimitRoutes reads the JSON array of per-route rate limits.
+func loadRateLimitRoutes(path string) []RateLimitRoute {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read rate limit routes file: %v", err)
+	}
+	var routes []RateLimitRoute
+	if err := json.Unmarshal(data, &routes); err != nil {
+		log.Fatalf("Failed to parse rate limit routes file: %v", err)
+	}
+	return routes
+}
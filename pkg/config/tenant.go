@@ -0,0 +1,201 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultTenantID matches tenant.DefaultID.
+const DefaultTenantID = "default"
+
+const defaultCodePrefix = "APP"
+
+// defaultTerminalStatuses close applications of tenants whose workflow does
+// not list terminal statuses of its own.
+var defaultTerminalStatuses = []string{"approved", "rejected", "withdrawn"}
+
+// defaultSubmitStatuses need a complete checklist for tenants whose
+// workflow does not list submit statuses of its own.
+var defaultSubmitStatuses = []string{"submitted"}
+
+const defaultExpiryWarningDays = 30
+
+type TenantConfig struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	CodePrefix string   `json:"codePrefix"`
+	Workflow   Workflow `json:"workflow"`
+	// Categories maps an application category to the documents it needs.
+	Categories map[string]Category `json:"categories"`
+	// DocumentExpiry configures how applications are told about expiring
+	// documents.
+	DocumentExpiry DocumentExpiry `json:"documentExpiry"`
+	// Retention configures how long closed applications are kept.
+	Retention Retention `json:"retention"`
+}
+
+// Prefix returns the prefix used for generated application codes.
+func (t TenantConfig) Prefix() string {
+	if t.CodePrefix == "" {
+		return defaultCodePrefix
+	}
+	return t.CodePrefix
+}
+
+// Workflow describes the statuses an application may go through. An empty
+// workflow accepts any status in any order.
+type Workflow struct {
+	// Initial lists the statuses an application without statuses may start in.
+	Initial []string `json:"initial"`
+	// Transitions maps a status to the statuses that may follow it.
+	Transitions map[string][]string `json:"transitions"`
+	// Terminal lists statuses that close an application; without it the
+	// default terminal statuses apply.
+	Terminal []string `json:"terminal"`
+	// Submit lists the statuses that submit an application, which need the
+	// checklist of its category to be complete; without it "submitted"
+	// does.
+	Submit []string `json:"submit"`
+}
+
+func (w Workflow) Defined() bool {
+	return len(w.Initial) > 0 || len(w.Transitions) > 0
+}
+
+// Allows reports whether an application in status from may move to status to.
+// from is empty for an application without statuses.
+func (w Workflow) Allows(from, to string) bool {
+	if !w.Defined() {
+		return true
+	}
+	if from == "" {
+		return contains(w.Initial, to)
+	}
+	return contains(w.Transitions[from], to)
+}
+
+// TerminalStatuses returns the statuses that close an application.
+func (w Workflow) TerminalStatuses() []string {
+	if len(w.Terminal) == 0 {
+		return defaultTerminalStatuses
+	}
+	return w.Terminal
+}
+
+func (w Workflow) IsTerminal(status string) bool {
+	return contains(w.TerminalStatuses(), status)
+}
+
+// IsSubmit reports whether moving to status submits the application.
+func (w Workflow) IsSubmit(status string) bool {
+	if len(w.Submit) == 0 {
+		return contains(defaultSubmitStatuses, status)
+	}
+	return contains(w.Submit, status)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// DocumentExpiry configures the expiry check. Documents expire after their
+// validUntil day and are expiring WarningDays before. When an application's
+// documents become expiring or expired, ExpiringStatus or ExpiredStatus is
+// appended to it if set and allowed by the workflow.
+type DocumentExpiry struct {
+	WarningDays    int    `json:"warningDays"`
+	ExpiringStatus string `json:"expiringStatus"`
+	ExpiredStatus  string `json:"expiredStatus"`
+}
+
+// Warning returns how many days before expiry documents are expiring.
+func (e DocumentExpiry) Warning() int {
+	if e.WarningDays <= 0 {
+		return defaultExpiryWarningDays
+	}
+	return e.WarningDays
+}
+
+// Retention maps a terminal status of the workflow to the number of days
+// applications closed with it are kept, after which they are purged with
+// their documents. Applications closed with other statuses are kept
+// indefinitely. Documents may go earlier under the retention of their
+// document type.
+type Retention struct {
+	Statuses map[string]int `json:"statuses"`
+}
+
+// Days returns how long applications closed with status are kept, or 0 for
+// indefinitely.
+func (r Retention) Days(status string) int {
+	return max(r.Statuses[status], 0)
+}
+
+// Category declares the documents applications of one category need.
+type Category struct {
+	Documents []DocumentRequirement `json:"documents"`
+}
+
+// documentKeySeparators are the runs of characters a file type name loses
+// on its way to a document type key.
+var documentKeySeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// DocumentTypeKey returns the catalog key of a file type name, the way
+// migration 000019 derived the keys of existing file types: "Passport copy"
+// becomes "passport_copy".
+func DocumentTypeKey(name string) string {
+	key := strings.Trim(documentKeySeparators.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if key == "" {
+		return "unnamed"
+	}
+	return key
+}
+
+// normalizeDocumentKeys rewrites the file types of the tenant's categories
+// to catalog keys. Checklists written before the catalog named file types
+// like "Passport"; they keep working, with a warning for each rewrite.
+func (t TenantConfig) normalizeDocumentKeys(warn func(format string, args ...interface{})) error {
+	for name, category := range t.Categories {
+		seen := map[string]bool{}
+		for i, req := range category.Documents {
+			key := DocumentTypeKey(req.FileType)
+			if key != req.FileType {
+				warn("Tenant %q category %q: fileType %q is not a document type key, using %q", t.ID, name, req.FileType, key)
+				category.Documents[i].FileType = key
+			}
+			if seen[key] {
+				return fmt.Errorf("tenant %q category %q lists fileType %q more than once", t.ID, name, key)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// DocumentRequirement declares how many documents of a file type an
+// application needs. Required documents need at least one; Max 0 means no
+// upper bound.
+type DocumentRequirement struct {
+	// FileType is the key of a document type in the tenant's catalog.
+	// Display names like "Passport" are turned into keys on load.
+	FileType    string `json:"fileType"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Min         int    `json:"min"`
+	Max         int    `json:"max"`
+}
+
+// MinCount returns the number of documents needed to satisfy the
+// requirement.
+func (r DocumentRequirement) MinCount() int {
+	if r.Required && r.Min < 1 {
+		return 1
+	}
+	return r.Min
+}
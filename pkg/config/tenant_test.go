@@ -0,0 +1,99 @@
+package config
+
+import "testing"
+
+func TestWorkflowIsTerminal(t *testing.T) {
+	custom := Workflow{Terminal: []string{"closed"}}
+	tests := []struct {
+		name     string
+		workflow Workflow
+		status   string
+		want     bool
+	}{
+		{"default approved", Workflow{}, "approved", true},
+		{"default rejected", Workflow{}, "rejected", true},
+		{"default withdrawn", Workflow{}, "withdrawn", true},
+		{"default open", Workflow{}, "review", false},
+		{"custom terminal", custom, "closed", true},
+		{"custom replaces defaults", custom, "approved", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.workflow.IsTerminal(tt.status); got != tt.want {
+				t.Errorf("IsTerminal(%q) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowIsSubmit(t *testing.T) {
+	custom := Workflow{Submit: []string{"filed", "refiled"}}
+	tests := []struct {
+		name     string
+		workflow Workflow
+		status   string
+		want     bool
+	}{
+		{"default submitted", Workflow{}, "submitted", true},
+		{"default other", Workflow{}, "filed", false},
+		{"custom", custom, "filed", true},
+		{"custom second", custom, "refiled", true},
+		{"custom replaces default", custom, "submitted", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.workflow.IsSubmit(tt.status); got != tt.want {
+				t.Errorf("IsSubmit(%q) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentTypeKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"passport", "passport"},
+		{"Passport", "passport"},
+		{" Passport ", "passport"},
+		{"Passport copy", "passport_copy"},
+		{"Proof of address (utility bill)", "proof_of_address_utility_bill"},
+		{"ID-card / front", "id_card_front"},
+		{"__tax_return__", "tax_return"},
+		{"", "unnamed"},
+		{"***", "unnamed"},
+	}
+	for _, tt := range tests {
+		if got := DocumentTypeKey(tt.name); got != tt.want {
+			t.Errorf("DocumentTypeKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeDocumentKeys(t *testing.T) {
+	tenant := TenantConfig{ID: "acme", Categories: map[string]Category{
+		"visa": {Documents: []DocumentRequirement{
+			{FileType: "Passport", Required: true},
+			{FileType: "bank_statement"},
+		}},
+	}}
+	var warnings int
+	if err := tenant.normalizeDocumentKeys(func(string, ...interface{}) { warnings++ }); err != nil {
+		t.Fatal(err)
+	}
+	docs := tenant.Categories["visa"].Documents
+	if docs[0].FileType != "passport" || docs[1].FileType != "bank_statement" {
+		t.Errorf("file types = %q, %q, want passport, bank_statement", docs[0].FileType, docs[1].FileType)
+	}
+	if warnings != 1 {
+		t.Errorf("got %d warnings, want 1", warnings)
+	}
+
+	duplicate := TenantConfig{ID: "acme", Categories: map[string]Category{
+		"visa": {Documents: []DocumentRequirement{{FileType: "Passport"}, {FileType: "passport"}}},
+	}}
+	if err := duplicate.normalizeDocumentKeys(func(string, ...interface{}) {}); err == nil {
+		t.Error("normalizeDocumentKeys() accepted a file type listed twice")
+	}
+}